@@ -0,0 +1,189 @@
+// Package board describes the Raspberry Pi boards the pump runs on: what each GPIO is wired to,
+// and which header pins the buses take over, so a bad wiring plan is caught before any GPIO is touched.
+package board
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pins says which GPIO (BCM numbering) each part of the pump is wired to
+type Pins struct {
+	Buttons []int // One per pump position; pump 1 first
+	RST     int   // MFRC522 reset
+	IRQ     int   // MFRC522 interrupt (periph.io driver only)
+}
+
+// Profile is one kind of board
+// Every 40-pin Pi has the same GPIO on the same header pins; they differ in the chip behind them
+type Profile struct {
+	Name     string
+	Models   []string // What /proc/device-tree/model starts with on this board
+	GPIOChip string   // Label of the gpiochip driving the header
+	GPIOMem  bool     // Whether /dev/gpiomem reaches the header GPIO (not through the Pi 5's RP1)
+	Pins     Pins     // Where the pump is wired unless configured otherwise
+}
+
+// defaultPins is the wiring in MFRC522_WIRING.md and the README
+var defaultPins = Pins{Buttons: []int{17, 27, 5, 6}, RST: 22, IRQ: 24}
+
+// Profiles are the boards the pump knows
+var Profiles = []Profile{
+	{Name: "pi3", Models: []string{"Raspberry Pi 3", "Raspberry Pi Compute Module 3"}, GPIOChip: "pinctrl-bcm2835", GPIOMem: true, Pins: defaultPins},
+	{Name: "pi4", Models: []string{"Raspberry Pi 4", "Raspberry Pi Compute Module 4"}, GPIOChip: "pinctrl-bcm2711", GPIOMem: true, Pins: defaultPins},
+	{Name: "pi5", Models: []string{"Raspberry Pi 5", "Raspberry Pi 500", "Raspberry Pi Compute Module 5"}, GPIOChip: "pinctrl-rp1", GPIOMem: false, Pins: defaultPins},
+	{Name: "zero2w", Models: []string{"Raspberry Pi Zero 2 W"}, GPIOChip: "pinctrl-bcm2835", GPIOMem: true, Pins: defaultPins},
+}
+
+// Lookup finds a profile by name
+func Lookup(name string) (Profile, error) {
+	var names []string
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	return Profile{}, fmt.Errorf("unknown board %q (have %s)", name, strings.Join(names, ", "))
+}
+
+// Detect picks the profile for the board this is running on, from its device tree model
+func Detect() (Profile, bool) {
+	model, err := os.ReadFile("/proc/device-tree/model")
+	if err != nil {
+		return Profile{}, false
+	}
+	return ForModel(strings.TrimRight(string(model), "\x00\n"))
+}
+
+// ForModel picks the profile for a device tree model such as "Raspberry Pi 4 Model B Rev 1.4"
+func ForModel(model string) (Profile, bool) {
+	for _, p := range Profiles {
+		for _, m := range p.Models {
+			if strings.HasPrefix(model, m) {
+				return p, true
+			}
+		}
+	}
+	return Profile{}, false
+}
+
+// ParsePin reads a GPIO number, written as 17, GPIO17 or BCM17
+func ParsePin(text string) (int, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(text), "GPIO"), "BCM")
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 || n > 27 {
+		return 0, fmt.Errorf("bad GPIO %q: expected 0 to 27, e.g. GPIO17", text)
+	}
+	return n, nil
+}
+
+// ParsePins applies "button1=17 button2=27 rst=25 irq=24" on top of pins
+// Buttons may also be given together, as buttons=17,27,5,6
+func ParsePins(text string, pins Pins) (Pins, error) {
+	pins.Buttons = append([]int(nil), pins.Buttons...)
+	for _, field := range strings.Fields(text) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Pins{}, fmt.Errorf("pin %q should be name=GPIO", field)
+		}
+		switch {
+		case key == "buttons":
+			pins.Buttons = pins.Buttons[:0]
+			for _, v := range strings.Split(value, ",") {
+				n, err := ParsePin(v)
+				if err != nil {
+					return Pins{}, fmt.Errorf("%s: %w", key, err)
+				}
+				pins.Buttons = append(pins.Buttons, n)
+			}
+		case strings.HasPrefix(key, "button"):
+			i, err := strconv.Atoi(strings.TrimPrefix(key, "button"))
+			if err != nil || i < 1 || i > len(pins.Buttons)+1 {
+				return Pins{}, fmt.Errorf("pin %q: buttons are numbered from 1 with no gaps", key)
+			}
+			n, err := ParsePin(value)
+			if err != nil {
+				return Pins{}, fmt.Errorf("%s: %w", key, err)
+			}
+			if i > len(pins.Buttons) {
+				pins.Buttons = append(pins.Buttons, n)
+			} else {
+				pins.Buttons[i-1] = n
+			}
+		case key == "rst" || key == "irq":
+			n, err := ParsePin(value)
+			if err != nil {
+				return Pins{}, fmt.Errorf("%s: %w", key, err)
+			}
+			if key == "rst" {
+				pins.RST = n
+			} else {
+				pins.IRQ = n
+			}
+		default:
+			return Pins{}, fmt.Errorf("unknown pin %q (have buttonN, buttons, rst, irq)", key)
+		}
+	}
+	return pins, nil
+}
+
+// SPI returns the pins SPI bus 0 or 1 takes over with chip select cs, by name
+func SPI(bus, cs int) (map[int]string, error) {
+	switch {
+	case bus == 0 && (cs == 0 || cs == 1):
+		return map[int]string{9: "SPI0 MISO", 10: "SPI0 MOSI", 11: "SPI0 SCLK", 8 - cs: fmt.Sprintf("SPI0 CE%d", cs)}, nil
+	case bus == 1 && cs >= 0 && cs <= 2:
+		return map[int]string{19: "SPI1 MISO", 20: "SPI1 MOSI", 21: "SPI1 SCLK", 18 - cs: fmt.Sprintf("SPI1 CE%d", cs)}, nil
+	}
+	return nil, fmt.Errorf("no SPI%d chip select %d on the header", bus, cs)
+}
+
+// UART is the pins the header's serial port takes over
+var UART = map[int]string{14: "UART TXD", 15: "UART RXD"}
+
+// Plan collects what each pin is used for, to find two things on one pin
+type Plan struct {
+	uses map[int][]string
+}
+
+// Use records that what needs pin
+func (p *Plan) Use(pin int, what string) {
+	if p.uses == nil {
+		p.uses = map[int][]string{}
+	}
+	p.uses[pin] = append(p.uses[pin], what)
+}
+
+// UseAll records every pin a bus takes over
+func (p *Plan) UseAll(pins map[int]string) {
+	for pin, what := range pins {
+		p.Use(pin, what)
+	}
+}
+
+// Check reports every pin that is used for more than one thing, or isn't on the header
+func (p *Plan) Check() error {
+	pins := make([]int, 0, len(p.uses))
+	for pin := range p.uses {
+		pins = append(pins, pin)
+	}
+	sort.Ints(pins)
+
+	var errs []error
+	for _, pin := range pins {
+		uses := p.uses[pin]
+		sort.Strings(uses)
+		switch {
+		case pin < 0 || pin > 27:
+			errs = append(errs, fmt.Errorf("GPIO%d (%s) is not on the header", pin, strings.Join(uses, ", ")))
+		case len(uses) > 1:
+			errs = append(errs, fmt.Errorf("GPIO%d is wanted for %s", pin, strings.Join(uses, " and ")))
+		}
+	}
+	return errors.Join(errs...)
+}
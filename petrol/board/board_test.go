@@ -0,0 +1,152 @@
+package board
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"Raspberry Pi 4 Model B Rev 1.4", "pi4"},
+		{"Raspberry Pi 5 Model B Rev 1.0", "pi5"},
+		{"Raspberry Pi 500 Rev 1.0", "pi5"},
+		{"Raspberry Pi 3 Model B Plus Rev 1.3", "pi3"},
+		{"Raspberry Pi Zero 2 W Rev 1.0", "zero2w"},
+		{"Raspberry Pi Compute Module 4 Rev 1.0", "pi4"},
+		{"Raspberry Pi Zero W Rev 1.1", ""},
+		{"Some other board", ""},
+	}
+	for _, tt := range tests {
+		p, ok := ForModel(tt.model)
+		if ok != (tt.want != "") || p.Name != tt.want {
+			t.Errorf("ForModel(%q) = %q, %v; want %q", tt.model, p.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int
+		wantErr bool
+	}{
+		{"17", 17, false},
+		{"GPIO17", 17, false},
+		{"gpio5", 5, false},
+		{"BCM27", 27, false},
+		{"0", 0, false},
+		{"28", 0, true},
+		{"-1", 0, true},
+		{"pin11", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePin(tt.text)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePin(%q) = %d, %v; want %d, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Pins
+		wantErr bool
+	}{
+		{"", defaultPins, false},
+		{"button1=23", Pins{Buttons: []int{23, 27, 5, 6}, RST: 22, IRQ: 24}, false},
+		{"button5=13", Pins{Buttons: []int{17, 27, 5, 6, 13}, RST: 22, IRQ: 24}, false},
+		{"buttons=GPIO4,GPIO12 rst=25 irq=GPIO16", Pins{Buttons: []int{4, 12}, RST: 25, IRQ: 16}, false},
+		{"buttons=4 button2=12", Pins{Buttons: []int{4, 12}, RST: 22, IRQ: 24}, false},
+		{"button6=13", Pins{}, true}, // Leaves a gap
+		{"button0=13", Pins{}, true},
+		{"rst", Pins{}, true},
+		{"rst=40", Pins{}, true},
+		{"buttons=4,x", Pins{}, true},
+		{"led=18", Pins{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePins(tt.text, defaultPins)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePins(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePins(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+
+	// The profile's pins are left alone
+	if _, err := ParsePins("button1=23", defaultPins); err != nil || defaultPins.Buttons[0] != 17 {
+		t.Errorf("ParsePins changed the pins it started from: %v", defaultPins.Buttons)
+	}
+}
+
+func TestPlanCheck(t *testing.T) {
+	spi0, err := SPI(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ok Plan
+	ok.UseAll(spi0)
+	for i, pin := range defaultPins.Buttons {
+		ok.Use(pin, fmt.Sprintf("pump %d button", i+1))
+	}
+	ok.Use(defaultPins.RST, "MFRC522 RST")
+	if err := ok.Check(); err != nil {
+		t.Errorf("default wiring has conflicts: %v", err)
+	}
+
+	var bad Plan
+	bad.UseAll(spi0)
+	bad.UseAll(UART)
+	bad.Use(8, "pump 1 button")  // SPI0 CE0
+	bad.Use(14, "pump 2 button") // UART TXD
+	bad.Use(30, "MFRC522 RST")
+	err = bad.Check()
+	if err == nil {
+		t.Fatal("no conflicts found")
+	}
+	for _, want := range []string{
+		"GPIO8 is wanted for SPI0 CE0 and pump 1 button",
+		"GPIO14 is wanted for UART TXD and pump 2 button",
+		"GPIO30 (MFRC522 RST) is not on the header",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("conflicts %q don't include %q", err, want)
+		}
+	}
+}
+
+func TestSPI(t *testing.T) {
+	tests := []struct {
+		bus, cs int
+		pins    []int
+		wantErr bool
+	}{
+		{0, 0, []int{8, 9, 10, 11}, false},
+		{0, 1, []int{7, 9, 10, 11}, false},
+		{1, 2, []int{16, 19, 20, 21}, false},
+		{0, 2, nil, true},
+		{2, 0, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := SPI(tt.bus, tt.cs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SPI(%d, %d) error = %v", tt.bus, tt.cs, err)
+			continue
+		}
+		for _, pin := range tt.pins {
+			if _, ok := got[pin]; !ok {
+				t.Errorf("SPI(%d, %d) doesn't use GPIO%d: %v", tt.bus, tt.cs, pin, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package emv reads the payment application on a contactless bank card or phone.
+//
+// It runs just enough of the EMV contactless flow to identify the card:
+// SELECT the proximity payment system environment (PPSE) to list the
+// card's applications, SELECT the preferred one, GET PROCESSING OPTIONS,
+// and READ RECORD until the card number turns up. No cryptograms are
+// checked and nothing is authorised here - that is the acquirer's job.
+//
+// Only the masked card number is kept, never the full PAN.
+package emv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+// ppse is the name of the proximity payment system environment every contactless card answers to
+const ppse = "2PAY.SYS.DDF01"
+
+// Tags used in the flow (EMV Book 3 annex A)
+const (
+	tagAID          = 0x4F   // Application identifier, in a directory entry
+	tagLabel        = 0x50   // Application label
+	tagPriority     = 0x87   // Application priority indicator
+	tagDirEntry     = 0x61   // Application template, one per application in the PPSE
+	tagPDOL         = 0x9F38 // Processing options data object list
+	tagCommandTmpl  = 0x83   // Command template, wrapping the PDOL data in GPO
+	tagRespFormat1  = 0x80   // GPO response: AIP and AFL with no tags
+	tagRespFormat2  = 0x77   // GPO response: tagged data objects
+	tagAFL          = 0x94   // Application file locator: which records to read
+	tagRecord       = 0x70   // Record template
+	tagPAN          = 0x5A   // Primary account number
+	tagTrack2       = 0x57   // Track 2 equivalent data
+	tagTrack2Mag    = 0x9F6B // Track 2 data (Mastercard magstripe mode)
+	tagExpiry       = 0x5F24 // Application expiration date, YYMMDD
+	tagTTQ          = 0x9F66 // Terminal transaction qualifiers
+	tagAmount       = 0x9F02 // Amount, authorised
+	tagAmountOther  = 0x9F03 // Amount, other (cashback)
+	tagCountry      = 0x9F1A // Terminal country code
+	tagCurrency     = 0x5F2A // Transaction currency code
+	tagTVR          = 0x95   // Terminal verification results
+	tagDate         = 0x9A   // Transaction date, YYMMDD
+	tagTxnType      = 0x9C   // Transaction type
+	tagUnpredNumber = 0x9F37 // Unpredictable number
+	tagTermType     = 0x9F35 // Terminal type
+)
+
+// Status words the flow cares about
+const (
+	swOK           = 0x9000
+	swNotFound     = 0x6A82
+	swMoreData     = 0x61 // First byte: SW2 bytes are waiting for GET RESPONSE
+	swWrongLength  = 0x6C // First byte: send again with Le = SW2
+	swRecordAbsent = 0x6A83
+)
+
+// maxResends is how many GET RESPONSEs or resends with a new length one command may take,
+// so a card that keeps asking for them can't hold up the payment
+const maxResends = 8
+
+var (
+	// ErrNoApplication means the card has no payment application we can use
+	ErrNoApplication = errors.New("no payment application on card")
+
+	// ErrNoPAN means the card never gave out its card number
+	ErrNoPAN = errors.New("card number not found in records")
+)
+
+// StatusError is a command the card refused, with the status word it gave
+type StatusError struct {
+	Command string
+	SW      uint16
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s refused with status %04X", e.Command, e.SW)
+}
+
+// Transceiver sends a command APDU to a card and returns its response, status word included
+type Transceiver interface {
+	Transceive(apdu []byte) ([]byte, error)
+}
+
+// Application is what the pump learns about a card's payment application
+type Application struct {
+	AID       []byte
+	Label     string // As the card names it, e.g. "VISA DEBIT"
+	Scheme    string // Card scheme, e.g. "Visa", from the AID
+	MaskedPAN string // All but the last four digits hidden
+	Expiry    string // YYMM, if the card gave it
+}
+
+// String is the line shown on screen, e.g. "Visa **** **** **** 1111"
+func (a Application) String() string {
+	if a.MaskedPAN == "" {
+		return a.Scheme
+	}
+	return a.Scheme + " " + a.MaskedPAN
+}
+
+// schemes maps registered application provider IDs (the first five bytes of an AID) to card schemes
+// Longer AIDs come first so that Maestro wins over Mastercard
+var schemes = []struct {
+	aid    string
+	scheme string
+}{
+	{"A0000000043060", "Maestro"},
+	{"A000000003", "Visa"},
+	{"A000000004", "Mastercard"},
+	{"A000000025", "American Express"},
+	{"A000000065", "JCB"},
+	{"A000000152", "Discover"},
+	{"A000000333", "UnionPay"},
+	{"A000000277", "Interac"},
+}
+
+// schemeFor names the scheme of aid, falling back to the card's own label
+func schemeFor(aid []byte, label string) string {
+	h := strings.ToUpper(hex.EncodeToString(aid))
+	for _, s := range schemes {
+		if strings.HasPrefix(h, s.aid) {
+			return s.scheme
+		}
+	}
+	if label != "" {
+		return label
+	}
+	return "Card"
+}
+
+// MaskPAN hides all but the last four digits of a card number, e.g. "**** **** **** 1111"
+func MaskPAN(pan string) string {
+	if len(pan) <= 4 {
+		return pan
+	}
+	var groups []string
+	for hidden := len(pan) - 4; hidden > 0; hidden -= 4 {
+		groups = append(groups, strings.Repeat("*", min(hidden, 4)))
+	}
+	return strings.Join(append(groups, pan[len(pan)-4:]), " ")
+}
+
+// Read identifies the payment application on a card, as if starting a purchase of amount
+// The card sees a normal GET PROCESSING OPTIONS, so phones may ask their owner to unlock first
+func Read(t Transceiver, amount pump.Money) (Application, error) {
+	candidates, err := selectPPSE(t)
+	if err != nil {
+		return Application{}, err
+	}
+
+	var app Application
+	var fci []byte
+	for _, c := range candidates {
+		fci, err = command(t, "SELECT AID", selectAPDU(c.aid))
+		var se *StatusError
+		if errors.As(err, &se) {
+			continue // Blocked or missing; try the next one
+		} else if err != nil {
+			return Application{}, err
+		}
+		app = Application{AID: c.aid, Label: c.label}
+		break
+	}
+	if app.AID == nil {
+		return Application{}, ErrNoApplication
+	}
+	if label, ok := findTag(fci, tagLabel); ok && app.Label == "" {
+		app.Label = string(label)
+	}
+	app.Scheme = schemeFor(app.AID, app.Label)
+
+	pdol, _ := findTag(fci, tagPDOL)
+	data, err := pdolData(pdol, amount)
+	if err != nil {
+		return app, err
+	}
+	tmpl := encodeTLV(tagCommandTmpl, data)
+	gpo := append([]byte{0x80, 0xA8, 0x00, 0x00, byte(len(tmpl))}, tmpl...)
+	gpo = append(gpo, 0x00)
+	resp, err := command(t, "GET PROCESSING OPTIONS", gpo)
+	if err != nil {
+		return app, err
+	}
+
+	// Some cards put the card number straight in the GPO response
+	if app.readPAN(resp) {
+		return app, nil
+	}
+	afl, err := fileLocator(resp)
+	if err != nil {
+		return app, err
+	}
+	for i := 0; i+4 <= len(afl); i += 4 {
+		sfi, first, last := afl[i]>>3, afl[i+1], afl[i+2]
+		for rec := first; rec <= last && rec != 0; rec++ {
+			record, err := command(t, "READ RECORD", []byte{0x00, 0xB2, rec, sfi<<3 | 0x04, 0x00})
+			var se *StatusError
+			if errors.As(err, &se) && se.SW == swRecordAbsent {
+				continue
+			} else if err != nil {
+				return app, err
+			}
+			if app.readPAN(record) {
+				return app, nil
+			}
+		}
+	}
+	return app, ErrNoPAN
+}
+
+// candidate is one application listed in the PPSE
+type candidate struct {
+	aid      []byte
+	label    string
+	priority int // 1 is highest; 0 means the card didn't say
+}
+
+// selectPPSE lists the card's applications, highest priority first
+func selectPPSE(t Transceiver) ([]candidate, error) {
+	fci, err := command(t, "SELECT PPSE", selectAPDU([]byte(ppse)))
+	var se *StatusError
+	if errors.As(err, &se) && se.SW == swNotFound {
+		return nil, ErrNoApplication
+	} else if err != nil {
+		return nil, err
+	}
+
+	// 6F > A5 > BF0C > 61 (4F, 50, 87) ...
+	var list []candidate
+	var walk func(data []byte)
+	walk = func(data []byte) {
+		objects, _ := parseTLV(data)
+		for _, o := range objects {
+			if o.Tag == tagDirEntry {
+				var c candidate
+				if aid, ok := findTag(o.Value, tagAID); ok {
+					c.aid = aid
+				}
+				if label, ok := findTag(o.Value, tagLabel); ok {
+					c.label = string(label)
+				}
+				if p, ok := findTag(o.Value, tagPriority); ok && len(p) == 1 {
+					c.priority = int(p[0] & 0x0F)
+				}
+				if len(c.aid) >= 5 {
+					list = append(list, c)
+				}
+			} else if constructed(o.Tag) {
+				walk(o.Value)
+			}
+		}
+	}
+	walk(fci)
+	if len(list) == 0 {
+		return nil, ErrNoApplication
+	}
+
+	// Stable insertion sort: cards list few applications
+	rank := func(c candidate) int {
+		if c.priority == 0 {
+			return 16
+		}
+		return c.priority
+	}
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && rank(list[j]) < rank(list[j-1]); j-- {
+			list[j], list[j-1] = list[j-1], list[j]
+		}
+	}
+	return list, nil
+}
+
+// selectAPDU selects an application or file by name
+func selectAPDU(name []byte) []byte {
+	apdu := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(name))}, name...)
+	return append(apdu, 0x00)
+}
+
+// pdolData fills in the terminal data a card asks for in its PDOL
+// Anything the pump has no value for is sent as zeros, which cards accept
+func pdolData(pdol []byte, amount pump.Money) ([]byte, error) {
+	entries, err := parseDOL(pdol)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var data []byte
+	for _, e := range entries {
+		v := e.Value
+		switch e.Tag {
+		case tagTTQ:
+			// Contactless EMV mode, online capable, CVM not required for this read
+			copy(v, []byte{0x36, 0x00, 0x40, 0x00})
+		case tagAmount:
+			copy(v, bcd(int64(amount), len(v)))
+		case tagCountry, tagCurrency:
+			copy(v, []byte{0x08, 0x26}) // United Kingdom, pound sterling
+		case tagDate:
+			copy(v, bcd(int64(now.Year()%100*10000+int(now.Month())*100+now.Day()), len(v)))
+		case tagUnpredNumber:
+			rand.Read(v)
+		case tagTermType:
+			copy(v, []byte{0x22}) // Attended, offline with online capability, merchant operated
+		case tagAmountOther, tagTVR, tagTxnType:
+			// Zeros: no cashback, nothing wrong yet, a purchase
+		}
+		data = append(data, v...)
+	}
+	return data, nil
+}
+
+// bcd packs n right-aligned into size bytes of binary coded decimal
+func bcd(n int64, size int) []byte {
+	out := make([]byte, size)
+	for i := size - 1; i >= 0 && n > 0; i-- {
+		out[i] = byte(n%10) | byte(n/10%10)<<4
+		n /= 100
+	}
+	return out
+}
+
+// fileLocator finds the AFL in a GPO response of either format
+func fileLocator(resp []byte) ([]byte, error) {
+	objects, err := parseTLV(resp)
+	if err != nil || len(objects) == 0 {
+		return nil, fmt.Errorf("unreadable GPO response % X", resp)
+	}
+	switch o := objects[0]; o.Tag {
+	case tagRespFormat1:
+		// Two bytes of AIP, then the AFL
+		if len(o.Value) < 2 {
+			return nil, fmt.Errorf("short GPO response % X", resp)
+		}
+		return o.Value[2:], nil
+	case tagRespFormat2:
+		afl, _ := findTag(o.Value, tagAFL)
+		return afl, nil
+	}
+	return nil, fmt.Errorf("unexpected GPO response tag %X", objects[0].Tag)
+}
+
+// readPAN picks the card number and expiry out of data, from tag 5A or track 2
+// Reports whether the card number was found
+func (a *Application) readPAN(data []byte) bool {
+	if exp, ok := findTag(data, tagExpiry); ok && len(exp) >= 2 {
+		a.Expiry = hex.EncodeToString(exp[:2])
+	}
+	var pan string
+	if v, ok := findTag(data, tagPAN); ok {
+		pan = strings.TrimRight(strings.ToUpper(hex.EncodeToString(v)), "F")
+	} else {
+		track, ok := findTag(data, tagTrack2)
+		if !ok {
+			track, ok = findTag(data, tagTrack2Mag)
+		}
+		if !ok {
+			return false
+		}
+		// The card number runs up to the D separator, followed by YYMM
+		digits := strings.ToUpper(hex.EncodeToString(track))
+		sep := strings.IndexByte(digits, 'D')
+		if sep < 0 {
+			return false
+		}
+		pan = digits[:sep]
+		if a.Expiry == "" && len(digits) >= sep+5 {
+			a.Expiry = digits[sep+1 : sep+5]
+		}
+	}
+	if pan == "" {
+		return false
+	}
+	a.MaskedPAN = MaskPAN(pan)
+	return true
+}
+
+// command sends an APDU and returns the response data once the status word is 9000
+// It fetches the rest of long answers (61xx) and resends with the length the card wants (6Cxx),
+// up to maxResends times before giving up with the last status word
+func command(t Transceiver, name string, apdu []byte) ([]byte, error) {
+	var data []byte
+	for resends := 0; ; resends++ {
+		resp, err := t.Transceive(apdu)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(resp) < 2 {
+			return nil, fmt.Errorf("%s: response too short (% X)", name, resp)
+		}
+		sw1, sw2 := resp[len(resp)-2], resp[len(resp)-1]
+		data = append(data, resp[:len(resp)-2]...)
+		if (sw1 == swMoreData || sw1 == swWrongLength) && resends == maxResends {
+			return nil, &StatusError{Command: name, SW: uint16(sw1)<<8 | uint16(sw2)}
+		}
+		switch {
+		case sw1 == swMoreData:
+			apdu = []byte{0x00, 0xC0, 0x00, 0x00, sw2} // GET RESPONSE
+			continue
+		case sw1 == swWrongLength:
+			apdu = append(append([]byte{}, apdu[:len(apdu)-1]...), sw2)
+			continue
+		case uint16(sw1)<<8|uint16(sw2) != swOK:
+			return nil, &StatusError{Command: name, SW: uint16(sw1)<<8 | uint16(sw2)}
+		}
+		return data, nil
+	}
+}
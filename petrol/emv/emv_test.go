@@ -0,0 +1,156 @@
+package emv
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestReadTestCards(t *testing.T) {
+	tests := []struct {
+		card TestCard
+		want Application
+	}{
+		{VisaTestCard, Application{Label: "VISA DEBIT", Scheme: "Visa", MaskedPAN: "**** **** **** 1111", Expiry: "2812"}},
+		{MastercardTestCard, Application{Label: "MASTERCARD", Scheme: "Mastercard", MaskedPAN: "**** **** **** 4444", Expiry: "2906"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.Scheme, func(t *testing.T) {
+			app, err := Read(tt.card, 4250)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(app.AID, tt.card.AID) {
+				t.Errorf("AID % X, want % X", app.AID, tt.card.AID)
+			}
+			app.AID = nil
+			if !reflect.DeepEqual(app, tt.want) {
+				t.Errorf("Read = %+v, want %+v", app, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNoApplication(t *testing.T) {
+	card := TestCard{AID: []byte{0xA0, 0x00, 0x00, 0x00}} // Too short to list
+	if _, err := Read(card, 100); !errors.Is(err, ErrNoApplication) {
+		t.Errorf("Read = %v, want ErrNoApplication", err)
+	}
+}
+
+// scriptedCard answers each command with the next response, recording what it was sent
+type scriptedCard struct {
+	responses [][]byte
+	sent      [][]byte
+}
+
+func (c *scriptedCard) Transceive(apdu []byte) ([]byte, error) {
+	c.sent = append(c.sent, append([]byte(nil), apdu...))
+	if len(c.responses) == 0 {
+		return nil, errors.New("no more responses")
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+func TestCommandStatusWords(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses [][]byte
+		want      []byte
+		sent      [][]byte // After the first command
+		sw        uint16   // StatusError wanted, 0 for none
+	}{
+		{"ok", [][]byte{{0x01, 0x02, 0x90, 0x00}}, []byte{0x01, 0x02}, nil, 0},
+		{"more data", [][]byte{{0x01, 0x61, 0x02}, {0x02, 0x03, 0x90, 0x00}}, []byte{0x01, 0x02, 0x03},
+			[][]byte{{0x00, 0xC0, 0x00, 0x00, 0x02}}, 0},
+		{"wrong length", [][]byte{{0x6C, 0x10}, {0x07, 0x90, 0x00}}, []byte{0x07},
+			[][]byte{{0x00, 0xB2, 0x01, 0x0C, 0x10}}, 0},
+		{"refused", [][]byte{{0x6A, 0x83}}, nil, nil, 0x6A83},
+		{"more data, eight times", append(slices.Repeat([][]byte{{0x01, 0x61, 0x01}}, maxResends), []byte{0x02, 0x90, 0x00}),
+			[]byte{0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x02}, nil, 0},
+		{"more data for ever", slices.Repeat([][]byte{{0x01, 0x61, 0x01}}, maxResends+1), nil, nil, 0x6101},
+		{"wrong length for ever", slices.Repeat([][]byte{{0x6C, 0x10}}, maxResends+1), nil, nil, 0x6C10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &scriptedCard{responses: tt.responses}
+			got, err := command(card, "READ RECORD", []byte{0x00, 0xB2, 0x01, 0x0C, 0x00})
+			var se *StatusError
+			switch {
+			case tt.sw != 0:
+				if !errors.As(err, &se) || se.SW != tt.sw {
+					t.Fatalf("error %v, want status %04X", err, tt.sw)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("data % X, want % X", got, tt.want)
+			}
+			for i, want := range tt.sent {
+				if !bytes.Equal(card.sent[i+1], want) {
+					t.Errorf("command %d was % X, want % X", i+2, card.sent[i+1], want)
+				}
+			}
+		})
+	}
+
+	if _, err := command(&scriptedCard{responses: [][]byte{{0x90}}}, "SELECT", []byte{0x00}); err == nil {
+		t.Error("accepted a response with no status word")
+	}
+}
+
+func TestReadPANFromTrack2(t *testing.T) {
+	var app Application
+	record := encodeTLV(tagRecord, encodeTLV(tagTrack2, mustHex("5413330089600010D2512201")))
+	if !app.readPAN(record) {
+		t.Fatal("no card number found in track 2")
+	}
+	if app.MaskedPAN != "**** **** **** 0010" || app.Expiry != "2512" {
+		t.Errorf("got %q expiring %q", app.MaskedPAN, app.Expiry)
+	}
+}
+
+func TestFileLocatorFormat1(t *testing.T) {
+	resp := encodeTLV(tagRespFormat1, []byte{0x20, 0x00, 0x08, 0x01, 0x01, 0x00})
+	afl, err := fileLocator(resp)
+	if err != nil || !bytes.Equal(afl, []byte{0x08, 0x01, 0x01, 0x00}) {
+		t.Errorf("fileLocator = % X, %v", afl, err)
+	}
+}
+
+func TestMaskPAN(t *testing.T) {
+	tests := []struct{ pan, want string }{
+		{"4111111111111111", "**** **** **** 1111"},
+		{"378282246310005", "**** **** *** 0005"},
+		{"1234", "1234"},
+		{"12345", "* 2345"},
+	}
+	for _, tt := range tests {
+		if got := MaskPAN(tt.pan); got != tt.want {
+			t.Errorf("MaskPAN(%q) = %q, want %q", tt.pan, got, tt.want)
+		}
+	}
+}
+
+func TestBCD(t *testing.T) {
+	tests := []struct {
+		n    int64
+		size int
+		want []byte
+	}{
+		{4250, 6, []byte{0x00, 0x00, 0x00, 0x00, 0x42, 0x50}},
+		{240517, 3, []byte{0x24, 0x05, 0x17}},
+		{7, 1, []byte{0x07}},
+	}
+	for _, tt := range tests {
+		if got := bcd(tt.n, tt.size); !bytes.Equal(got, tt.want) {
+			t.Errorf("bcd(%d, %d) = % X, want % X", tt.n, tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package emv
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// tlv is one BER-TLV data object; constructed objects hold their children in Value
+type tlv struct {
+	Tag   uint32
+	Value []byte
+}
+
+// constructed reports whether a tag's value is itself a list of data objects
+func constructed(tag uint32) bool {
+	for tag > 0xFF {
+		tag >>= 8
+	}
+	return tag&0x20 != 0
+}
+
+// readTag reads a tag from the start of data and returns it with its length in bytes
+// A tag is one byte, or more if the low five bits of the first are all set; later bytes have bit 8 set while more follow
+func readTag(data []byte) (uint32, int, error) {
+	tag, n := uint32(data[0]), 1
+	if data[0]&0x1F != 0x1F {
+		return tag, n, nil
+	}
+	for {
+		if n >= len(data) || n > 3 {
+			return 0, 0, fmt.Errorf("truncated tag % X", data)
+		}
+		tag = tag<<8 | uint32(data[n])
+		n++
+		if data[n-1]&0x80 == 0 {
+			return tag, n, nil
+		}
+	}
+}
+
+// parseTLV reads a list of BER-TLV data objects
+// Padding bytes of 00 or FF between objects are skipped, as EMV allows
+func parseTLV(data []byte) ([]tlv, error) {
+	var out []tlv
+	for len(data) > 0 {
+		if data[0] == 0x00 || data[0] == 0xFF {
+			data = data[1:]
+			continue
+		}
+
+		tag, n, err := readTag(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+
+		// Length: one byte up to 127, or 0x81/0x82 followed by one or two length bytes
+		if len(data) < 1 {
+			return nil, fmt.Errorf("tag %X: missing length", tag)
+		}
+		length, n := int(data[0]), 1
+		switch data[0] {
+		case 0x81:
+			if len(data) < 2 {
+				return nil, fmt.Errorf("tag %X: truncated length", tag)
+			}
+			length, n = int(data[1]), 2
+		case 0x82:
+			if len(data) < 3 {
+				return nil, fmt.Errorf("tag %X: truncated length", tag)
+			}
+			length, n = int(binary.BigEndian.Uint16(data[1:])), 3
+		default:
+			if data[0] > 0x7F {
+				return nil, fmt.Errorf("tag %X: unsupported length byte %02X", tag, data[0])
+			}
+		}
+		data = data[n:]
+		if len(data) < length {
+			return nil, fmt.Errorf("tag %X: %d bytes wanted, %d left", tag, length, len(data))
+		}
+		out = append(out, tlv{Tag: tag, Value: data[:length]})
+		data = data[length:]
+	}
+	return out, nil
+}
+
+// findTag searches data depth first for tag, looking inside constructed objects
+func findTag(data []byte, tag uint32) ([]byte, bool) {
+	objects, err := parseTLV(data)
+	if err != nil {
+		return nil, false
+	}
+	for _, o := range objects {
+		if o.Tag == tag {
+			return o.Value, true
+		}
+		if constructed(o.Tag) {
+			if v, ok := findTag(o.Value, tag); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// encodeTLV lays out a primitive or constructed data object
+func encodeTLV(tag uint32, value []byte) []byte {
+	var out []byte
+	for shift := 24; shift > 0; shift -= 8 {
+		if b := byte(tag >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	out = append(out, byte(tag))
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xFF:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// parseDOL reads a data object list - tags and lengths with no values, as in a PDOL
+func parseDOL(dol []byte) ([]tlv, error) {
+	var out []tlv
+	for len(dol) > 0 {
+		tag, n, err := readTag(dol)
+		if err != nil {
+			return nil, err
+		}
+		if n >= len(dol) {
+			return nil, fmt.Errorf("DOL tag %X: missing length", tag)
+		}
+		out = append(out, tlv{Tag: tag, Value: make([]byte, dol[n])})
+		dol = dol[n+1:]
+	}
+	return out, nil
+}
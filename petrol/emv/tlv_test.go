@@ -0,0 +1,127 @@
+package emv
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadTag(t *testing.T) {
+	tests := []struct {
+		data    []byte
+		tag     uint32
+		n       int
+		wantErr bool
+	}{
+		{[]byte{0x5A, 0x08}, 0x5A, 1, false},
+		{[]byte{0x9F, 0x38, 0x03}, 0x9F38, 2, false},
+		{[]byte{0xBF, 0x0C, 0x10}, 0xBF0C, 2, false},
+		{[]byte{0x1F, 0x81, 0x01}, 0x1F8101, 3, false},
+		{[]byte{0x9F}, 0, 0, true},
+		{[]byte{0x1F, 0x81, 0x81, 0x81, 0x01}, 0, 0, true}, // Longer than four bytes
+	}
+	for _, tt := range tests {
+		tag, n, err := readTag(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readTag(% X) error = %v, want error %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if tag != tt.tag || n != tt.n {
+			t.Errorf("readTag(% X) = %X, %d; want %X, %d", tt.data, tag, n, tt.tag, tt.n)
+		}
+	}
+}
+
+func TestParseTLV(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 200)
+	longer := bytes.Repeat([]byte{0xCD}, 300)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []tlv
+		wantErr bool
+	}{
+		{"empty", nil, nil, false},
+		{"one", []byte{0x50, 0x02, 'O', 'K'}, []tlv{{0x50, []byte("OK")}}, false},
+		{"two-byte tag", []byte{0x9F, 0x02, 0x01, 0x99}, []tlv{{0x9F02, []byte{0x99}}}, false},
+		{"padding skipped", []byte{0x00, 0xFF, 0x5A, 0x01, 0x41, 0x00}, []tlv{{0x5A, []byte{0x41}}}, false},
+		{"81 length", append([]byte{0x70, 0x81, 0xC8}, long...), []tlv{{0x70, long}}, false},
+		{"82 length", append([]byte{0x70, 0x82, 0x01, 0x2C}, longer...), []tlv{{0x70, longer}}, false},
+		{"zero length", []byte{0x95, 0x00, 0x9C, 0x01, 0x00}, []tlv{{0x95, []byte{}}, {0x9C, []byte{0x00}}}, false},
+		{"missing length", []byte{0x5A}, nil, true},
+		{"truncated 81 length", []byte{0x5A, 0x81}, nil, true},
+		{"truncated 82 length", []byte{0x5A, 0x82, 0x01}, nil, true},
+		{"unsupported length", []byte{0x5A, 0x83, 0x00, 0x00, 0x01}, nil, true},
+		{"value past the end", []byte{0x5A, 0x04, 0x01, 0x02}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTLV(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTLV = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindTag(t *testing.T) {
+	// 6F > A5 > BF0C > 61 > 4F, as in a PPSE answer
+	aid := []byte{0xA0, 0x00, 0x00, 0x00, 0x03, 0x10, 0x10}
+	entry := encodeTLV(tagDirEntry, append(encodeTLV(tagAID, aid), encodeTLV(tagLabel, []byte("VISA"))...))
+	fci := encodeTLV(0x6F, append(encodeTLV(0x84, []byte(ppse)), encodeTLV(0xA5, encodeTLV(0xBF0C, entry))...))
+
+	if v, ok := findTag(fci, tagAID); !ok || !bytes.Equal(v, aid) {
+		t.Errorf("findTag(4F) = % X, %v; want % X", v, ok, aid)
+	}
+	if v, ok := findTag(fci, tagLabel); !ok || string(v) != "VISA" {
+		t.Errorf("findTag(50) = %q, %v", v, ok)
+	}
+	if _, ok := findTag(fci, tagPAN); ok {
+		t.Error("found a tag that isn't there")
+	}
+	// 84 is primitive, so a 4F-looking byte inside its value isn't searched
+	if _, ok := findTag(encodeTLV(0x84, []byte{0x4F, 0x01, 0x00}), tagAID); ok {
+		t.Error("searched inside a primitive object")
+	}
+}
+
+func TestEncodeTLVRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		tag  uint32
+		size int
+	}{
+		{0x5A, 0}, {0x5A, 8}, {0x9F38, 0x7F}, {0x70, 0x80}, {0x70, 0xFF}, {0xBF0C, 0x100}, {0x70, 1000},
+	} {
+		value := bytes.Repeat([]byte{0x42}, tt.size)
+		got, err := parseTLV(encodeTLV(tt.tag, value))
+		if err != nil || len(got) != 1 || got[0].Tag != tt.tag || !bytes.Equal(got[0].Value, value) {
+			t.Errorf("tag %X with %d bytes: round trip gave %v, %v", tt.tag, tt.size, got, err)
+		}
+	}
+}
+
+func TestParseDOL(t *testing.T) {
+	got, err := parseDOL(testPDOL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		tag  uint32
+		size int
+	}{{tagTTQ, 4}, {tagAmount, 6}, {tagUnpredNumber, 4}, {tagCurrency, 2}}
+	if len(got) != len(want) {
+		t.Fatalf("parseDOL gave %d entries, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Tag != w.tag || len(got[i].Value) != w.size {
+			t.Errorf("entry %d = %X (%d bytes), want %X (%d bytes)", i, got[i].Tag, len(got[i].Value), w.tag, w.size)
+		}
+	}
+
+	if _, err := parseDOL([]byte{0x9F, 0x02}); err == nil {
+		t.Error("parsed a DOL entry with no length")
+	}
+}
@@ -0,0 +1,88 @@
+// Package evdev reads key presses from a Linux input device, such as a USB card reader that types like a keyboard.
+package evdev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// eviocgrab is EVIOCGRAB, _IOW('E', 0x90, int): while grabbed, only this file sees the device's events
+const eviocgrab = 0x40044590
+
+// Event types and values (linux/input-event-codes.h)
+const (
+	evKey      = 0x01
+	keyPressed = 1
+)
+
+// inputEvent is struct input_event; the timeval is as wide as the kernel's long
+type inputEvent struct {
+	Time  unix.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Device is an input device opened for this program alone
+type Device struct {
+	file *os.File
+}
+
+// Open opens an input device such as /dev/input/event0 and grabs it,
+// so what it types doesn't also reach the console or the desktop
+func Open(path string) (*Device, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Through the raw connection rather than Fd, which would stop read deadlines working
+	conn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	var grabErr error
+	err = conn.Control(func(fd uintptr) {
+		grabErr = unix.IoctlSetInt(int(fd), eviocgrab, 1)
+	})
+	if err == nil {
+		err = grabErr
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("grab %s: %w", path, err)
+	}
+	return &Device{file: file}, nil
+}
+
+// Close closes the device, which also lets it go
+func (d *Device) Close() error {
+	return d.file.Close()
+}
+
+// ReadKey waits until deadline for a key to be pressed, and returns its code
+// Releases, auto-repeats and other events are skipped; a zero deadline waits forever
+func (d *Device) ReadKey(deadline time.Time) (uint16, error) {
+	if err := d.file.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, unsafe.Sizeof(inputEvent{}))
+	for {
+		if _, err := io.ReadFull(d.file, buf); err != nil {
+			return 0, err
+		}
+		var ev inputEvent
+		if _, err := binary.Decode(buf, binary.NativeEndian, &ev); err != nil {
+			return 0, err
+		}
+		if ev.Type == evKey && ev.Value == keyPressed {
+			return ev.Code, nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package evdev
+
+import (
+	"fmt"
+	"time"
+)
+
+// Device is an input device opened for this program alone
+type Device struct{}
+
+// Open is only implemented on Linux, where the pump runs
+func Open(path string) (*Device, error) {
+	return nil, fmt.Errorf("input devices are only supported on Linux")
+}
+
+// Close closes the device
+func (d *Device) Close() error {
+	return nil
+}
+
+// ReadKey waits until deadline for a key to be pressed
+func (d *Device) ReadKey(time.Time) (uint16, error) {
+	return 0, fmt.Errorf("input devices are only supported on Linux")
+}
@@ -0,0 +1,17 @@
+package evdev
+
+// Key codes a card reader types (linux/input-event-codes.h), and the characters they stand for
+var keyChars = map[uint16]byte{
+	2: '1', 3: '2', 4: '3', 5: '4', 6: '5', 7: '6', 8: '7', 9: '8', 10: '9', 11: '0',
+	30: 'A', 48: 'B', 46: 'C', 32: 'D', 18: 'E', 33: 'F',
+	// Keypad
+	79: '1', 80: '2', 81: '3', 75: '4', 76: '5', 77: '6', 71: '7', 72: '8', 73: '9', 82: '0',
+	// Enter and keypad Enter end a line
+	28: '\n', 96: '\n',
+}
+
+// Char returns the character a key types, for digits, A to F and Enter ('\n')
+func Char(code uint16) (byte, bool) {
+	c, ok := keyChars[code]
+	return c, ok
+}
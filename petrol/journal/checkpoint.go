@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+// Checkpoint is the last saved state of a sale in progress
+type Checkpoint struct {
+	Pump   int            `json:"pump"`
+	Saved  time.Time      `json:"saved"`
+	Start  time.Time      `json:"start"`
+	Grade  string         `json:"grade"`
+	Volume pump.Volume    `json:"volume_ml"`
+	Price  pump.UnitPrice `json:"price_tenth_pence_per_litre"`
+	Amount pump.Money     `json:"amount_pence"`
+}
+
+// CheckpointFromSnapshot records pump pumpNumber's current sale
+func CheckpointFromSnapshot(pumpNumber int, snap pump.Snapshot, saved time.Time) Checkpoint {
+	return Checkpoint{
+		Pump:   pumpNumber,
+		Saved:  saved,
+		Start:  snap.Started,
+		Grade:  snap.Grade.Name,
+		Volume: snap.Volume,
+		Price:  snap.PricePerLitre,
+		Amount: snap.Amount,
+	}
+}
+
+// Sale returns the interrupted sale, ready for Engine.Resume
+func (c Checkpoint) Sale() pump.Sale {
+	return pump.Sale{
+		Start:         c.Start,
+		Grade:         c.Grade,
+		Volume:        c.Volume,
+		PricePerLitre: c.Price,
+		Amount:        c.Amount,
+	}
+}
+
+// Matches reports whether entry is the journaled end of this checkpoint's sale
+func (c Checkpoint) Matches(entry Entry) bool {
+	return entry.Pump == c.Pump && entry.Start.Equal(c.Start)
+}
+
+// Checkpoints keeps one checkpoint file per pump in a directory
+// Files are replaced atomically, so a power cut leaves either the old checkpoint or the new one
+type Checkpoints struct {
+	Dir string
+}
+
+// OpenCheckpoints uses dir for checkpoints, creating it if needed
+func OpenCheckpoints(dir string) (*Checkpoints, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("checkpoints: %w", err)
+	}
+	return &Checkpoints{Dir: dir}, nil
+}
+
+func (c *Checkpoints) path(pumpNumber int) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("pump-%d.checkpoint", pumpNumber))
+}
+
+// Save replaces the pump's checkpoint, waiting until it is on disk
+func (c *Checkpoints) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+
+	// Write a temporary file, flush it, then rename it over the old checkpoint
+	tmp := c.path(cp.Pump) + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path(cp.Pump)); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := syncDir(c.Dir); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Load returns the pump's checkpoint, if it has one
+func (c *Checkpoints) Load(pumpNumber int) (Checkpoint, bool, error) {
+	data, err := os.ReadFile(c.path(pumpNumber))
+	if os.IsNotExist(err) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("checkpoint %s: %w", c.path(pumpNumber), err)
+	}
+	return cp, true, nil
+}
+
+// Clear removes the pump's checkpoint once its sale has finished
+func (c *Checkpoints) Clear(pumpNumber int) error {
+	err := os.Remove(c.path(pumpNumber))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return syncDir(c.Dir)
+}
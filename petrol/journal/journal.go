@@ -0,0 +1,273 @@
+// Package journal keeps an append-only, crash-safe record of every sale.
+//
+// Each entry is one line: a CRC-32 of the JSON that follows, then the JSON
+// itself. Every append is fsync'd before it returns, so a sale that has been
+// journaled survives a power cut. A power cut in the middle of an append can
+// leave a torn last line; Open detects it by its missing newline or bad
+// checksum and truncates the file back to the last complete entry.
+//
+// Checkpoints hold the sale in progress on each pump, so a sale interrupted
+// by a restart can be resumed instead of lost.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+// Entry is one journaled sale
+type Entry struct {
+	Seq     uint64         `json:"seq"` // Transaction number, counting from 1
+	Pump    int            `json:"pump"`
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Grade   string         `json:"grade"`
+	Volume  pump.Volume    `json:"volume_ml"`
+	Price   pump.UnitPrice `json:"price_tenth_pence_per_litre"`
+	Amount  pump.Money     `json:"amount_pence"`
+	CardUID string         `json:"card_uid,omitempty"`
+	Outcome pump.Outcome   `json:"outcome"`
+}
+
+// FromSale builds the entry for a sale finished by pump number pumpNumber
+// Seq is filled in by Append
+func FromSale(pumpNumber int, sale pump.Sale) Entry {
+	return Entry{
+		Pump:    pumpNumber,
+		Start:   sale.Start,
+		End:     sale.End,
+		Grade:   sale.Grade,
+		Volume:  sale.Volume,
+		Price:   sale.PricePerLitre,
+		Amount:  sale.Amount,
+		CardUID: sale.CardUID,
+		Outcome: sale.Outcome,
+	}
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("#%d pump %d %s %s %s @ %s %s", e.Seq, e.Pump, e.Outcome, e.Grade, e.Volume, e.Price, e.Amount)
+}
+
+// journalFile is what the journal needs of its file; tests stand in for it to make writes fail
+type journalFile interface {
+	io.Writer
+	io.ReaderAt
+	io.Seeker
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// Journal is an open journal file
+type Journal struct {
+	mu     sync.Mutex
+	file   journalFile
+	seq    uint64        // Seq of the last entry written
+	last   map[int]Entry // Each pump's last entry
+	broken error         // Why the file can no longer be appended to safely
+}
+
+// Open opens (or creates) the journal at path, recovering from any torn write at its end
+func Open(path string) (*Journal, error) {
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+
+	entries, good, err := scan(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("journal %s: %w", path, err)
+	}
+
+	// Drop anything after the last complete entry
+	if info, err := file.Stat(); err == nil && info.Size() > good {
+		fmt.Printf("⚠ Journal %s: discarding %d bytes of incomplete entry\n", path, info.Size()-good)
+		if err := file.Truncate(good); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("journal %s: %w", path, err)
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("journal %s: %w", path, err)
+		}
+	}
+	if _, err := file.Seek(good, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("journal %s: %w", path, err)
+	}
+
+	// A new file only survives a power cut once its directory entry is on disk too
+	if created {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("journal %s: %w", path, err)
+		}
+	}
+
+	j := &Journal{file: file, last: make(map[int]Entry)}
+	for _, entry := range entries {
+		j.seq = entry.Seq
+		j.last[entry.Pump] = entry
+	}
+	return j, nil
+}
+
+// Append writes entry with the next transaction number and waits until it is on disk
+func (j *Journal) Append(entry Entry) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.broken != nil {
+		return entry, j.broken
+	}
+	entry.Seq = j.seq + 1
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("journal: %w", err)
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+
+	// A failed append must leave nothing behind: the next entry would land after a
+	// torn line, which Open could then only take for corruption in the middle of the file
+	end, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return entry, fmt.Errorf("journal: %w", err)
+	}
+	if _, err := j.file.Write([]byte(line)); err != nil {
+		return entry, j.rollback(end, err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return entry, j.rollback(end, err)
+	}
+	j.seq = entry.Seq
+	j.last[entry.Pump] = entry
+	return entry, nil
+}
+
+// rollback cuts the file back to end after a failed append
+// If even that fails, the journal refuses further appends rather than write after a torn line
+func (j *Journal) rollback(end int64, cause error) error {
+	err := j.file.Truncate(end)
+	if err == nil {
+		_, err = j.file.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		j.broken = fmt.Errorf("journal: unusable after failed append (%v): %w", cause, err)
+		return j.broken
+	}
+	return fmt.Errorf("journal: %w", cause)
+}
+
+// Last returns the most recent entry for pump pumpNumber
+func (j *Journal) Last(pumpNumber int) (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.last[pumpNumber]
+	return entry, ok
+}
+
+// Entries reads back every entry in the journal, oldest first
+func (j *Journal) Entries() ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Read through a separate section so the append position is left alone
+	info, err := j.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	entries, _, err := scan(io.NewSectionReader(j.file, 0, info.Size()))
+	return entries, err
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// scan reads complete entries from r, stopping at the first torn or corrupt line
+// good is the offset just after the last complete entry
+func scan(r io.Reader) (entries []Entry, good int64, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without its newline was cut short
+			return entries, good, nil
+		}
+		if err != nil {
+			return entries, good, err
+		}
+
+		entry, ok := parseLine(line)
+		if !ok {
+			// Only the last line can be torn by a power cut - a bad entry
+			// followed by good ones is corruption, and truncating would lose sales
+			if laterEntryIsValid(reader) {
+				return entries, good, fmt.Errorf("corrupt entry at offset %d", good)
+			}
+			return entries, good, nil
+		}
+		entries = append(entries, entry)
+		good += int64(len(line))
+	}
+}
+
+// laterEntryIsValid reports whether any complete line left in reader is a valid entry
+func laterEntryIsValid(reader *bufio.Reader) bool {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return false
+		}
+		if _, ok := parseLine(line); ok {
+			return true
+		}
+	}
+}
+
+// parseLine checks a line's checksum and decodes it
+func parseLine(line []byte) (Entry, bool) {
+	sum, data, found := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !found {
+		return Entry{}, false
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || crc32.ChecksumIEEE(data) != uint32(want) {
+		return Entry{}, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// syncDir flushes a directory so newly created files in it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,243 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+func testEntry(pumpNumber int, volume pump.Volume) Entry {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Entry{
+		Pump:    pumpNumber,
+		Start:   start,
+		End:     start.Add(time.Minute),
+		Grade:   "Unleaded",
+		Volume:  volume,
+		Price:   1459,
+		Amount:  pump.UnitPrice(1459).Cost(volume),
+		Outcome: pump.OutcomePaid,
+	}
+}
+
+// writeJournal appends n entries to a new journal and returns its path and raw contents
+func writeJournal(t *testing.T, n int) (string, []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sales.journal")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		if _, err := j.Append(testEntry(1+i%2, pump.Volume(i)*pump.Litre)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestAppendNumbersAndReopens(t *testing.T) {
+	path, _ := writeJournal(t, 3)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	entries, err := j.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			t.Errorf("entry %d has seq %d", i, e.Seq)
+		}
+	}
+	if last, ok := j.Last(2); !ok || last.Seq != 3 {
+		t.Errorf("Last(2) = %v, %v; want seq 3", last, ok)
+	}
+	entry, err := j.Append(testEntry(1, pump.Litre))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Seq != 4 {
+		t.Errorf("appended seq %d after reopening, want 4", entry.Seq)
+	}
+}
+
+func TestOpenRecovery(t *testing.T) {
+	_, good := writeJournal(t, 3)
+	lines := splitLines(good)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		entries int
+		wantErr bool
+	}{
+		{"empty", nil, 0, false},
+		{"complete", good, 3, false},
+		{"tail without newline", append(dup(good), lines[0][:20]...), 3, false},
+		{"tail cut before newline", dup(good[:len(good)-1]), 2, false},
+		{"tail with bad checksum", append(dup(good), corrupt(lines[0])...), 3, false},
+		{"garbage tail", append(dup(good), "not an entry\n"...), 3, false},
+		{"corrupt middle", concat(lines[0], corrupt(lines[1]), lines[2]), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sales.journal")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			j, err := Open(path)
+			if tt.wantErr {
+				if err == nil {
+					j.Close()
+					t.Fatal("Open succeeded on a corrupt journal")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer j.Close()
+
+			entries, err := j.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.entries {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.entries)
+			}
+			entry, err := j.Append(testEntry(1, pump.Litre))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Seq != uint64(tt.entries+1) {
+				t.Errorf("next seq %d, want %d", entry.Seq, tt.entries+1)
+			}
+			if entries, err := j.Entries(); err != nil || len(entries) != tt.entries+1 {
+				t.Errorf("after append: %d entries, %v", len(entries), err)
+			}
+		})
+	}
+}
+
+// failingFile writes only part of what it is given, or fails to sync, when told to
+type failingFile struct {
+	*os.File
+	failWrite bool
+	failSync  bool
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.File.Write(p)
+}
+
+func (f *failingFile) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func TestFailedAppendLeavesNothingBehind(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(f *failingFile)
+	}{
+		{"torn write", func(f *failingFile) { f.failWrite = true }},
+		{"failed sync", func(f *failingFile) { f.failSync = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := writeJournal(t, 2)
+			j, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := &failingFile{File: j.file.(*os.File)}
+			j.file = f
+
+			tt.inject(f)
+			if _, err := j.Append(testEntry(1, 5*pump.Litre)); !errors.Is(err, errInjected) {
+				t.Fatalf("Append = %v, want the injected error", err)
+			}
+			entry, err := j.Append(testEntry(2, 6*pump.Litre))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Seq != 3 {
+				t.Errorf("seq after failed append %d, want 3", entry.Seq)
+			}
+			j.Close()
+
+			j, err = Open(path)
+			if err != nil {
+				t.Fatalf("reopening after failed append: %v", err)
+			}
+			defer j.Close()
+			entries, err := j.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 3 || entries[2].Seq != 3 || entries[2].Volume != 6*pump.Litre {
+				t.Errorf("entries after reopening: %v", entries)
+			}
+		})
+	}
+}
+
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := 0
+		for data[i] != '\n' {
+			i++
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+func dup(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// corrupt flips a byte in a line's JSON, so its checksum no longer matches
+func corrupt(line []byte) []byte {
+	out := dup(line)
+	out[len(out)-3] ^= 0x01
+	return out
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
 	"math/rand"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -21,20 +27,38 @@ import (
 	"github.com/stianeikeland/go-rpio/v4"
 	"periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/spi"
 	"periph.io/x/conn/v3/spi/spireg"
 	"periph.io/x/devices/v3/mfrc522"
+	"periph.io/x/devices/v3/mfrc522/commands"
 	"periph.io/x/host/v3"
+
+	"petrol-pump/board"
+	"petrol-pump/emv"
+	"petrol-pump/journal"
+	"petrol-pump/ndef"
+	"petrol-pump/payment"
+	"petrol-pump/pn532"
+	"petrol-pump/pump"
+	"petrol-pump/pushbutton"
+	"petrol-pump/receipt"
 )
 
 const (
-	// GPIO pin 17 for button (BCM numbering)
-	buttonPin = 17
-
 	// Pump settings
-	minPricePerLitre = 1.40                 // Minimum currency per litre
-	maxPricePerLitre = 1.60                 // Maximum currency per litre
-	incrementRate    = 0.0015               // Litres added per increment
-	updateInterval   = 3 * time.Millisecond // How often to check button and update display
+	updateInterval = 10 * time.Millisecond // How often to check button and update display (flow depends on elapsed time, not ticks)
+
+	// How often idle pumps check their price source for scheduled or edited prices
+	priceRefreshInterval = 30 * time.Second
+
+	// How often a sale in progress is saved while pumping
+	checkpointInterval = 1 * time.Second
+
+	// How long the success screen waits for an answer to "Print receipt?"
+	receiptChoiceTimeout = 10 * time.Second
+
+	// How long to wait for the acquirer before showing the timeout screen
+	paymentTimeout = 10 * time.Second
 
 	// Splash screen settings
 	splashDuration = 3 * time.Second // How long to show splash screen
@@ -50,10 +74,15 @@ const (
 )
 
 var (
-	debugMode        = false
-	keyPressed       = false
-	lastKeyPressTime = time.Time{}
-	keyPressTimeout  = 150 * time.Millisecond // If no key press in this time, assume key is released
+	// The board, and the GPIO (BCM numbering) each pump button and the MFRC522 are wired to; set from -board and -pins
+	boardProfile board.Profile
+	boardPins    board.Pins
+
+	// Lines printed at the top of every receipt
+	receiptHeader = []string{"PETROL PUMP", "VAT receipt"}
+
+	debugMode       = false
+	keyPressTimeout = 150 * time.Millisecond // If no key press in this time, assume key is released
 
 	// Colors for petrol pump display
 	displayBg       = color.RGBA{R: 20, G: 20, B: 20, A: 255}
@@ -96,64 +125,307 @@ func (ct *customTheme) Font(style fyne.TextStyle) fyne.Resource {
 // RFIDReader is an interface for RFID card readers
 type RFIDReader interface {
 	IsCardPresent() (bool, error)
-	ReadCardID() (string, error)
+	// ReadCard describes the card on the reader: its full UID, ATQA, SAK and family
+	ReadCard() (Card, error)
 }
 
 // MockRFIDReader simulates an RFID reader for testing
 type MockRFIDReader struct {
 	cardPresent bool
-	cardID      string
+	card        Card
+	detected    bool // The card on the reader has been reported, as a halted card would be
+	taps        int  // Counts taps, so a card is only taken away by its own timer
+	mu          sync.Mutex
+	values      map[string]int32        // Value blocks on simulated MIFARE Classic cards, keyed by UID and block
+	messages    map[string]ndef.Message // NDEF messages on simulated tags, keyed by UID
+}
+
+// mockWalletCard is the card SimulateWalletTap taps, so one card can be topped up and then spent
+var mockWalletCard = newCard(cardIdentity{UID: []byte{0x5A, 0x11, 0xE7, 0x01}, ATQA: [2]byte{0x04, 0x00}, SAK: 0x08})
+
+// mockCards are the kinds of card SimulateTap pretends to read
+var mockCards = []struct {
+	uidLength int
+	atqa      [2]byte
+	sak       byte
+}{
+	{4, [2]byte{0x04, 0x00}, 0x08}, // MIFARE Classic 1K
+	{7, [2]byte{0x44, 0x00}, 0x00}, // NTAG215
+	{7, [2]byte{0x44, 0x03}, 0x20}, // DESFire EV1
+	{4, [2]byte{0x04, 0x00}, 0x20}, // Contactless bank card
 }
 
 func (m *MockRFIDReader) IsCardPresent() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.cardPresent, nil
 }
 
-func (m *MockRFIDReader) ReadCardID() (string, error) {
+func (m *MockRFIDReader) ReadCard() (Card, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.cardPresent {
-		return m.cardID, nil
+		return m.card, nil
 	}
-	return "", fmt.Errorf("no card present")
+	return Card{}, fmt.Errorf("no card present")
+}
+
+// CardEvents implements CardEventReader
+func (m *MockRFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, m)
+}
+
+func (m *MockRFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		card, present := m.card, m.cardPresent && !m.detected
+		m.detected = m.cardPresent
+		m.mu.Unlock()
+		if present {
+			return card, nil
+		}
+		if time.Now().After(deadline) {
+			return Card{}, errNoCard
+		}
+		time.Sleep(cardPollInterval)
+	}
+}
+
+func (m *MockRFIDReader) stillPresent(uid []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cardPresent && bytes.Equal(m.card.UID, uid)
 }
 
 func (m *MockRFIDReader) SimulateTap() {
-	// Generate a random card ID for simulation
-	m.cardID = fmt.Sprintf("%02X:%02X:%02X:%02X",
-		rand.Intn(256), rand.Intn(256), rand.Intn(256), rand.Intn(256))
+	// Generate a random card of a random kind for simulation
+	kind := mockCards[rand.Intn(len(mockCards))]
+	uid := make([]byte, kind.uidLength)
+	rand.Read(uid)
+	m.tap(newCard(cardIdentity{UID: uid, ATQA: kind.atqa, SAK: kind.sak}))
+}
+
+// mockSticker is the NTAG sticker SimulateStickerTap taps, which starts out with a customer profile on it
+var mockSticker = newCard(cardIdentity{UID: []byte{0x04, 0x5C, 0x21, 0x8A, 0x3F, 0x61, 0x80}, ATQA: [2]byte{0x44, 0x00}, SAK: 0x00})
+
+// SimulateStickerTap taps the simulated NFC sticker
+func (m *MockRFIDReader) SimulateStickerTap() {
+	m.mu.Lock()
+	if _, ok := m.messages[mockSticker.ID()]; !ok {
+		if m.messages == nil {
+			m.messages = make(map[string]ndef.Message)
+		}
+		m.messages[mockSticker.ID()] = ndef.Message{
+			ndef.NewExternal(tagCustomerType, []byte("Sam")),
+			ndef.NewExternal(tagVehicleType, []byte("Red hatchback")),
+		}
+	}
+	m.mu.Unlock()
+	m.tap(mockSticker)
+}
+
+// SimulateWalletTap taps the simulated wallet card
+func (m *MockRFIDReader) SimulateWalletTap() {
+	m.tap(mockWalletCard)
+}
+
+// SimulateBankCardTap taps a simulated contactless bank card
+func (m *MockRFIDReader) SimulateBankCardTap() {
+	uid := make([]byte, 4)
+	rand.Read(uid)
+	uid[0] = 0x08 // Random UID, as bank cards and phones give
+	m.tap(newCard(cardIdentity{UID: uid, ATQA: [2]byte{0x04, 0x00}, SAK: 0x20}))
+}
+
+func (m *MockRFIDReader) tap(card Card) {
+	m.mu.Lock()
+	m.card = card
 	m.cardPresent = true
+	m.detected = false
+	m.taps++
+	tap := m.taps
+	m.mu.Unlock()
 
-	// Auto-clear after a short time
+	// Auto-clear after a short time, unless another card has been tapped since
 	go func() {
 		time.Sleep(1 * time.Second)
-		m.cardPresent = false
+		m.mu.Lock()
+		if m.taps == tap {
+			m.cardPresent = false
+		}
+		m.mu.Unlock()
 	}()
 }
 
+// ReadValue implements ValueCardReader - any key opens a simulated card
+func (m *MockRFIDReader) ReadValue(card Card, key ClassicKey, block byte) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[mockValueKey(card, block)]
+	if !ok {
+		return 0, fmt.Errorf("block %d of %s: %w", block, card.ID(), errNotValueBlock)
+	}
+	return value, nil
+}
+
+// AddValue implements ValueCardReader
+func (m *MockRFIDReader) AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[mockValueKey(card, block)]
+	if !ok {
+		return 0, fmt.Errorf("block %d of %s: %w", block, card.ID(), errNotValueBlock)
+	}
+	value += delta
+	m.values[mockValueKey(card, block)] = value
+	return value, nil
+}
+
+// WriteValue implements ValueCardReader
+func (m *MockRFIDReader) WriteValue(card Card, key ClassicKey, block byte, value int32) error {
+	if err := checkValueBlock(block); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.values == nil {
+		m.values = make(map[string]int32)
+	}
+	m.values[mockValueKey(card, block)] = value
+	return nil
+}
+
+// ReadNDEF implements NDEFReader - simulated tags start out empty
+func (m *MockRFIDReader) ReadNDEF(card Card) (ndef.Message, error) {
+	if card.Family != CardUltralight {
+		return nil, errNotNDEF
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages[card.ID()], nil
+}
+
+// WriteNDEF implements NDEFReader
+func (m *MockRFIDReader) WriteNDEF(card Card, msg ndef.Message) error {
+	if card.Family != CardUltralight {
+		return errNotNDEF
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.messages == nil {
+		m.messages = make(map[string]ndef.Message)
+	}
+	m.messages[card.ID()] = msg
+	return nil
+}
+
+// APDUSession implements APDUReader - simulated bank cards are Visa or Mastercard test cards, by UID
+func (m *MockRFIDReader) APDUSession(card Card, fn func(t APDUTransceiver) error) error {
+	switch card.Family {
+	case CardISODEP:
+		if len(card.UID) > 0 && card.UID[len(card.UID)-1]%2 == 1 {
+			return fn(emv.MastercardTestCard)
+		}
+		return fn(emv.VisaTestCard)
+	case CardDESFire:
+		return fn(mockNoApplication{})
+	}
+	return fmt.Errorf("%s does not speak ISO-DEP", card.Family)
+}
+
+// mockNoApplication is an ISO-DEP card with no payment application, such as a DESFire transit card
+type mockNoApplication struct{}
+
+func (mockNoApplication) Transceive(apdu []byte) ([]byte, error) {
+	return []byte{0x6A, 0x82}, nil // File or application not found
+}
+
+func mockValueKey(card Card, block byte) string {
+	return fmt.Sprintf("%s/%d", card.ID(), block)
+}
+
 // MFRC522RFIDReader implements the RFIDReader interface for real MFRC522 hardware
 type MFRC522RFIDReader struct {
-	dev        *mfrc522.Dev
-	lastCardID string
-	lastSeen   time.Time
+	port     spi.PortCloser
+	dev      *mfrc522.Dev
+	picc     *iso14443a // Card activation over the device's register access
+	lastCard Card
+	lastSeen time.Time
+}
+
+// mfrc522Config is where an MFRC522 is wired and how it is set up
+type mfrc522Config struct {
+	bus, chip int // SPI bus and chip select; -1 for the driver's default
+	rst, irq  int // GPIO numbers
+	gain      int // Receiver gain, 0 (18 dB) to 7 (48 dB); -1 leaves the driver's setting
 }
 
-func NewMFRC522RFIDReader() (*MFRC522RFIDReader, error) {
+// mfrc522Options reads an MFRC522 reader's options: bus, cs, rst, irq and gain
+// rst and irq default to the board's pins
+func mfrc522Options(opts readerOptions, defaultGain int) (mfrc522Config, error) {
+	cfg := mfrc522Config{rst: boardPins.RST, irq: boardPins.IRQ}
+	var err error
+	if v, ok := opts["rst"]; ok {
+		if cfg.rst, err = board.ParsePin(v); err != nil {
+			return cfg, err
+		}
+	}
+	if v, ok := opts["irq"]; ok {
+		if cfg.irq, err = board.ParsePin(v); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.bus, err = opts.int("bus", -1); err != nil {
+		return cfg, err
+	}
+	if cfg.chip, err = opts.int("cs", -1); err != nil {
+		return cfg, err
+	}
+	if cfg.gain, err = opts.int("gain", defaultGain); err != nil {
+		return cfg, err
+	}
+	if cfg.gain < -1 || cfg.gain > 7 {
+		return cfg, fmt.Errorf("gain must be between 0 and 7")
+	}
+	return cfg, nil
+}
+
+// spiPins returns the header pins the reader's SPI bus takes over
+func (c mfrc522Config) spiPins() (map[int]string, error) {
+	return board.SPI(max(c.bus, 0), max(c.chip, 0))
+}
+
+// gpioNames are the names periph.io might know a GPIO by
+func gpioNames(n int) []string {
+	return []string{fmt.Sprintf("GPIO%d", n), strconv.Itoa(n), fmt.Sprintf("BCM%d", n)}
+}
+
+// spiPort names the SPI port for periph.io; empty picks the first one
+func (c mfrc522Config) spiPort() string {
+	if c.bus < 0 && c.chip < 0 {
+		return ""
+	}
+	return fmt.Sprintf("/dev/spidev%d.%d", max(c.bus, 0), max(c.chip, 0))
+}
+
+// NewMFRC522RFIDReader opens an MFRC522 through periph.io, waiting for cards on its IRQ line
+func NewMFRC522RFIDReader(cfg mfrc522Config) (*MFRC522RFIDReader, error) {
 	// Initialize periph.io host
 	if _, err := host.Init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize periph: %w", err)
 	}
 
 	// Open SPI port
-	port, err := spireg.Open("")
+	port, err := spireg.Open(cfg.spiPort())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SPI: %w", err)
 	}
 
-	// Get GPIO pins for RST and IRQ
-	// Try multiple common RST pins (GPIO22 is most common, then GPIO25)
+	// Get GPIO pins for RST and IRQ, from the board's pin mapping
 	var rstPin gpio.PinOut
-
-	// Try different pin references - GPIO22 (Pin 15) is the most common RST pin
-	for _, pinName := range []string{"GPIO22", "22", "BCM22", "GPIO25", "25", "BCM25"} {
+	rstNames := gpioNames(cfg.rst)
+	for _, pinName := range rstNames {
 		if pin := gpioreg.ByName(pinName); pin != nil {
 			rstPin = pin
 			fmt.Printf("  Found RST pin: %s\n", pinName)
@@ -167,12 +439,14 @@ func NewMFRC522RFIDReader() (*MFRC522RFIDReader, error) {
 		for _, pin := range gpioreg.All() {
 			fmt.Printf("    - %s\n", pin.Name())
 		}
-		return nil, fmt.Errorf("failed to find RST pin (tried GPIO22, 22, BCM22, GPIO25, 25, BCM25)")
+		port.Close()
+		return nil, fmt.Errorf("failed to find RST pin (tried %s)", strings.Join(rstNames, ", "))
 	}
 
 	// Get an IRQ pin and configure it for interrupt detection
 	var irqPin gpio.PinIn
-	for _, pinName := range []string{"GPIO24", "24", "BCM24"} {
+	irqNames := gpioNames(cfg.irq)
+	for _, pinName := range irqNames {
 		if pin := gpioreg.ByName(pinName); pin != nil {
 			irqPin = pin
 			fmt.Printf("  Found IRQ pin: %s\n", pinName)
@@ -191,28 +465,35 @@ func NewMFRC522RFIDReader() (*MFRC522RFIDReader, error) {
 	}
 
 	if irqPin == nil {
-		return nil, fmt.Errorf("could not find GPIO24 for IRQ pin")
+		port.Close()
+		return nil, fmt.Errorf("could not find %s for IRQ pin", irqNames[0])
 	}
 
 	// Create MFRC522 device with SPI port and pins
 	// Use WithSync() to enable interrupt-driven mode (now that IRQ is configured)
 	dev, err := mfrc522.NewSPI(port, rstPin, irqPin, mfrc522.WithSync())
 	if err != nil {
+		port.Close()
 		return nil, fmt.Errorf("failed to create MFRC522 device: %w", err)
 	}
 
 	fmt.Println("  ✓ Device created in interrupt mode (using IRQ for efficient detection)")
 
 	// Set antenna gain for better detection
-	fmt.Println("  Setting antenna gain...")
-	if err := dev.SetAntennaGain(7); err != nil {
-		fmt.Printf("  ⚠ Warning: Could not set antenna gain: %v\n", err)
-	} else {
-		fmt.Println("  ✓ Antenna gain set to maximum (7)")
-	}
-
 	reader := &MFRC522RFIDReader{
-		dev: dev,
+		port: port,
+		dev:  dev,
+		picc: &iso14443a{bus: periphBus{ll: dev.LowLevel}},
+	}
+	if cfg.gain >= 0 {
+		fmt.Println("  Setting antenna gain...")
+		if err := dev.SetAntennaGain(cfg.gain); err != nil {
+			fmt.Printf("  ⚠ Warning: Could not set antenna gain: %v\n", err)
+		} else if err := reader.picc.setAntennaGain(cfg.gain); err != nil {
+			fmt.Printf("  ⚠ Warning: Could not set antenna gain: %v\n", err)
+		} else {
+			fmt.Printf("  ✓ Antenna gain set to %d (of 7)\n", cfg.gain)
+		}
 	}
 
 	fmt.Println("  MFRC522 initialized - SPI communication OK ✓")
@@ -257,6 +538,7 @@ func NewMFRC522RFIDReader() (*MFRC522RFIDReader, error) {
 
 	// If we got IRQ timeout errors, return error to trigger fallback
 	if hasIRQError {
+		reader.Close()
 		return nil, fmt.Errorf("IRQ timeout - periph.io library requires working IRQ signal")
 	}
 
@@ -268,83 +550,159 @@ func NewMFRC522RFIDReader() (*MFRC522RFIDReader, error) {
 func (r *MFRC522RFIDReader) IsCardPresent() (bool, error) {
 	// Interrupt-driven detection with reasonable timeout
 	// IRQ will signal when card is present, so we can wait a bit
-	uid, err := r.dev.ReadUID(300 * time.Millisecond)
+	card, err := r.selectCard(cardDetectTimeout)
 	if err != nil {
 		// Ignore normal "no card" errors
-		errStr := err.Error()
-		if errStr != "timeout" && errStr != "no tag" &&
-			!strings.Contains(errStr, "timeout waiting for irq") {
+		if !isNoCard(err) {
 			// Log unexpected errors occasionally
 			if time.Since(r.lastSeen) > 5*time.Second {
-				fmt.Printf("DEBUG: ReadUID error: %v\n", err)
+				fmt.Printf("DEBUG: selectCard error: %v\n", err)
 				r.lastSeen = time.Now() // Use this to limit debug spam
 			}
 		}
 		return false, nil
 	}
 
-	if len(uid) > 0 {
-		// Card detected - store the ID
-		r.lastCardID = formatUID(uid)
-		r.lastSeen = time.Now()
-		fmt.Printf("✓✓✓ Card detected! UID length: %d bytes, ID: %s\n", len(uid), r.lastCardID)
-		return true, nil
-	}
-
-	return false, nil
+	// Card detected - store it
+	r.lastCard = card
+	r.lastSeen = time.Now()
+	fmt.Printf("✓✓✓ Card detected! UID length: %d bytes, card: %s\n", len(card.UID), card)
+	return true, nil
 }
 
-func (r *MFRC522RFIDReader) ReadCardID() (string, error) {
-	// Return the last seen card ID if it was recent
-	if time.Since(r.lastSeen) < 2*time.Second && r.lastCardID != "" {
-		return r.lastCardID, nil
+func (r *MFRC522RFIDReader) ReadCard() (Card, error) {
+	// Return the last seen card if it was recent
+	if time.Since(r.lastSeen) < 2*time.Second && len(r.lastCard.UID) > 0 {
+		return r.lastCard, nil
 	}
 
 	// Try to read card again - use reasonable timeout for interrupt mode
-	uid, err := r.dev.ReadUID(300 * time.Millisecond)
+	card, err := r.selectCard(cardDetectTimeout)
 	if err != nil {
-		return "", fmt.Errorf("failed to read card: %w", err)
+		return Card{}, fmt.Errorf("failed to read card: %w", err)
 	}
 
-	if len(uid) == 0 {
-		return "", fmt.Errorf("no card present")
+	r.lastCard = card
+	r.lastSeen = time.Now()
+	return card, nil
+}
+
+// Close powers the chip down and releases the SPI port
+// Called by the supervisor when the reader has stopped answering, so it doesn't wait for the reader lock
+func (r *MFRC522RFIDReader) Close() error {
+	haltErr := r.dev.Halt()
+	if err := r.port.Close(); err != nil {
+		return err
 	}
+	return haltErr
+}
 
-	r.lastCardID = formatUID(uid)
-	r.lastSeen = time.Now()
-	return r.lastCardID, nil
+// CardEvents implements CardEventReader, waiting on the IRQ line for new cards
+func (r *MFRC522RFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, r)
+}
+
+func (r *MFRC522RFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	return r.selectCard(timeout)
+}
+
+func (r *MFRC522RFIDReader) stillPresent(uid []byte) bool {
+	return r.picc.cardSession(uid, func() error { return nil }) == nil
 }
 
-// formatUID converts byte array to hex string format
-func formatUID(uid []byte) string {
-	parts := make([]string, len(uid))
-	for i, b := range uid {
-		parts[i] = fmt.Sprintf("%02X", b)
+// selectCard waits for the IRQ, then runs the full anticollision cascade
+// periph's own ReadUID stops at 7-byte UIDs and doesn't report the ATQA or SAK
+// The reader is held while waiting, as the wait reprograms it
+func (r *MFRC522RFIDReader) selectCard(timeout time.Duration) (Card, error) {
+	ll := r.dev.LowLevel
+	r.picc.mu.Lock()
+	defer r.picc.mu.Unlock()
+	if err := ll.WaitForEdge(timeout); err != nil {
+		// periph reports the IRQ not firing, which is an empty field, only in the error's text
+		if strings.Contains(err.Error(), "timeout waiting for IRQ edge") {
+			return Card{}, fmt.Errorf("%w: %v", errNoCard, err)
+		}
+		return Card{}, err
+	}
+	if err := ll.Init(); err != nil {
+		return Card{}, err
+	}
+	defer ll.ClearInterrupt()
+
+	id, err := r.picc.activate(piccReqA)
+	if err != nil {
+		return Card{}, err
 	}
-	return fmt.Sprintf("%s", parts[0]) + ":" + fmt.Sprintf("%s", parts[1]) + ":" +
-		fmt.Sprintf("%s", parts[2]) + ":" + fmt.Sprintf("%s", parts[3])
+	if err := r.picc.halt(); err != nil {
+		fmt.Printf("DEBUG: HLTA failed: %v\n", err)
+	}
+	return newCard(id), nil
+}
+
+// periphBus gives register access to the MFRC522 through periph's low-level driver
+type periphBus struct {
+	ll *commands.LowLevel
+}
+
+func (b periphBus) readRegister(reg byte) (byte, error) {
+	return b.ll.DevRead(int(reg))
 }
 
+func (b periphBus) writeRegister(reg, val byte) error {
+	return b.ll.DevWrite(int(reg), val)
+}
+
+// PetrolPump is the Fyne front-end for one pump position on the forecourt
+// It drives the engine from the button and touchscreen, and follows it as an observer
 type PetrolPump struct {
-	litres           float64
-	amount           float64
-	pricePerLitre    float64
-	button           rpio.Pin
+	number           int
+	engine           *pump.Engine
+	forecourt        *pump.Forecourt
+	button           pushbutton.Input // nil in debug mode
+	keyPressed       bool             // Debug mode: SPACE is held for this pump
+	lastKeyPressTime time.Time        // Debug mode: last SPACE key repeat
 	litresContainer  *fyne.Container
 	amountContainer  *fyne.Container
 	litresDigitTexts []*canvas.Text
 	amountDigitTexts []*canvas.Text
 	payButton        *PayButton
+	startButton      *PayButton
+	presetLabel      *canvas.Text
+	gradeLabel       *canvas.Text
 	rateLabel        *canvas.Text
-	rfidReader       RFIDReader
 	mockRFIDReader   *MockRFIDReader
-	rfidCheckTicker  *time.Ticker
-	onPaymentScreen  bool
-	isPumping        bool
+	journal          *journal.Journal // Gives receipts their transaction number (nil without a journal)
+	printer          receipt.Printer  // nil if receipts can't be printed
+	lastSale         pump.Sale        // The sale that finished most recently, for its receipt
+	payments         payment.Processor
+	wallet           payment.Processor // Pays from wallet cards' own balance (nil without wallet cards)
+	walletCards      *walletCards      // Tops up wallet cards (nil without wallet cards)
+	tags             NDEFReader        // Reads and writes NFC stickers (nil if the reader can't)
+	apdu             APDUReader        // Reads bank cards and phones (nil if the reader can't)
+	rfid             *readerSupervisor // Keeps the card reader running, and reports its health (nil without one)
+	readerStatus     *canvas.Text      // The payment screen's warning about the card reader
+	acceptingCards   atomic.Bool       // The payment or top-up screen is waiting for a card
+	topUpAmount      atomic.Int64      // Top-up screen: pence to add to the next card, 0 until an amount is chosen
+	paymentMu        sync.Mutex
+	paid             pump.Money    // Captured so far for the current sale
+	captures         []cardPayment // Payments taken for the current sale, refunded if it is cancelled
+	paymentSale      uint64        // Counts sales whose payments were settled or refunded, so a late capture can tell
 	window           fyne.Window
 	mainContent      *fyne.Container
 }
 
+// cardDetails is what the pump read from a card besides its UID, for the success screen
+type cardDetails struct {
+	profile tagProfile       // From an NFC sticker
+	app     *emv.Application // From a bank card or phone (nil if there was none)
+}
+
+// cardPayment is a payment taken for a sale and the processor that took it
+type cardPayment struct {
+	auth      payment.Authorisation
+	processor payment.Processor
+}
+
 // PayButton is a custom Bootstrap-style button widget for the touchscreen
 type PayButton struct {
 	background *canvas.Rectangle
@@ -354,84 +712,422 @@ type PayButton struct {
 	onTapped   func()
 }
 
-// generateRandomPrice returns a random price between min and max
-func generateRandomPrice() float64 {
-	// Generate random price between minPricePerLitre and maxPricePerLitre
-	// Round to 2 decimal places
-	randomPrice := minPricePerLitre + rand.Float64()*(maxPricePerLitre-minPricePerLitre)
-	return float64(int(randomPrice*100)) / 100
+// NewPetrolPump creates a display for a forecourt position and subscribes it to engine events
+func NewPetrolPump(forecourt *pump.Forecourt, pos *pump.Position) *PetrolPump {
+	p := &PetrolPump{
+		number:    pos.Number,
+		engine:    pos.Engine,
+		forecourt: forecourt,
+	}
+	pos.Subscribe(p.handleEngineEvent)
+	return p
 }
 
-func NewPetrolPump() *PetrolPump {
-	return &PetrolPump{
-		litres:        0.0,
-		amount:        0.0,
-		pricePerLitre: generateRandomPrice(),
+// handleEngineEvent keeps the display in step with the engine
+func (p *PetrolPump) handleEngineEvent(ev pump.Event) {
+	switch ev.Type {
+	case pump.EventStateChanged:
+		t := ev.Transition
+		fmt.Printf("ℹ Pump %d state: %s → %s (%s)\n", p.number, t.From, t.To, t.Reason)
+		// Entering idle returns to the main screen
+		if t.To == pump.StateIdle {
+			p.showMainScreen()
+		}
+		// The pay button is only usable while paused with something to pay
+		p.updatePayButton()
+		p.updatePresetDisplay(ev.Snapshot)
+	case pump.EventTotalsChanged:
+		p.updateGUIDisplay(ev.Snapshot)
+	case pump.EventPriceChanged:
+		p.updateRateLabel(ev.Snapshot.PricePerLitre)
+	case pump.EventGradeChanged:
+		p.updateGradeLabel(ev.Snapshot.Grade)
+	case pump.EventLockChanged, pump.EventTanksChanged:
+		p.updatePresetDisplay(ev.Snapshot)
+	case pump.EventSaleFinished:
+		p.lastSale = ev.Sale
+		p.paymentMu.Lock()
+		p.paid = 0
+		p.captures = nil
+		p.paymentSale++
+		p.paymentMu.Unlock()
 	}
 }
 
-func (p *PetrolPump) increment() {
-	p.litres += incrementRate
-	p.amount = p.litres * p.pricePerLitre
-	p.isPumping = true
-	p.updateGUIDisplay()
+// reset abandons the current sale (if any) and returns the pump to idle
+func (p *PetrolPump) reset() {
+	p.acceptingCards.Store(false)
+	p.refundPayments()
+	if err := p.engine.Reset(); err != nil {
+		fmt.Printf("⚠ Cannot reset: %v\n", err)
+	}
 }
 
-func (p *PetrolPump) reset() {
-	p.litres = 0.0
-	p.amount = 0.0
-	p.isPumping = false
-	// Generate new random price on reset
-	p.pricePerLitre = generateRandomPrice()
-	// Update rate label if it exists
+// showMainScreen switches the window back to the litres/amount display
+func (p *PetrolPump) showMainScreen() {
+	if p.window == nil || p.mainContent == nil {
+		return
+	}
+	mainBg := canvas.NewRectangle(displayBg)
+	p.window.SetContent(container.NewStack(mainBg, p.mainContent))
+}
+
+// updateRateLabel shows the price per litre in the header
+func (p *PetrolPump) updateRateLabel(pricePerLitre pump.UnitPrice) {
 	if p.rateLabel != nil {
-		p.rateLabel.Text = fmt.Sprintf("£%.2f/L", p.pricePerLitre)
+		p.rateLabel.Text = pricePerLitre.String()
 		p.rateLabel.Refresh()
 	}
-	p.updateGUIDisplay()
 }
 
-func (p *PetrolPump) stopPumping() {
-	p.isPumping = false
-	p.updatePayButton()
+// updateGradeLabel shows the selected grade in the header
+func (p *PetrolPump) updateGradeLabel(grade pump.Grade) {
+	if p.gradeLabel != nil {
+		p.gradeLabel.Text = strings.ToUpper(grade.Name)
+		p.gradeLabel.Color = grade.Colour
+		p.gradeLabel.Refresh()
+	}
 }
 
-func (p *PetrolPump) updateGUIDisplay() {
+func (p *PetrolPump) updateGUIDisplay(snap pump.Snapshot) {
 	// Update multi-color digit displays
 	if p.litresDigitTexts != nil {
-		litresText := fmt.Sprintf("%06.2f", p.litres)
+		litresText := padDigits(snap.Volume.Litres(), 6)
 		updateMultiColorDigitDisplay(litresText, displayWhite, 120, p.litresDigitTexts)
 	}
 	if p.amountDigitTexts != nil {
-		amountText := fmt.Sprintf("%06.2f", p.amount)
+		amountText := padDigits(snap.Amount.Pounds(), 6)
 		updateMultiColorDigitDisplay(amountText, displayWhite, 120, p.amountDigitTexts)
 	}
-	p.updatePayButton()
 }
 
 func (p *PetrolPump) updatePayButton() {
 	if p.payButton != nil {
-		// Enable button only if not pumping and there's an amount to pay
-		shouldEnable := !p.isPumping && p.amount > 0
+		// Enable button only when paused and there's an amount to pay
+		shouldEnable := p.engine.CanPay()
 		p.payButton.SetEnabled(shouldEnable)
 	}
 }
 
+// updatePresetDisplay shows the chosen preset and only allows a new sale to be started while idle
+func (p *PetrolPump) updatePresetDisplay(snap pump.Snapshot) {
+	if p.startButton != nil {
+		p.startButton.SetEnabled(snap.State == pump.StateIdle && !snap.Locked && !snap.OutOfService)
+	}
+	if p.presetLabel != nil {
+		p.presetLabel.Color = color.Black
+		if snap.OutOfService && snap.State == pump.StateIdle {
+			p.presetLabel.Text = "OUT OF SERVICE"
+			p.presetLabel.Color = displayRed
+		} else if snap.Locked && snap.State == pump.StateIdle {
+			p.presetLabel.Text = "PUMP LOCKED"
+		} else if snap.Preset.IsSet() {
+			p.presetLabel.Text = "PRESET " + snap.Preset.String()
+		} else {
+			p.presetLabel.Text = ""
+		}
+		p.presetLabel.Refresh()
+	}
+}
+
+// presetChoices are the presets offered on the preset screen
+var presetChoices = []pump.Preset{
+	pump.PresetByAmount(5 * pump.Pound),
+	pump.PresetByAmount(10 * pump.Pound),
+	pump.PresetByAmount(20 * pump.Pound),
+	pump.PresetByAmount(50 * pump.Pound),
+	pump.PresetByVolume(5 * pump.Litre),
+	pump.PresetByVolume(10 * pump.Litre),
+	pump.PresetByVolume(20 * pump.Litre),
+	pump.NoPreset,
+}
+
+// showGradeScreen lets the customer choose a fuel grade, then moves on to the preset screen
+func (p *PetrolPump) showGradeScreen() {
+	if p.engine.State() != pump.StateIdle {
+		return
+	}
+
+	bg := canvas.NewRectangle(displayBg)
+	header := screenHeader("PETROL", color.Black)
+
+	titleText := canvas.NewText("Choose your fuel", displayWhite)
+	titleText.TextSize = 60
+	titleText.Alignment = fyne.TextAlignCenter
+
+	// One large tile per grade in the grade's colour, with its current price
+	// Grades whose tank is low are shown but can't be chosen
+	var tiles []fyne.CanvasObject
+	for _, info := range p.engine.Grades() {
+		name := info.Name
+		tileBg := canvas.NewRectangle(info.Colour)
+		label := fmt.Sprintf("%s\n%s", name, info.Price)
+		if info.OutOfService {
+			label = fmt.Sprintf("%s\nOut of service", name)
+		}
+		btn := widget.NewButton(label, func() {
+			if err := p.engine.SelectGrade(name); err != nil {
+				fmt.Printf("⚠ Cannot select grade: %v\n", err)
+				p.showMainScreen()
+				return
+			}
+			p.showPresetScreen()
+		})
+		btn.Importance = widget.LowImportance
+		if info.OutOfService {
+			btn.Disable()
+		}
+		tiles = append(tiles, container.NewGridWrap(fyne.NewSize(220, 160), container.NewStack(tileBg, btn)))
+	}
+
+	// Cancel button
+	cancelButton := widget.NewButton("Cancel", func() {
+		p.showMainScreen()
+	})
+	cancelButton.Importance = widget.HighImportance
+
+	// Layout
+	content := container.NewBorder(
+		header, // Top
+		container.NewPadded(container.NewCenter(cancelButton)), // Bottom
+		nil, // Left
+		nil, // Right
+		// Center
+		container.NewVBox(
+			layout.NewSpacer(),
+			container.NewCenter(titleText),
+			layout.NewSpacer(),
+			container.NewCenter(container.NewGridWithColumns(len(tiles), tiles...)),
+			layout.NewSpacer(),
+		),
+	)
+
+	p.window.SetContent(container.NewStack(bg, content))
+}
+
+// showPresetScreen lets the customer choose an amount or volume before pumping
+func (p *PetrolPump) showPresetScreen() {
+	if p.engine.State() != pump.StateIdle {
+		return
+	}
+
+	bg := canvas.NewRectangle(displayBg)
+
+	// Header with white background (same style as main screen) showing the grade
+	snap := p.engine.Snapshot()
+	header := screenHeader(strings.ToUpper(snap.Grade.Name), snap.Grade.Colour)
+
+	titleText := canvas.NewText("Choose a preset", displayWhite)
+	titleText.TextSize = 60
+	titleText.Alignment = fyne.TextAlignCenter
+
+	// One large touch button per preset
+	var buttons []fyne.CanvasObject
+	for _, preset := range presetChoices {
+		preset := preset
+		btn := widget.NewButton(preset.String(), func() {
+			if err := p.engine.Authorise(preset); err != nil {
+				fmt.Printf("⚠ Cannot authorise preset: %v\n", err)
+			}
+			p.showMainScreen()
+		})
+		btn.Importance = widget.HighImportance
+		buttons = append(buttons, container.NewGridWrap(fyne.NewSize(220, 90), btn))
+	}
+
+	// Cancel button
+	cancelButton := widget.NewButton("Cancel", func() {
+		p.showMainScreen()
+	})
+	cancelButton.Importance = widget.HighImportance
+
+	// Layout
+	content := container.NewBorder(
+		header, // Top
+		container.NewPadded(container.NewCenter(cancelButton)), // Bottom
+		nil, // Left
+		nil, // Right
+		// Center
+		container.NewVBox(
+			layout.NewSpacer(),
+			container.NewCenter(titleText),
+			layout.NewSpacer(),
+			container.NewCenter(container.NewGridWithColumns(4, buttons...)),
+			layout.NewSpacer(),
+		),
+	)
+
+	p.window.SetContent(container.NewStack(bg, content))
+}
+
+// tankerDelivery is the volume added by each delivery button on the tank screen
+const tankerDelivery = 1000 * pump.Litre
+
+// showTankScreen is the attendant's tanker delivery screen: every tank's level, with buttons to refill it
+func (p *PetrolPump) showTankScreen() {
+	tanks := p.forecourt.Tanks()
+	if tanks == nil {
+		fmt.Println("ℹ No storage tanks configured")
+		return
+	}
+
+	bg := canvas.NewRectangle(displayBg)
+	header := screenHeader("TANKER DELIVERY", color.Black)
+
+	// One row per tank: level, low-level warning and delivery buttons
+	rows := container.NewVBox()
+	for _, tank := range tanks.All() {
+		grade := tank.Grade
+		level := createBasicText(fmt.Sprintf("%-10s %s of %s", tank.Grade, tank.Level, tank.Capacity), displayWhite, 36)
+		if tank.Low() {
+			level.Text += "  OUT OF SERVICE"
+			level.Color = displayRed
+		}
+
+		deliverButton := widget.NewButton("+"+tankerDelivery.String(), func() {
+			if _, err := tanks.Deliver(grade, tankerDelivery); err != nil {
+				fmt.Printf("⚠ Delivery failed: %v\n", err)
+			}
+			p.showTankScreen()
+		})
+		fillButton := widget.NewButton("Fill", func() {
+			if t, ok := tanks.Tank(grade); ok && t.Level < t.Capacity {
+				if _, err := tanks.Deliver(grade, t.Capacity-t.Level); err != nil {
+					fmt.Printf("⚠ Delivery failed: %v\n", err)
+				}
+			}
+			p.showTankScreen()
+		})
+		deliverButton.Importance = widget.HighImportance
+		fillButton.Importance = widget.HighImportance
+
+		rows.Add(container.NewHBox(level, layout.NewSpacer(), deliverButton, fillButton))
+	}
+
+	// Back button
+	backButton := widget.NewButton("Back", func() {
+		p.showMainScreen()
+	})
+	backButton.Importance = widget.HighImportance
+
+	// Layout
+	content := container.NewBorder(
+		header, // Top
+		container.NewPadded(container.NewCenter(backButton)), // Bottom
+		nil, // Left
+		nil, // Right
+		// Center
+		container.NewVBox(
+			layout.NewSpacer(),
+			container.NewCenter(container.NewPadded(rows)),
+			layout.NewSpacer(),
+		),
+	)
+
+	p.window.SetContent(container.NewStack(bg, content))
+}
+
+// topUpChoices are the amounts the attendant can add to a wallet card
+var topUpChoices = []pump.Money{5 * pump.Pound, 10 * pump.Pound, 20 * pump.Pound}
+
+// showTopUpScreen is the attendant's wallet card top-up screen
+// Choosing an amount waits for a card; status reports what happened to the last one
+func (p *PetrolPump) showTopUpScreen(status string, statusColour color.Color) {
+	if p.walletCards == nil {
+		fmt.Println("ℹ Wallet cards are not enabled")
+		return
+	}
+
+	bg := canvas.NewRectangle(displayBg)
+	header := screenHeader("TOP UP CARD", color.Black)
+
+	amount := pump.Money(p.topUpAmount.Load())
+	prompt := "Choose an amount to add"
+	if amount > 0 {
+		prompt = fmt.Sprintf("Tap the card to add %s", amount)
+	}
+	promptText := canvas.NewText(prompt, displayWhite)
+	promptText.TextSize = 50
+	promptText.Alignment = fyne.TextAlignCenter
+
+	statusText := canvas.NewText(status, statusColour)
+	statusText.TextSize = 30
+	statusText.Alignment = fyne.TextAlignCenter
+
+	// One button per amount
+	amounts := container.NewHBox()
+	for _, choice := range topUpChoices {
+		choice := choice
+		button := widget.NewButton("+"+choice.String(), func() {
+			p.topUpAmount.Store(int64(choice))
+			p.acceptingCards.Store(true)
+			p.showTopUpScreen("", displayWhite)
+		})
+		button.Importance = widget.HighImportance
+		amounts.Add(button)
+	}
+
+	// Back button
+	backButton := widget.NewButton("Back", func() {
+		p.acceptingCards.Store(false)
+		p.topUpAmount.Store(0)
+		p.showMainScreen()
+	})
+	backButton.Importance = widget.HighImportance
+
+	// Layout
+	content := container.NewBorder(
+		header, // Top
+		container.NewPadded(container.NewCenter(backButton)), // Bottom
+		nil, // Left
+		nil, // Right
+		// Center
+		container.NewVBox(
+			layout.NewSpacer(),
+			container.NewCenter(promptText),
+			layout.NewSpacer(),
+			container.NewCenter(container.NewPadded(amounts)),
+			container.NewCenter(statusText),
+			layout.NewSpacer(),
+		),
+	)
+
+	p.window.SetContent(container.NewStack(bg, content))
+}
+
+// topUpCard adds amount to a tapped wallet card, then waits for the next card
+func (p *PetrolPump) topUpCard(card Card, amount pump.Money) {
+	p.showTopUpScreen("Topping up...", displayWhite)
+
+	balance, err := p.walletCards.TopUp(card, amount)
+	if err != nil {
+		fmt.Printf("✗ Top-up of card %s failed: %v\n", card.ID(), err)
+		p.acceptingCards.Store(true)
+		p.showTopUpScreen(fmt.Sprintf("✗ Card %s not topped up - try again", card.ID()), displayRed)
+		return
+	}
+	fmt.Printf("✓ Card %s topped up by %s, balance %s\n", card.ID(), amount, balance)
+	p.topUpAmount.Store(0)
+	p.showTopUpScreen(fmt.Sprintf("✓ Card %s balance %s", card.ID(), balance), displayAmber)
+}
+
+// showPaymentScreen asks for a card for whatever is still due on the sale
 func (p *PetrolPump) showPaymentScreen() {
-	// Set flag that we're on payment screen
-	p.onPaymentScreen = true
+	// Move to the payment state - rejected if the sale isn't paused with an amount due
+	// (coming back to try another card, the sale is already waiting for payment)
+	if p.engine.State() != pump.StateAwaitingPayment {
+		if err := p.engine.RequestPayment(); err != nil {
+			fmt.Printf("⚠ Cannot show payment screen: %v\n", err)
+			return
+		}
+	}
 
 	// Create payment screen background
 	bg := canvas.NewRectangle(displayBg)
 
-	// Header with white background (same style as main screen)
-	headerBg := canvas.NewRectangle(color.White)
-	petrolLabel := canvas.NewText("PETROL", color.Black)
-	petrolLabel.TextSize = 50
-	petrolLabel.Alignment = fyne.TextAlignCenter
-
-	headerContent := container.NewCenter(petrolLabel)
-	header := container.NewStack(headerBg, container.NewPadded(headerContent))
+	// Header with white background (same style as main screen) showing the grade
+	snap := p.engine.Snapshot()
+	header := screenHeader(strings.ToUpper(snap.Grade.Name), snap.Grade.Colour)
 
 	// Payment instruction text
 	paymentText := canvas.NewText("Tap the contactless", displayWhite)
@@ -444,24 +1140,31 @@ func (p *PetrolPump) showPaymentScreen() {
 	rfidText.Alignment = fyne.TextAlignCenter
 	rfidText.TextStyle = fyne.TextStyle{Bold: false}
 
-	// Amount to pay
-	amountText := canvas.NewText(fmt.Sprintf("£%.2f", p.amount), displayWhite)
+	// Amount to pay - less anything already taken from a partially approved card
+	paid := p.amountPaid()
+	amountText := canvas.NewText((snap.Amount - paid).String(), displayWhite)
 	amountText.TextSize = 100
 	amountText.Alignment = fyne.TextAlignCenter
 	amountText.TextStyle = fyne.TextStyle{Bold: true}
 
+	// What the customer is paying for
+	fuelLine := fmt.Sprintf("%s  %s @ %s", snap.Grade.Name, snap.Volume, snap.PricePerLitre)
+	if paid > 0 {
+		fuelLine = fmt.Sprintf("%s of %s paid - tap another card for the rest", paid, snap.Amount)
+	}
+	fuelText := canvas.NewText(fuelLine, displayWhite)
+	fuelText.TextSize = 30
+	fuelText.Alignment = fyne.TextAlignCenter
+
+	// Warning if the card reader isn't working properly
+	p.readerStatus = canvas.NewText("", displayAmber)
+	p.readerStatus.TextSize = 30
+	p.readerStatus.Alignment = fyne.TextAlignCenter
+	p.showReaderHealth()
+
 	// Cancel button
 	cancelButton := widget.NewButton("Cancel", func() {
-		// Stop checking for RFID
-		p.onPaymentScreen = false
-		// Go back to main screen
-		mainBg := canvas.NewRectangle(displayBg)
-		p.window.SetContent(container.NewStack(mainBg, p.mainContent))
-		// Reset the pump after a short delay to allow transition
-		go func() {
-			time.Sleep(100 * time.Millisecond)
-			p.reset()
-		}()
+		p.cancelPayment()
 	})
 	cancelButton.Importance = widget.HighImportance
 
@@ -478,7 +1181,287 @@ func (p *PetrolPump) showPaymentScreen() {
 			container.NewCenter(rfidText),
 			layout.NewSpacer(),
 			container.NewCenter(amountText),
+			container.NewCenter(fuelText),
 			layout.NewSpacer(),
+			container.NewCenter(p.readerStatus),
+			layout.NewSpacer(),
+		),
+	)
+
+	p.window.SetContent(container.NewStack(bg, content))
+	p.acceptingCards.Store(true)
+}
+
+// showReaderHealth updates the payment screen's card reader warning
+func (p *PetrolPump) showReaderHealth() {
+	if p.readerStatus == nil || p.rfid == nil {
+		return
+	}
+	switch p.rfid.Health() {
+	case HealthOK:
+		p.readerStatus.Text = ""
+	case HealthDegraded:
+		p.readerStatus.Text = "Card reader having trouble - keep the card still"
+		p.readerStatus.Color = displayAmber
+	default:
+		p.readerStatus.Text = "Card reader offline - please see the attendant"
+		p.readerStatus.Color = displayRed
+	}
+	p.readerStatus.Refresh()
+}
+
+// amountPaid returns how much has been captured for the current sale
+func (p *PetrolPump) amountPaid() pump.Money {
+	p.paymentMu.Lock()
+	defer p.paymentMu.Unlock()
+	return p.paid
+}
+
+// cancelPayment abandons the sale from the payment stage, refunding anything already taken
+func (p *PetrolPump) cancelPayment() {
+	p.acceptingCards.Store(false)
+	p.refundPayments()
+
+	// Leaving the payment state stops the RFID checks
+	if err := p.engine.CancelPayment(); err != nil {
+		fmt.Printf("⚠ Cannot cancel payment: %v\n", err)
+		return
+	}
+	// Reset the pump after a short delay - entering idle goes back to the main screen
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.reset()
+	}()
+}
+
+// refundPayments gives back every payment captured for the current sale
+func (p *PetrolPump) refundPayments() {
+	p.paymentMu.Lock()
+	captures := p.captures
+	p.captures = nil
+	p.paid = 0
+	p.paymentSale++
+	p.paymentMu.Unlock()
+
+	for _, c := range captures {
+		p.refund(c)
+	}
+}
+
+// refund gives back one captured payment
+func (p *PetrolPump) refund(c cardPayment) {
+	auth := c.auth
+	ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+	defer cancel()
+	if err := c.processor.Refund(ctx, auth, auth.Approved); err != nil {
+		fmt.Printf("✗ Pump %d: refund of %s failed: %v\n", p.number, auth, err)
+		return
+	}
+	fmt.Printf("ℹ Pump %d: refunded %s to card %s\n", p.number, auth.Approved, auth.CardUID)
+}
+
+// handleCardTap takes a tapped card through the payment processor, or tops it up on the top-up screen
+// Approval completes the sale; a partial approval asks for another card for the rest;
+// a decline, timeout or low wallet balance gets its own screen with retry and cancel
+func (p *PetrolPump) handleCardTap(card Card) {
+	if !p.acceptingCards.CompareAndSwap(true, false) {
+		return
+	}
+	if amount := pump.Money(p.topUpAmount.Load()); amount > 0 {
+		go p.topUpCard(card, amount)
+		return
+	}
+	cardUID := card.ID()
+	p.showPaymentMessage("Authorising...", displayWhite, "Please wait", nil)
+
+	// The customer can cancel, or the pump be reset, while the bank is still answering
+	p.paymentMu.Lock()
+	sale := p.paymentSale
+	p.paymentMu.Unlock()
+
+	go func() {
+		profile, tagMessage, tagRead := p.readTag(card)
+
+		due := p.engine.Snapshot().Amount - p.amountPaid()
+		details := cardDetails{profile: profile, app: p.readPaymentApp(card, due)}
+		ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+		defer cancel()
+
+		processor := p.processorFor(card)
+		auth, err := processor.Authorise(ctx, cardUID, due)
+		if errors.Is(err, payment.ErrNoWallet) {
+			// A MIFARE Classic card without a wallet on it pays through the acquirer like any other
+			processor = p.payments
+			auth, err = processor.Authorise(ctx, cardUID, due)
+		}
+		if err == nil {
+			if err = processor.Capture(ctx, auth, auth.Approved); err != nil {
+				if voidErr := processor.Void(ctx, auth); voidErr != nil {
+					fmt.Printf("⚠ Pump %d: void of %s failed: %v\n", p.number, auth.ID, voidErr)
+				}
+			}
+		}
+
+		var balanceErr *payment.BalanceError
+		switch {
+		case errors.As(err, &balanceErr):
+			fmt.Printf("✗ Pump %d: card %s: %v\n", p.number, cardUID, err)
+			detail := fmt.Sprintf("Card balance %s - top up or try another card", balanceErr.Balance)
+			p.showPaymentMessage("Insufficient funds", displayRed, detail, p.showPaymentScreen)
+			return
+		case errors.Is(err, payment.ErrDeclined):
+			fmt.Printf("✗ Pump %d: card %s declined: %v\n", p.number, cardUID, err)
+			p.showPaymentMessage("Card declined", displayRed, "Try another card, or cancel", p.showPaymentScreen)
+			return
+		case errors.Is(err, payment.ErrTimeout):
+			fmt.Printf("✗ Pump %d: payment timed out: %v\n", p.number, err)
+			p.showPaymentMessage("No response from bank", displayAmber, "Retry with the same card, or cancel", func() {
+				p.acceptingCards.Store(true)
+				p.handleCardTap(card)
+			})
+			return
+		case err != nil:
+			fmt.Printf("✗ Pump %d: payment failed: %v\n", p.number, err)
+			p.showPaymentMessage("Payment failed", displayRed, "Try another card, or cancel", p.showPaymentScreen)
+			return
+		}
+
+		captured := cardPayment{auth: auth, processor: processor}
+		p.paymentMu.Lock()
+		if p.paymentSale != sale {
+			p.paymentMu.Unlock()
+			fmt.Printf("⚠ Pump %d: sale ended while card %s was paying\n", p.number, cardUID)
+			p.refund(captured)
+			return
+		}
+		p.paid += auth.Approved
+		p.captures = append(p.captures, captured)
+		paid := p.paid
+		p.paymentMu.Unlock()
+		fmt.Printf("✓ Pump %d: %s\n", p.number, auth)
+
+		if paid < p.engine.Snapshot().Amount {
+			// Partially approved - ask for another card for the rest
+			p.showPaymentScreen()
+			return
+		}
+		p.handlePaymentSuccess(cardUID, details)
+		if tagRead {
+			p.writeLastFill(card, tagMessage)
+		}
+	}()
+}
+
+// readTag reads the profile on an NFC sticker, if the card is one and the reader can read it
+// The whole message is returned too, so the last fill can be written back without losing anything;
+// ok is false if there was no message to read, and then nothing should be written
+func (p *PetrolPump) readTag(card Card) (profile tagProfile, msg ndef.Message, ok bool) {
+	if p.tags == nil || card.Family != CardUltralight || !readerCan[NDEFReader](p.tags) {
+		return tagProfile{}, nil, false
+	}
+	msg, err := p.tags.ReadNDEF(card)
+	if err != nil {
+		fmt.Printf("⚠ Pump %d: cannot read tag %s: %v\n", p.number, card.ID(), err)
+		return tagProfile{}, nil, false
+	}
+	profile = profileFromMessage(msg)
+	fmt.Printf("🏷 Pump %d: tag %s: %s\n", p.number, card.ID(), profile)
+	for _, r := range msg {
+		fmt.Printf("    %s\n", r)
+	}
+	return profile, msg, true
+}
+
+// writeLastFill records the sale just paid on the NFC sticker that paid for it
+func (p *PetrolPump) writeLastFill(card Card, msg ndef.Message) {
+	if p.lastSale.CardUID != card.ID() {
+		return
+	}
+	record, err := lastFillRecord(p.number, p.lastSale)
+	if err == nil {
+		err = p.tags.WriteNDEF(card, msg.Replace(record))
+	}
+	if err != nil {
+		fmt.Printf("⚠ Pump %d: cannot write last fill to tag %s: %v\n", p.number, card.ID(), err)
+		return
+	}
+	fmt.Printf("🏷 Pump %d: last fill written to tag %s\n", p.number, card.ID())
+}
+
+// readPaymentApp reads the payment application on a bank card or phone, if the reader can talk to it
+// Failing to read it doesn't stop the payment; the success screen just shows the UID instead
+func (p *PetrolPump) readPaymentApp(card Card, due pump.Money) *emv.Application {
+	if p.apdu == nil || (card.Family != CardISODEP && card.Family != CardDESFire) || !readerCan[APDUReader](p.apdu) {
+		return nil
+	}
+	var app emv.Application
+	err := p.apdu.APDUSession(card, func(t APDUTransceiver) error {
+		var err error
+		app, err = emv.Read(t, due)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("ℹ Pump %d: no payment application read from %s: %v\n", p.number, card.ID(), err)
+		return nil
+	}
+	fmt.Printf("💳 Pump %d: %s (%s, expires %s)\n", p.number, app, app.Label, app.Expiry)
+	return &app
+}
+
+// processorFor picks how a card pays: MIFARE Classic cards from their wallet, anything else through the acquirer
+func (p *PetrolPump) processorFor(card Card) payment.Processor {
+	if p.wallet != nil && (card.Family == CardMifareClassic1K || card.Family == CardMifareClassic4K) &&
+		readerCan[ValueCardReader](p.walletCards.reader) {
+		return p.wallet
+	}
+	return p.payments
+}
+
+// showPaymentMessage shows a payment status screen
+// With a retry function the screen offers Retry and Cancel; without one it just waits
+func (p *PetrolPump) showPaymentMessage(title string, titleColour color.Color, detail string, retry func()) {
+	bg := canvas.NewRectangle(displayBg)
+
+	snap := p.engine.Snapshot()
+	header := screenHeader(strings.ToUpper(snap.Grade.Name), snap.Grade.Colour)
+
+	titleText := canvas.NewText(title, titleColour)
+	titleText.TextSize = 70
+	titleText.Alignment = fyne.TextAlignCenter
+	titleText.TextStyle = fyne.TextStyle{Bold: true}
+
+	detailText := canvas.NewText(detail, displayWhite)
+	detailText.TextSize = 30
+	detailText.Alignment = fyne.TextAlignCenter
+
+	amountText := canvas.NewText((snap.Amount - p.amountPaid()).String(), displayWhite)
+	amountText.TextSize = 80
+	amountText.Alignment = fyne.TextAlignCenter
+
+	var bottom fyne.CanvasObject
+	if retry != nil {
+		retryButton := widget.NewButton("Retry", retry)
+		cancelButton := widget.NewButton("Cancel", func() {
+			p.cancelPayment()
+		})
+		retryButton.Importance = widget.HighImportance
+		cancelButton.Importance = widget.HighImportance
+		bottom = container.NewPadded(container.NewCenter(container.NewHBox(retryButton, cancelButton)))
+	}
+
+	// Layout
+	content := container.NewBorder(
+		header, // Top
+		bottom, // Bottom
+		nil,    // Left
+		nil,    // Right
+		// Center
+		container.NewVBox(
+			layout.NewSpacer(),
+			container.NewCenter(titleText),
+			layout.NewSpacer(),
+			container.NewCenter(amountText),
+			container.NewCenter(detailText),
 			layout.NewSpacer(),
 		),
 	)
@@ -487,21 +1470,22 @@ func (p *PetrolPump) showPaymentScreen() {
 }
 
 // handlePaymentSuccess shows a success screen and resets the pump
-func (p *PetrolPump) handlePaymentSuccess(cardUID string) {
-	// Stop checking for RFID
-	p.onPaymentScreen = false
+// A bank card shows its scheme and masked number, and a profile read from an NFC sticker greets its owner
+func (p *PetrolPump) handlePaymentSuccess(cardUID string, details cardDetails) {
+	// Leaving the payment state stops the RFID checks
+	if err := p.engine.CompletePayment(cardUID); err != nil {
+		// The sale can't be marked paid, so nothing may stay taken for it
+		fmt.Printf("⚠ Payment ignored: %v\n", err)
+		p.refundPayments()
+		return
+	}
 
 	// Create success screen
 	bg := canvas.NewRectangle(displayBg)
 
-	// Header with white background
-	headerBg := canvas.NewRectangle(color.White)
-	petrolLabel := canvas.NewText("PETROL", color.Black)
-	petrolLabel.TextSize = 50
-	petrolLabel.Alignment = fyne.TextAlignCenter
-
-	headerContent := container.NewCenter(petrolLabel)
-	header := container.NewStack(headerBg, container.NewPadded(headerContent))
+	// Header with white background showing the grade
+	snap := p.engine.Snapshot()
+	header := screenHeader(strings.ToUpper(snap.Grade.Name), snap.Grade.Colour)
 
 	// Success message
 	successText := canvas.NewText("✓ Payment Successful!", color.RGBA{R: 40, G: 200, B: 80, A: 255})
@@ -510,127 +1494,169 @@ func (p *PetrolPump) handlePaymentSuccess(cardUID string) {
 	successText.TextStyle = fyne.TextStyle{Bold: true}
 
 	// Card info (optional)
-	cardText := canvas.NewText(fmt.Sprintf("Card: %s", cardUID), displayWhite)
+	cardLine := fmt.Sprintf("Card: %s", cardUID)
+	if details.app != nil {
+		cardLine = details.app.String()
+	}
+	cardText := canvas.NewText(cardLine, displayWhite)
 	cardText.TextSize = 30
 	cardText.Alignment = fyne.TextAlignCenter
 
 	// Amount paid
-	amountText := canvas.NewText(fmt.Sprintf("£%.2f", p.amount), displayWhite)
+	amountText := canvas.NewText(snap.Amount.String(), displayWhite)
 	amountText.TextSize = 80
 	amountText.Alignment = fyne.TextAlignCenter
 
+	// Fuel bought
+	fuelText := canvas.NewText(fmt.Sprintf("%s  %s", snap.Grade.Name, snap.Volume), displayWhite)
+	fuelText.TextSize = 30
+	fuelText.Alignment = fyne.TextAlignCenter
+
+	body := container.NewVBox(
+		layout.NewSpacer(),
+		container.NewCenter(successText),
+		layout.NewSpacer(),
+		container.NewCenter(amountText),
+		container.NewCenter(fuelText),
+		layout.NewSpacer(),
+		container.NewCenter(cardText),
+		layout.NewSpacer(),
+	)
+	if greeting := details.profile.Greeting(); greeting != "" {
+		greetingText := canvas.NewText(greeting, displayAmber)
+		greetingText.TextSize = 30
+		greetingText.Alignment = fyne.TextAlignCenter
+		body.Add(container.NewCenter(greetingText))
+		body.Add(layout.NewSpacer())
+	}
+
+	// Offer a receipt if there's somewhere to print it
+	if p.printer != nil {
+		rcpt := p.receipt()
+		question := canvas.NewText("Print receipt?", displayWhite)
+		question.TextSize = 30
+		question.Alignment = fyne.TextAlignCenter
+
+		printButton := widget.NewButton("Print receipt", func() {
+			p.printReceipt(rcpt)
+			p.finishSale()
+		})
+		noButton := widget.NewButton("No thanks", func() {
+			p.finishSale()
+		})
+		printButton.Importance = widget.HighImportance
+		noButton.Importance = widget.HighImportance
+
+		body.Add(container.NewCenter(question))
+		body.Add(container.NewPadded(container.NewCenter(container.NewHBox(printButton, noButton))))
+		body.Add(layout.NewSpacer())
+	}
+
 	// Layout
 	content := container.NewBorder(
 		header, // Top
-		nil,    // Bottom
-		nil,    // Left
-		nil,    // Right
-		// Center
-		container.NewVBox(
-			layout.NewSpacer(),
-			container.NewCenter(successText),
-			layout.NewSpacer(),
-			container.NewCenter(amountText),
-			layout.NewSpacer(),
-			container.NewCenter(cardText),
-			layout.NewSpacer(),
-		),
+		nil,    // Bottom
+		nil,    // Left
+		nil,    // Right
+		body,   // Center
 	)
 
 	p.window.SetContent(container.NewStack(bg, content))
 
-	// Return to main screen after 3 seconds and reset
+	// Return to main screen after 3 seconds (or once the receipt question times out) and reset
+	wait := 3 * time.Second
+	if p.printer != nil {
+		wait = receiptChoiceTimeout
+	}
 	go func() {
-		time.Sleep(3 * time.Second)
-		mainBg := canvas.NewRectangle(displayBg)
-		p.window.SetContent(container.NewStack(mainBg, p.mainContent))
-		time.Sleep(100 * time.Millisecond)
-		p.reset()
+		time.Sleep(wait)
+		p.finishSale()
 	}()
 }
 
-// startRFIDMonitoring starts checking for RFID cards when on payment screen
-func (p *PetrolPump) startRFIDMonitoring() {
-	if p.rfidReader == nil {
-		fmt.Println("ℹ RFID reader not available - payments will be manual only")
-		return
+// finishSale leaves the success screen for the next customer, unless that has already happened
+func (p *PetrolPump) finishSale() {
+	if p.engine.State() == pump.StatePaid {
+		p.reset()
 	}
+}
 
-	fmt.Println("✓ RFID monitoring started - checking for cards every 500ms")
+// receipt builds the receipt for the sale that has just been paid
+func (p *PetrolPump) receipt() receipt.Receipt {
+	var number uint64
+	if p.journal != nil {
+		if entry, ok := p.journal.Last(p.number); ok && entry.Start.Equal(p.lastSale.Start) {
+			number = entry.Seq
+		}
+	}
+	return receipt.New(receiptHeader, number, p.number, p.lastSale)
+}
 
-	// Check for RFID cards every 500ms
-	p.rfidCheckTicker = time.NewTicker(500 * time.Millisecond)
-	checkCount := 0
-	go func() {
-		for range p.rfidCheckTicker.C {
-			// Only check if we're on the payment screen
-			if !p.onPaymentScreen {
-				continue
-			}
+// printReceipt sends a receipt to the printer, logging rather than failing the sale if it can't
+func (p *PetrolPump) printReceipt(rcpt receipt.Receipt) {
+	if err := p.printer.Print(rcpt); err != nil {
+		fmt.Printf("⚠ Receipt not printed: %v\n", err)
+		return
+	}
+	fmt.Printf("🧾 Pump %d: receipt printed\n", p.number)
+}
 
-			checkCount++
-			if checkCount%10 == 0 {
-				fmt.Printf("DEBUG: Checking for card... (check #%d)\n", checkCount)
-			}
+// startRFIDMonitoring hands cards tapped on the reader to the pump on its payment or top-up screen
+// The forecourt shares one reader, so a card pays for the lowest-numbered pump waiting for payment
+func startRFIDMonitoring(rfidReader RFIDReader, displays []*PetrolPump) {
+	if rfidReader == nil {
+		fmt.Println("ℹ RFID reader not available - payments will be manual only")
+		return
+	}
 
-			// Check if a card is present with panic recovery
-			var present bool
-			var err error
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						fmt.Printf("⚠ PANIC in IsCardPresent: %v\n", r)
-						fmt.Println("  → RFID reader may not be properly initialized")
-						fmt.Println("  → Falling back to keyboard mode (press P to pay)")
-						err = fmt.Errorf("panic in IsCardPresent: %v", r)
-						present = false
-					}
-				}()
-				present, err = p.rfidReader.IsCardPresent()
-			}()
+	stream := newCardStream()
+	go stream.forward(cardEvents(context.Background(), rfidReader))
+	if _, ok := rfidReader.(CardEventReader); ok {
+		fmt.Println("✓ RFID monitoring started - waiting for card events")
+	} else {
+		fmt.Printf("✓ RFID monitoring started - polling for cards every %s\n", cardPollInterval)
+	}
 
-			if err != nil {
-				fmt.Printf("DEBUG: Error checking card: %v\n", err)
+	// The payment and top-up screens take each card as it arrives; it isn't seen again until it has been taken away
+	events, _ := stream.Subscribe()
+	go func() {
+		for ev := range events {
+			if ev.Kind == CardRemoved {
+				fmt.Printf("ℹ Card %s removed\n", ev.Card.ID())
 				continue
 			}
-
-			if !present {
+			p := awaitingCard(displays)
+			if p == nil {
+				fmt.Printf("ℹ Card %s tapped, but no pump is waiting for one\n", ev.Card.ID())
 				continue
 			}
 
 			fmt.Println("✓ RFID card detected! Processing payment...")
-
-			// Read card ID with panic recovery
-			cardID := ""
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						fmt.Printf("⚠ PANIC in ReadCardID: %v\n", r)
-						cardID = "Unknown"
-					}
-				}()
-				if id, readErr := p.rfidReader.ReadCardID(); readErr == nil {
-					cardID = id
-				} else {
-					cardID = "Unknown"
-					fmt.Printf("DEBUG: Error reading card ID: %v\n", readErr)
-				}
-			}()
-
-			fmt.Printf("  Card ID: %s\n", cardID)
-			fmt.Printf("  Amount: £%.2f\n", p.amount)
-			fmt.Printf("  Fuel: %.2f L @ £%.2f/L\n", p.litres, p.pricePerLitre)
-
-			// Handle payment success
-			p.handlePaymentSuccess(cardID)
-
-			// Reset check count and delay to prevent multiple reads
-			checkCount = 0
-			time.Sleep(2 * time.Second)
+			fmt.Printf("  Pump: %d\n", p.number)
+			fmt.Printf("  Card: %s\n", ev.Card)
+			snap := p.engine.Snapshot()
+			fmt.Printf("  Amount: %s\n", snap.Amount)
+			fmt.Printf("  Fuel: %s @ %s\n", snap.Volume, snap.PricePerLitre)
+
+			// Take the card through the payment processor
+			p.handleCardTap(ev.Card)
 		}
 	}()
 }
 
+// awaitingCard returns the lowest-numbered display waiting for a card, to pay or to top up
+func awaitingCard(displays []*PetrolPump) *PetrolPump {
+	for _, p := range displays {
+		if !p.acceptingCards.Load() {
+			continue
+		}
+		if p.engine.State() == pump.StateAwaitingPayment || p.topUpAmount.Load() > 0 {
+			return p
+		}
+	}
+	return nil
+}
+
 // NewPayButton creates a new Bootstrap-style touchscreen-friendly pay button
 func NewPayButton(text string, onTapped func()) *PayButton {
 	pb := &PayButton{
@@ -764,21 +1790,29 @@ func (r *payButtonRenderer) Objects() []fyne.CanvasObject {
 func (r *payButtonRenderer) Destroy() {}
 
 func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
-	w := a.NewWindow("Petrol Pump Display")
-	w.SetFullScreen(true)
+	// A single pump fills the screen; several pumps each get their own 1024x600 window
+	var w fyne.Window
+	if len(p.forecourt.Positions()) == 1 {
+		w = a.NewWindow("Petrol Pump Display")
+		w.SetFullScreen(true)
+	} else {
+		w = a.NewWindow(fmt.Sprintf("Petrol Pump Display - Pump %d", p.number))
+		w.Resize(fyne.NewSize(1024, 600))
+	}
 
 	// Create background - full 1024x600
 	bg := canvas.NewRectangle(displayBg)
 	bg.SetMinSize(fyne.NewSize(1024, 600))
 
-	// Header labels (black text for white header background)
-	petrolLabel := canvas.NewText("PETROL", color.Black)
-	petrolLabel.TextSize = 50
-	petrolLabel.Alignment = fyne.TextAlignCenter
-	petrolLabel.TextStyle = fyne.TextStyle{Bold: false}
+	// Header labels (selected grade in its colour on the white header background)
+	grade := p.engine.Snapshot().Grade
+	p.gradeLabel = canvas.NewText(strings.ToUpper(grade.Name), grade.Colour)
+	p.gradeLabel.TextSize = 50
+	p.gradeLabel.Alignment = fyne.TextAlignCenter
+	p.gradeLabel.TextStyle = fyne.TextStyle{Bold: false}
 
 	// Rate label for header (black text)
-	p.rateLabel = canvas.NewText(fmt.Sprintf("£%.2f/L", p.pricePerLitre), color.Black)
+	p.rateLabel = canvas.NewText(p.engine.Snapshot().PricePerLitre.String(), color.Black)
 	p.rateLabel.TextSize = 30
 	p.rateLabel.Alignment = fyne.TextAlignCenter
 	p.rateLabel.TextStyle = fyne.TextStyle{Bold: false}
@@ -798,20 +1832,20 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 
 	var headerContent fyne.CanvasObject
 	if debugMode {
-		// Header with PETROL (left), DEBUG MODE (center), rate (right)
+		// Header with grade (left), DEBUG MODE (center), rate (right)
 		headerContent = container.NewBorder(
 			nil, nil,
-			container.NewPadded(petrolLabel),                        // Left with padding
+			container.NewPadded(p.gradeLabel),                       // Left with padding
 			container.NewPadded(p.rateLabel),                        // Right with padding
 			container.NewCenter(container.NewPadded(modeIndicator)), // Center
 		)
 	} else {
-		// Header with PETROL (left), rate (right)
+		// Header with grade (left), rate (right)
 		headerContent = container.NewBorder(
 			nil, nil,
-			container.NewPadded(petrolLabel), // Left with padding
-			container.NewPadded(p.rateLabel), // Right with padding
-			nil,                              // Center empty
+			container.NewPadded(p.gradeLabel), // Left with padding
+			container.NewPadded(p.rateLabel),  // Right with padding
+			nil,                               // Center empty
 		)
 	}
 	// Stack header background and content - header has fixed height
@@ -847,6 +1881,17 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 		p.showPaymentScreen()
 	})
 
+	// Start button - choose grade then preset; only enabled while idle, before pumping starts
+	p.startButton = NewPayButton("START", func() {
+		p.showGradeScreen()
+	})
+	p.startButton.SetEnabled(p.engine.State() == pump.StateIdle && !p.engine.OutOfService())
+
+	// Chosen preset, shown in the middle of the footer
+	p.presetLabel = canvas.NewText("", color.Black)
+	p.presetLabel.TextSize = 30
+	p.presetLabel.Alignment = fyne.TextAlignCenter
+
 	// Load logo for footer
 	var logoWidget fyne.CanvasObject
 	if _, err := os.Stat(logoPath); err == nil {
@@ -865,12 +1910,15 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 	footerBg := canvas.NewRectangle(color.White)
 	footerBg.SetMinSize(fyne.NewSize(1024, 100))
 
-	// Footer content: logo left, button right - use Border for proper alignment
+	// Footer content: logo left, preset centre, buttons right - use Border for proper alignment
 	footerContent := container.NewBorder(
 		nil, nil,
-		container.NewPadded(logoWidget),  // Left (with padding)
-		container.NewPadded(p.payButton), // Right (with padding)
-		nil,                              // Center empty
+		container.NewPadded(logoWidget), // Left (with padding)
+		container.NewHBox( // Right (with padding)
+			container.NewPadded(p.startButton),
+			container.NewPadded(p.payButton),
+		),
+		container.NewCenter(p.presetLabel), // Center
 	)
 
 	// Stack footer background and content - footer has fixed height
@@ -889,12 +1937,12 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 	var content *fyne.Container
 	if debugMode {
 		// Debug mode: show control instructions
-		statusLabel := canvas.NewText("Hold SPACE to pump • Press P to tap RFID • Press R to reset • ESC to exit", displayWhite)
+		statusLabel := canvas.NewText("Tap START to choose fuel • Hold SPACE to pump • Press P to tap RFID • Press R to reset • ESC to exit", displayWhite)
 		statusLabel.TextSize = 14
 		statusLabel.Alignment = fyne.TextAlignCenter
 
 		content = container.NewBorder(
-			header, // Top - header with PETROL and DEBUG MODE
+			header, // Top - header with grade and DEBUG MODE
 			footer, // Bottom - footer with button and logo
 			nil,    // Left
 			nil,    // Right
@@ -939,7 +1987,7 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 	} else {
 		// Normal mode: clean display without instructions
 		content = container.NewBorder(
-			header, // Top - header with PETROL
+			header, // Top - header with grade
 			footer, // Bottom - footer with button and logo
 			nil,    // Left
 			nil,    // Right
@@ -986,14 +2034,15 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 	p.window = w
 	w.SetContent(container.NewStack(bg, content))
 
-	// Handle keyboard - ESC and R work in both modes, SPACE and P only in debug mode
+	// Handle keyboard - ESC, R, L and A work in both modes, SPACE and P only in debug mode
+	// SPACE and R act on this window's pump; L and A act on the whole forecourt
 	w.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
 		switch key.Name {
 		case fyne.KeySpace:
 			// Only allow SPACE to pump in debug mode
 			if debugMode {
-				keyPressed = true
-				lastKeyPressTime = time.Now()
+				p.keyPressed = true
+				p.lastKeyPressTime = time.Now()
 			}
 		case fyne.KeyP:
 			// Only allow P to simulate RFID tap in debug mode
@@ -1005,13 +2054,52 @@ func (p *PetrolPump) createGUIDisplay(a fyne.App) fyne.Window {
 			// Reset works in both modes
 			p.reset()
 			if debugMode {
-				keyPressed = false
+				p.keyPressed = false
+			}
+		case fyne.KeyL:
+			// Attendant: lock or unlock every pump
+			if p.engine.Locked() {
+				p.forecourt.UnlockAll()
+				fmt.Println("🔓 Forecourt unlocked")
+			} else {
+				p.forecourt.LockAll()
+				fmt.Println("🔒 Forecourt locked")
+			}
+			fmt.Print(p.forecourt)
+		case fyne.KeyA:
+			// Attendant: authorise every idle pump for an open-ended sale
+			fmt.Printf("✓ Authorised pumps: %v\n", p.forecourt.AuthoriseAll(pump.NoPreset))
+		case fyne.KeyD:
+			// Attendant: record a tanker delivery (only between sales)
+			if p.engine.State() == pump.StateIdle {
+				p.showTankScreen()
+			}
+		case fyne.KeyT:
+			// Attendant: top up wallet cards (only between sales)
+			if p.engine.State() == pump.StateIdle {
+				p.showTopUpScreen("", displayWhite)
+			}
+		case fyne.KeyN:
+			// Only allow N to simulate the NFC sticker in debug mode
+			if debugMode && p.mockRFIDReader != nil {
+				fmt.Println("🔧 DEBUG: Simulating NFC sticker tap...")
+				p.mockRFIDReader.SimulateStickerTap()
+			}
+		case fyne.KeyB:
+			// Only allow B to simulate a bank card in debug mode
+			if debugMode && p.mockRFIDReader != nil {
+				fmt.Println("🔧 DEBUG: Simulating bank card tap...")
+				p.mockRFIDReader.SimulateBankCardTap()
+			}
+		case fyne.KeyW:
+			// Only allow W to simulate the wallet card in debug mode
+			if debugMode && p.mockRFIDReader != nil {
+				fmt.Println("🔧 DEBUG: Simulating wallet card tap...")
+				p.mockRFIDReader.SimulateWalletTap()
 			}
 		case fyne.KeyEscape:
 			// ESC to exit works in both modes
-			fmt.Printf("\nFinal totals:\n")
-			fmt.Printf("  Litres: %.2f L\n", p.litres)
-			fmt.Printf("  Amount: £%.2f\n", p.amount)
+			printFinalTotals(p.forecourt)
 			a.Quit()
 		}
 	})
@@ -1225,6 +2313,25 @@ func updateMultiColorDigitDisplay(text string, mainColor color.Color, size float
 	}
 }
 
+// screenHeader creates the white header used by the full-screen prompts
+func screenHeader(text string, col color.Color) *fyne.Container {
+	headerBg := canvas.NewRectangle(color.White)
+	label := canvas.NewText(text, col)
+	label.TextSize = 50
+	label.Alignment = fyne.TextAlignCenter
+
+	headerContent := container.NewCenter(label)
+	return container.NewStack(headerBg, container.NewPadded(headerContent))
+}
+
+// padDigits left-pads a reading with zeros to width characters, e.g. "1.50" → "001.50"
+func padDigits(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return strings.Repeat("0", width-len(text)) + text
+}
+
 // createDigitalText creates a text widget with digital font if available
 func createDigitalText(text string, col color.Color, size float32) *canvas.Text {
 	txt := canvas.NewText(text, col)
@@ -1300,10 +2407,166 @@ func loadBaseFont() {
 	}
 }
 
+// forecourtSetup is everything main prepares before the displays start
+type forecourtSetup struct {
+	pumpCount   int
+	prices      pump.PriceSource
+	tanks       *pump.Tanks
+	journal     *journal.Journal     // nil to run without a journal
+	checkpoints *journal.Checkpoints // nil to run without checkpoints
+	printer     receipt.Printer      // nil if receipts can't be printed
+	payments    payment.Processor
+	walletCards *walletCards       // nil without wallet cards
+	buttons     []pushbutton.Input // One per pump in normal mode, empty in debug mode
+	rfidReader  RFIDReader
+}
+
 func main() {
-	var button rpio.Pin
+	var buttons []pushbutton.Input
 	var rfidReader RFIDReader
 
+	pumpCount := flag.Int("pumps", 1, "number of pump positions to run, up to one per button in -pins (4 by default)")
+	priceFile := flag.String("price-file", "", "read prices from this file instead of picking random ones")
+	fixedPrice := flag.String("fixed-price", "", "charge this price per litre (e.g. 1.459) for every grade")
+	journalPath := flag.String("journal", "transactions.journal", "append every sale to this journal file (empty to disable)")
+	checkpointDir := flag.String("checkpoints", "checkpoints", "save sales in progress in this directory so they survive a restart (empty to disable)")
+	printerPath := flag.String("printer", "", "print receipts on the ESC/POS printer at this device (e.g. /dev/usb/lp0 or /dev/serial0)")
+	printerBaud := flag.Int("printer-baud", receipt.DefaultBaud, "serial speed of the receipt printer")
+	receiptFile := flag.String("receipt-file", "", "append receipts to this file instead of printing them")
+	acquirer := flag.String("acquirer", "approve", "how the simulated card acquirer answers: approve, decline, timeout, partial or random")
+	walletBlock := flag.Int("wallet-block", DefaultWalletBlock, "MIFARE Classic block holding wallet card balances (0 to disable wallet cards)")
+	walletKey := flag.String("wallet-key", "A:FFFFFFFFFFFF", "sector key for reading and spending wallet cards, as A:<12 hex digits> or B:<12 hex digits>")
+	walletTopUpKey := flag.String("wallet-topup-key", "", "sector key for topping up and refunding wallet cards (default -wallet-key)")
+	readers := flag.String("reader", "", fmt.Sprintf("card readers to try in order, separated by commas, each with key=value options (e.g. \"periph-mfrc522 cs=1 gain=5, mock\"); \"list\" lists them. Readers: %s", strings.Join(readerNames(), ", ")))
+	readerConfig := flag.String("reader-config", "", "read the card readers to try from this file, one per line in the same form as -reader")
+	pn532Port := flag.String("pn532", "", "read cards with a PN532 on this serial port (e.g. /dev/ttyS0 or /dev/ttyUSB0) before trying the MFRC522")
+	pn532Speed := flag.Int("pn532-baud", pn532.DefaultBaud, "serial speed of the PN532")
+	wedgeDevice := flag.String("wedge-reader", "", "read cards with a USB reader that types UIDs like a keyboard, at this input device (e.g. /dev/input/event0)")
+	wedgeUIDs := flag.String("wedge-format", defaultWedgeFormat, "how the -wedge-reader types UIDs: decimal, hex or auto")
+	rfidBitRate := flag.Int("rfid-bitrate", isoDepMaxBitRate, "fastest bit rate in kbit/s to talk to bank cards and phones at: 106, 212, 424 or 848")
+	boardName := flag.String("board", "auto", "board the pump runs on, for its pin layout: pi3, pi4, pi5, zero2w or auto")
+	pinMap := flag.String("pins", "", "GPIO for each part, overriding the board's, e.g. \"button1=17 button2=27 rst=25 irq=24\" or \"buttons=17,27\"")
+	gpioBackend := flag.String("gpio", "auto", "how to read the buttons: rpio (/dev/gpiomem), cdev (/dev/gpiochipN) or auto (cdev on a Pi 5 or where rpio fails)")
+	gpioChip := flag.String("gpio-chip", "", "GPIO chip for -gpio cdev, e.g. gpiochip0 (default the board's header chip, found by label)")
+	buttonBias := flag.String("button-bias", "pull-up", "how the button lines are pulled: pull-up (button to ground), pull-down (button to 3.3V) or none")
+	tankLowLevel := flag.Int("tank-low-level", int(pump.DefaultTankLowLevel/pump.Litre), "litres below which a grade's tank puts it out of service")
+	flag.Parse()
+	if *tankLowLevel < 0 {
+		fmt.Println("✗ -tank-low-level must not be negative")
+		os.Exit(1)
+	}
+	switch *rfidBitRate {
+	case 106, 212, 424, 848:
+		isoDepMaxBitRate = *rfidBitRate
+	default:
+		fmt.Println("✗ -rfid-bitrate must be 106, 212, 424 or 848")
+		os.Exit(1)
+	}
+	if *readers == "list" {
+		printReaderKinds()
+		os.Exit(0)
+	}
+	if !validWedgeFormat(*wedgeUIDs) {
+		fmt.Println("✗ -wedge-format must be decimal, hex or auto")
+		os.Exit(1)
+	}
+	// -pn532 and -wedge-reader are shorthand for readers, filling in any options the chain leaves out
+	var shorthands []readerSpec
+	if *pn532Port != "" {
+		shorthands = append(shorthands, readerSpec{kind: "pn532", options: readerOptions{"port": *pn532Port, "baud": strconv.Itoa(*pn532Speed)}})
+	}
+	if *wedgeDevice != "" {
+		shorthands = append(shorthands, readerSpec{kind: "evdev", options: readerOptions{"device": *wedgeDevice, "format": *wedgeUIDs}})
+	}
+	prices, err := choosePriceSource(*priceFile, *fixedPrice)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	behaviour, err := payment.ParseBehaviour(*acquirer)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	if *walletBlock < 0 || *walletBlock > 255 {
+		fmt.Println("✗ -wallet-block must be between 0 and 255")
+		os.Exit(1)
+	}
+	spendKey, err := ParseClassicKey(*walletKey)
+	if err != nil {
+		fmt.Printf("✗ -wallet-key: %v\n", err)
+		os.Exit(1)
+	}
+	topUpKey := spendKey
+	if *walletTopUpKey != "" {
+		if topUpKey, err = ParseClassicKey(*walletTopUpKey); err != nil {
+			fmt.Printf("✗ -wallet-topup-key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	readerChain, err := chooseReaders(*readers, *readerConfig, shorthands)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	// Work out the wiring and check it before any GPIO is touched
+	if boardProfile, err = chooseBoard(*boardName); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+	if boardPins, err = board.ParsePins(*pinMap, boardProfile.Pins); err != nil {
+		fmt.Printf("✗ -pins: %v\n", err)
+		os.Exit(1)
+	}
+	if *pumpCount < 1 || *pumpCount > len(boardPins.Buttons) {
+		fmt.Printf("✗ -pumps must be between 1 and %d (one per button in -pins)\n", len(boardPins.Buttons))
+		os.Exit(1)
+	}
+	bias, err := pushbutton.ParseBias(*buttonBias)
+	if err != nil {
+		fmt.Printf("✗ -button-bias: %v\n", err)
+		os.Exit(1)
+	}
+	switch *gpioBackend {
+	case "auto", "rpio", "cdev":
+	default:
+		fmt.Println("✗ -gpio must be rpio, cdev or auto")
+		os.Exit(1)
+	}
+	if err := checkPins(boardPins.Buttons[:*pumpCount], readerChain); err != nil {
+		fmt.Printf("✗ Pin conflicts:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(1)
+	}
+
+	printer, err := choosePrinter(*printerPath, *printerBaud, *receiptFile)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+
+	var salesJournal *journal.Journal
+	if *journalPath != "" {
+		salesJournal, err = journal.Open(*journalPath)
+		if err != nil {
+			fmt.Printf("✗ %v\n", err)
+			os.Exit(1)
+		}
+		defer salesJournal.Close()
+		fmt.Printf("✓ Journaling sales to %s\n", *journalPath)
+	}
+	var checkpoints *journal.Checkpoints
+	if *checkpointDir != "" {
+		checkpoints, err = journal.OpenCheckpoints(*checkpointDir)
+		if err != nil {
+			fmt.Printf("✗ %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Seed random number generator for price randomization
 	rand.Seed(time.Now().UnixNano())
 
@@ -1312,8 +2575,9 @@ func main() {
 	loadBaseFont()
 
 	// Try to initialize GPIO
-	err := rpio.Open()
+	buttons, closeButtons, err := openButtons(*gpioBackend, *gpioChip, bias, boardPins.Buttons[:*pumpCount])
 	if err != nil {
+		fmt.Printf("⚠ %v\n", err)
 		// GPIO not available - enter debug mode with GRAPHICAL display
 		debugMode = true
 		fmt.Println("╔════════════════════════════════════╗")
@@ -1325,6 +2589,12 @@ func main() {
 		fmt.Println("║  Hold SPACE to pump petrol         ║")
 		fmt.Println("║  Press P to simulate RFID tap      ║")
 		fmt.Println("║  Press R to reset                  ║")
+		fmt.Println("║  Press L to lock/unlock pumps      ║")
+		fmt.Println("║  Press D for tanker deliveries     ║")
+		fmt.Println("║  Press T to top up wallet cards    ║")
+		fmt.Println("║  Press W to tap the wallet card    ║")
+		fmt.Println("║  Press N to tap the NFC sticker    ║")
+		fmt.Println("║  Press B to tap a bank card        ║")
 		fmt.Println("║  Press ESC to exit                 ║")
 		fmt.Println("║                                    ║")
 		fmt.Println("║  Starting in 2 seconds...          ║")
@@ -1332,68 +2602,302 @@ func main() {
 		time.Sleep(2 * time.Second)
 	} else {
 		// GPIO available - normal mode with graphical display
-		defer rpio.Close()
-		button = rpio.Pin(buttonPin)
-		button.Input()
-		button.PullUp()
+		defer closeButtons()
+		for i := range buttons {
+			fmt.Printf("  Pump %d button on GPIO%d\n", i+1, boardPins.Buttons[i])
+		}
 		fmt.Println("✓ GPIO initialized - Running in normal mode")
 		fmt.Println("  Press and hold the button to pump")
 		time.Sleep(1 * time.Second)
 	}
 
 	// Try to initialize RFID reader
-	rfidReader = initRFIDReader()
+	rfidReader = initRFIDReader(readerChain)
+	wallets, err := chooseWalletCards(rfidReader, *walletBlock, spendKey, topUpKey)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
 
 	// Run graphical mode
-	runGraphicalMode(button, rfidReader)
+	tanks := pump.DefaultTanks(pump.DefaultGrades(), pump.Volume(*tankLowLevel)*pump.Litre)
+	runGraphicalMode(forecourtSetup{
+		pumpCount:   *pumpCount,
+		prices:      prices,
+		tanks:       tanks,
+		journal:     salesJournal,
+		checkpoints: checkpoints,
+		printer:     printer,
+		payments:    payment.NewSimulator(behaviour),
+		walletCards: wallets,
+		buttons:     buttons,
+		rfidReader:  rfidReader,
+	})
 }
 
-// initRFIDReader tries to initialize the MFRC522 RFID reader
-// Automatically detects if running on Pi with real hardware or in test mode
-// Returns mock reader if real hardware not available
-func initRFIDReader() RFIDReader {
-	fmt.Println("\nInitializing RFID reader...")
-
-	// Skip gobot in debug mode (no GPIO = probably not a real Pi with hardware)
-	// Also check if SPI devices exist before trying gobot
-	spiDevicesExist := false
-	if spidevFiles, err := filepath.Glob("/dev/spidev*"); err == nil && len(spidevFiles) > 0 {
-		spiDevicesExist = true
+// choosePriceSource picks the price source from the command-line flags
+// With neither flag set, prices are random between sales as before
+func choosePriceSource(priceFile, fixedPrice string) (pump.PriceSource, error) {
+	switch {
+	case priceFile != "" && fixedPrice != "":
+		return nil, fmt.Errorf("use only one of -price-file and -fixed-price")
+	case priceFile != "":
+		src, err := pump.NewPriceFile(priceFile)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("✓ Prices from %s\n", priceFile)
+		return src, nil
+	case fixedPrice != "":
+		price, err := pump.ParseUnitPrice(fixedPrice)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("✓ Fixed price %s\n", price)
+		return pump.FixedPrice(price), nil
 	}
+	return pump.RandomPrices{}, nil
+}
 
-	if !debugMode && spiDevicesExist {
-		// Try gobot MFRC522 driver first (uses SPI polling of chip registers)
-		// NOTE: gobot polls the MFRC522's internal interrupt registers via SPI
-		// It does NOT use GPIO IRQ pin - this is why it's more reliable!
-		fmt.Println("  Attempting gobot MFRC522 driver (SPI register polling mode)...")
-		gobotReader, err := NewGobotRFIDReader()
+// openButtons opens a button on each pin, through go-rpio or the GPIO character device
+// auto uses go-rpio where the board's GPIO is reachable through /dev/gpiomem, falling back to the
+// character device, which is all a Pi 5 has; the returned function lets go of the buttons
+func openButtons(backend, chip string, bias pushbutton.Bias, pins []int) ([]pushbutton.Input, func(), error) {
+	var errs []error
+	if backend == "rpio" || (backend == "auto" && boardProfile.GPIOMem) {
+		err := rpio.Open()
 		if err == nil {
-			fmt.Println("✓ Gobot MFRC522 RFID reader initialized successfully!")
-			fmt.Println("  ✓ Using gobot library with SPI interrupt register polling")
-			fmt.Println("  ✓ No GPIO IRQ pin required - more reliable than periph.io!")
-			fmt.Println("  ✓ Hardware ready - tap your card on the reader to pay")
-			return gobotReader
+			var buttons []pushbutton.Input
+			for _, pin := range pins {
+				buttons = append(buttons, pushbutton.OpenRPIO(pin, bias))
+			}
+			fmt.Printf("✓ Buttons through go-rpio (%s)\n", bias)
+			return buttons, func() { rpio.Close() }, nil
 		}
-		fmt.Printf("  ⚠ Gobot initialization failed: %v\n", err)
-	} else {
-		if debugMode {
-			fmt.Println("  Skipping gobot (debug mode - no hardware expected)")
-		} else if !spiDevicesExist {
-			fmt.Println("  Skipping gobot (no SPI devices found - run: make setup-spi)")
+		errs = append(errs, fmt.Errorf("go-rpio: %w", err))
+		if backend == "rpio" {
+			return nil, nil, errors.Join(errs...)
 		}
 	}
 
-	// Fallback: Try periph.io MFRC522 hardware (requires GPIO IRQ)
-	fmt.Println("  Attempting periph.io MFRC522 driver (requires GPIO IRQ)...")
-	periphReader, err := NewMFRC522RFIDReader()
-	if err == nil {
-		fmt.Println("✓ periph.io MFRC522 RFID reader initialized successfully")
-		fmt.Println("  Hardware ready - tap your card on the reader to pay")
-		return periphReader
+	if chip == "" {
+		found, err := pushbutton.FindChip(boardProfile.GPIOChip)
+		if err != nil {
+			return nil, nil, errors.Join(append(errs, fmt.Errorf("GPIO character device: %w", err))...)
+		}
+		chip = found
+	}
+	var buttons []pushbutton.Input
+	closeAll := func() {
+		for _, b := range buttons {
+			b.Close()
+		}
+	}
+	for _, pin := range pins {
+		line, err := pushbutton.OpenLine(chip, pin, bias)
+		if err != nil {
+			closeAll()
+			return nil, nil, errors.Join(append(errs, fmt.Errorf("GPIO character device: %w", err))...)
+		}
+		buttons = append(buttons, line)
+	}
+	fmt.Printf("✓ Buttons through %s (%s, edge events)\n", chip, bias)
+	return buttons, closeAll, nil
+}
+
+// chooseBoard picks the board profile from -board, recognising the board for "auto"
+// Off a Pi, auto uses the pi4 layout, which every 40-pin Pi shares
+func chooseBoard(name string) (board.Profile, error) {
+	if name != "auto" {
+		profile, err := board.Lookup(name)
+		if err != nil {
+			return board.Profile{}, fmt.Errorf("-board: %w", err)
+		}
+		fmt.Printf("✓ Board %s\n", profile.Name)
+		return profile, nil
+	}
+	if profile, ok := board.Detect(); ok {
+		fmt.Printf("✓ Board %s (detected)\n", profile.Name)
+		return profile, nil
+	}
+	fmt.Println("ℹ Board not recognised - using the pi4 pin layout")
+	return board.Lookup("pi4")
+}
+
+// checkPins finds pins wanted for two things: pump buttons, and the buses and pins of every reader in the chain
+// Readers in the chain are alternatives, so they may share a bus; they are all wired up at once all the same
+func checkPins(buttons []int, chain []readerSpec) error {
+	var plan board.Plan
+	for i, pin := range buttons {
+		plan.Use(pin, fmt.Sprintf("pump %d button", i+1))
 	}
-	fmt.Printf("  ⚠ periph.io initialization failed: %v\n", err)
 
-	// Real hardware not available - use mock for testing
+	// Each pin once per use, however many readers share it
+	type pinUse struct {
+		pin  int
+		what string
+	}
+	readerPins := map[pinUse]bool{}
+	for _, spec := range chain {
+		pins, err := spec.pins()
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec.kind, err)
+		}
+		for pin, what := range pins {
+			readerPins[pinUse{pin, what}] = true
+		}
+	}
+	for use := range readerPins {
+		plan.Use(use.pin, use.what)
+	}
+	return plan.Check()
+}
+
+// choosePrinter picks where receipts go from the command-line flags
+// With neither flag set there is no printer and the success screen doesn't offer a receipt
+func choosePrinter(printerPath string, baud int, receiptFile string) (receipt.Printer, error) {
+	switch {
+	case printerPath != "" && receiptFile != "":
+		return nil, fmt.Errorf("use only one of -printer and -receipt-file")
+	case printerPath != "":
+		fmt.Printf("✓ Receipts printed on %s\n", printerPath)
+		return receipt.DevicePrinter{Path: printerPath, Baud: baud}, nil
+	case receiptFile != "":
+		fmt.Printf("✓ Receipts written to %s\n", receiptFile)
+		return receipt.FilePrinter{Path: receiptFile}, nil
+	}
+	return nil, nil
+}
+
+// chooseWalletCards sets up wallet cards from the command-line flags
+// Wallet cards need a reader that can use MIFARE Classic value blocks; block 0 turns them off
+func chooseWalletCards(reader RFIDReader, block int, key, topUpKey ClassicKey) (*walletCards, error) {
+	if block == 0 {
+		return nil, nil
+	}
+	if err := checkValueBlock(byte(block)); err != nil {
+		return nil, fmt.Errorf("-wallet-block: %v", err)
+	}
+	if !readerCan[ValueCardReader](reader) {
+		fmt.Println("ℹ RFID reader can't use MIFARE Classic value blocks - wallet cards disabled")
+		return nil, nil
+	}
+	valueReader := reader.(ValueCardReader)
+	fmt.Printf("✓ Wallet cards keep their balance in block %d (%s to spend, %s to top up)\n", block, key, topUpKey)
+	return &walletCards{reader: valueReader, block: byte(block), key: key, topUpKey: topUpKey}, nil
+}
+
+// mockRFIDReader is the mock reader's one instance, shared with the debug keys
+var mockRFIDReader = &MockRFIDReader{}
+
+func init() {
+	registerReader("periph-mfrc522", readerKind{
+		summary: "MFRC522 on SPI through periph.io, waiting on its IRQ line",
+		options: []string{"bus", "cs", "rst", "irq", "gain"},
+		open: func(opts readerOptions) (RFIDReader, error) {
+			cfg, err := mfrc522Options(opts, 7)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("  Attempting periph.io MFRC522 driver (requires GPIO IRQ)...")
+			periphReader, err := NewMFRC522RFIDReader(cfg)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("✓ periph.io MFRC522 RFID reader initialized successfully")
+			fmt.Println("  Hardware ready - tap your card on the reader to pay")
+			return periphReader, nil
+		},
+		pins: func(opts readerOptions) (map[int]string, error) {
+			cfg, err := mfrc522Options(opts, -1)
+			if err != nil {
+				return nil, err
+			}
+			pins, err := cfg.spiPins()
+			if err != nil {
+				return nil, err
+			}
+			// One map can't hold two uses of a pin, so a clash within the reader is reported here
+			for _, use := range []struct {
+				pin  int
+				what string
+			}{{cfg.rst, "MFRC522 RST"}, {cfg.irq, "MFRC522 IRQ"}} {
+				if other, ok := pins[use.pin]; ok {
+					return nil, fmt.Errorf("GPIO%d is wanted for %s and %s", use.pin, other, use.what)
+				}
+				pins[use.pin] = use.what
+			}
+			return pins, nil
+		},
+	})
+	registerReader("mock", readerKind{
+		summary: "no hardware; the debug keys tap cards",
+		open: func(readerOptions) (RFIDReader, error) {
+			printKeyboardSimulationBanner()
+			return mockRFIDReader, nil
+		},
+	})
+}
+
+// chooseReaders picks the card readers to try, in order, from the command-line flags
+// shorthands are the readers -pn532 and -wedge-reader ask for; they fill in options for readers of
+// the same kind in an explicit chain, and head the default chain when neither flag is set
+func chooseReaders(readers, readerConfig string, shorthands []readerSpec) ([]readerSpec, error) {
+	var chain []readerSpec
+	var err error
+	switch {
+	case readers != "" && readerConfig != "":
+		return nil, fmt.Errorf("use only one of -reader and -reader-config")
+	case readers != "":
+		chain, err = parseReaderChain(readers)
+	case readerConfig != "":
+		chain, err = loadReaderConfig(readerConfig)
+	default:
+		return defaultReaders(shorthands), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return withDefaults(chain, shorthands), nil
+}
+
+// defaultReaders looks for hardware the way the pump always has, ending with the mock reader
+func defaultReaders(shorthands []readerSpec) []readerSpec {
+	chain := append([]readerSpec{}, shorthands...)
+	return append(chain,
+		readerSpec{kind: "gobot-mfrc522", guessed: true},
+		readerSpec{kind: "periph-mfrc522"},
+		readerSpec{kind: "mock"})
+}
+
+// initRFIDReader opens the first card reader in chain that works
+// Returns a supervisor running it, which fails over down the chain if it stops working
+func initRFIDReader(chain []readerSpec) RFIDReader {
+	fmt.Println("\nInitializing RFID reader...")
+
+	var factories []readerFactory
+	var mock *MockRFIDReader
+	for _, spec := range chain {
+		// Skip gobot in debug mode (no GPIO = probably not a real Pi with hardware)
+		if debugMode && spec.guessed {
+			continue
+		}
+		factories = append(factories, spec.factory())
+		if spec.kind == "mock" {
+			mock = mockRFIDReader
+		}
+	}
+
+	supervisor := newReaderSupervisor(factories, mock)
+	if err := supervisor.start(); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return nil
+	}
+	return supervisor
+}
+
+// printKeyboardSimulationBanner explains the mock reader, which stands in when there's no hardware
+func printKeyboardSimulationBanner() {
 	fmt.Println("\n╔═══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║              RFID KEYBOARD SIMULATION MODE                    ║")
 	fmt.Println("╠═══════════════════════════════════════════════════════════════╣")
@@ -1420,18 +2924,65 @@ func initRFIDReader() RFIDReader {
 	fmt.Println("║  ✓ Perfect for demos & testing!                             ║")
 	fmt.Println("╚═══════════════════════════════════════════════════════════════╝")
 	fmt.Println()
-
-	return &MockRFIDReader{}
 }
 
-func runGraphicalMode(button rpio.Pin, rfidReader RFIDReader) {
-	pump := NewPetrolPump()
-	pump.button = button
-	pump.rfidReader = rfidReader
+// runGraphicalMode runs a forecourt of setup.pumpCount pumps, one display window each
+func runGraphicalMode(setup forecourtSetup) {
+	prices, buttons, rfidReader := setup.prices, setup.buttons, setup.rfidReader
+	forecourt := pump.NewForecourt(setup.pumpCount, pump.DefaultGrades(), setup.tanks)
+	for _, pos := range forecourt.Positions() {
+		pos.SetPriceSource(prices)
+	}
+	if setup.journal != nil {
+		journalSales(forecourt, setup.journal)
+	}
+	if setup.checkpoints != nil {
+		// Journal first, so a finished sale is recorded before its checkpoint goes
+		checkpointSales(forecourt, setup.checkpoints)
+		resumeSales(forecourt, setup.checkpoints, setup.journal)
+	}
+
+	// One wallet for the whole forecourt, as it shares the reader
+	var wallet payment.Processor
+	if setup.walletCards != nil {
+		wallet = payment.NewWallet(setup.walletCards)
+	}
+
+	// Asked of the reader running now, as the supervisor answers every capability for whichever reader it has
+	canReadTags, canReadBankCards := readerCan[NDEFReader](rfidReader), readerCan[APDUReader](rfidReader)
+	if !canReadTags {
+		fmt.Println("ℹ RFID reader can't read NFC stickers - tag profiles disabled")
+	}
+	if !canReadBankCards {
+		fmt.Println("ℹ RFID reader can't talk ISO-DEP - bank card details won't be read")
+	}
 
-	// Store mock reader reference if in debug mode
-	if mockReader, ok := rfidReader.(*MockRFIDReader); ok {
-		pump.mockRFIDReader = mockReader
+	var displays []*PetrolPump
+	for i, pos := range forecourt.Positions() {
+		display := NewPetrolPump(forecourt, pos)
+		if i < len(buttons) {
+			display.button = buttons[i]
+		}
+		display.journal = setup.journal
+		display.printer = setup.printer
+		display.payments = setup.payments
+		display.wallet = wallet
+		display.walletCards = setup.walletCards
+		if canReadTags {
+			display.tags = rfidReader.(NDEFReader)
+		}
+		if canReadBankCards {
+			display.apdu = rfidReader.(APDUReader)
+		}
+		// Store mock reader reference if in debug mode
+		if mockReader, ok := rfidReader.(*MockRFIDReader); ok {
+			display.mockRFIDReader = mockReader
+		}
+		if supervisor, ok := rfidReader.(*readerSupervisor); ok {
+			display.mockRFIDReader = supervisor.mock
+			display.rfid = supervisor
+		}
+		displays = append(displays, display)
 	}
 
 	// Create GUI application
@@ -1444,42 +2995,216 @@ func runGraphicalMode(button rpio.Pin, rfidReader RFIDReader) {
 	splashWindow := createSplashScreen(myApp)
 	splashWindow.Show()
 
-	// After splash duration, switch to main pump display
+	// After splash duration, switch to the pump displays
 	go func() {
 		time.Sleep(splashDuration)
 		splashWindow.Hide()
 
-		// Create and show main window
-		mainWindow := pump.createGUIDisplay(myApp)
-		mainWindow.Show()
+		// Create and show one window per pump
+		for _, display := range displays {
+			display.createGUIDisplay(myApp).Show()
+		}
 
 		// Setup signal handling after main window is shown
-		setupSignalHandling(myApp, pump)
+		setupSignalHandling(myApp, forecourt)
+
+		// Start pump monitoring for every position
+		for _, display := range displays {
+			startPumpMonitoring(display.engine, display.buttonPressed)
+		}
 
-		// Start pump monitoring
-		startPumpMonitoring(pump, button)
+		// A sale resumed after a restart goes straight to payment
+		for _, display := range displays {
+			if display.engine.CanPay() {
+				display.showPaymentScreen()
+			}
+		}
 
 		// Start RFID monitoring if reader is available
-		pump.startRFIDMonitoring()
+		if supervisor, ok := rfidReader.(*readerSupervisor); ok {
+			supervisor.OnHealthChange(func(ReaderHealth, string) {
+				for _, display := range displays {
+					display.showReaderHealth()
+				}
+			})
+		}
+		startRFIDMonitoring(rfidReader, displays)
+
+		// Pick up scheduled or edited prices while pumps are idle
+		// (random prices only change when a sale finishes, as before)
+		if _, random := prices.(pump.RandomPrices); !random {
+			startPriceRefresh(forecourt)
+		}
 	}()
 
 	myApp.Run()
+	closeReader(rfidReader, readerHangLimit)
+}
+
+// journalSales appends every finished sale on the forecourt to the journal
+func journalSales(forecourt *pump.Forecourt, salesJournal *journal.Journal) {
+	for _, pos := range forecourt.Positions() {
+		number := pos.Number
+		pos.Subscribe(func(ev pump.Event) {
+			if ev.Type != pump.EventSaleFinished {
+				return
+			}
+			entry, err := salesJournal.Append(journal.FromSale(number, ev.Sale))
+			if err != nil {
+				fmt.Printf("✗ Failed to journal sale on pump %d: %v\n", number, err)
+				return
+			}
+			fmt.Printf("✓ Journaled %s\n", entry)
+		})
+	}
+}
+
+// checkpointSales saves each pump's sale in progress while it pumps, and clears it once finished
+// Events arrive from the dispense ticker and the payment goroutines alike, so each pump's are taken one at a time;
+// an event for a sale that has already finished comes too late to save
+func checkpointSales(forecourt *pump.Forecourt, checkpoints *journal.Checkpoints) {
+	for _, pos := range forecourt.Positions() {
+		number := pos.Number
+		var (
+			mu        sync.Mutex
+			lastSaved time.Time
+			finished  time.Time // Start of the last sale to finish
+		)
+		save := func(snap pump.Snapshot) {
+			if !snap.Started.After(finished) {
+				return
+			}
+			lastSaved = time.Now()
+			if err := checkpoints.Save(journal.CheckpointFromSnapshot(number, snap, lastSaved)); err != nil {
+				fmt.Printf("✗ Failed to checkpoint pump %d: %v\n", number, err)
+			}
+		}
+
+		pos.Subscribe(func(ev pump.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch ev.Type {
+			case pump.EventTotalsChanged:
+				// Periodically while fuel flows
+				if ev.Snapshot.State == pump.StatePumping && time.Since(lastSaved) >= checkpointInterval {
+					save(ev.Snapshot)
+				}
+			case pump.EventStateChanged:
+				// Exactly once the nozzle stops
+				if to := ev.Transition.To; (to == pump.StatePaused || to == pump.StateAwaitingPayment) && ev.Snapshot.Volume > 0 {
+					save(ev.Snapshot)
+				}
+			case pump.EventSaleFinished:
+				if ev.Sale.Start.After(finished) {
+					finished = ev.Sale.Start
+				}
+				if err := checkpoints.Clear(number); err != nil {
+					fmt.Printf("✗ Failed to clear checkpoint for pump %d: %v\n", number, err)
+				}
+			}
+		})
+	}
+}
+
+// resumeSales puts back any sale that was still in progress when the pump last stopped
+// A checkpoint whose sale already made it into the journal finished just before the restart
+func resumeSales(forecourt *pump.Forecourt, checkpoints *journal.Checkpoints, salesJournal *journal.Journal) {
+	var entries []journal.Entry
+	if salesJournal != nil {
+		var err error
+		if entries, err = salesJournal.Entries(); err != nil {
+			fmt.Printf("⚠ Cannot read journal: %v\n", err)
+		}
+	}
+
+	for _, pos := range forecourt.Positions() {
+		cp, ok, err := checkpoints.Load(pos.Number)
+		if err != nil {
+			fmt.Printf("✗ Pump %d: %v\n", pos.Number, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		finished := false
+		for _, entry := range entries {
+			if cp.Matches(entry) {
+				finished = true
+				break
+			}
+		}
+		if finished || cp.Volume <= 0 {
+			if err := checkpoints.Clear(pos.Number); err != nil {
+				fmt.Printf("✗ Failed to clear checkpoint for pump %d: %v\n", pos.Number, err)
+			}
+			continue
+		}
+
+		if err := pos.Resume(cp.Sale()); err != nil {
+			fmt.Printf("✗ Pump %d: cannot resume interrupted sale: %v\n", pos.Number, err)
+			continue
+		}
+		fmt.Printf("⚠ Pump %d: resumed interrupted sale - %s %s, %s to pay\n", pos.Number, cp.Grade, cp.Volume, cp.Amount)
+	}
 }
 
-func setupSignalHandling(myApp fyne.App, pump *PetrolPump) {
+func setupSignalHandling(myApp fyne.App, forecourt *pump.Forecourt) {
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		fmt.Printf("\nFinal totals:\n")
-		fmt.Printf("  Litres: %.2f L\n", pump.litres)
-		fmt.Printf("  Amount: £%.2f\n", pump.amount)
+		printFinalTotals(forecourt)
 		myApp.Quit()
 	}()
 }
 
-func startPumpMonitoring(pump *PetrolPump, button rpio.Pin) {
+// startPriceRefresh periodically offers idle pumps new prices from their price source
+func startPriceRefresh(forecourt *pump.Forecourt) {
+	go func() {
+		ticker := time.NewTicker(priceRefreshInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			for _, pos := range forecourt.Positions() {
+				pos.RefreshPrices(now)
+			}
+		}
+	}()
+}
+
+// printFinalTotals prints each pump's readings on exit
+func printFinalTotals(forecourt *pump.Forecourt) {
+	fmt.Printf("\nFinal totals:\n")
+	for _, st := range forecourt.Status() {
+		fmt.Printf("  Pump %d (%s)\n", st.Number, st.Grade.Name)
+		fmt.Printf("    Litres: %s\n", st.Volume)
+		fmt.Printf("    Amount: %s\n", st.Amount)
+	}
+	if tanks := forecourt.Tanks(); tanks != nil {
+		fmt.Printf("Tanks:\n")
+		for _, tank := range tanks.All() {
+			fmt.Printf("  %s\n", tank)
+		}
+	}
+}
+
+// buttonPressed reads this pump's button (or SPACE key in debug mode)
+func (p *PetrolPump) buttonPressed() bool {
+	if debugMode {
+		// Debug mode: use keyboard (handled by Fyne event handlers)
+		// Check if key press has timed out (key was released)
+		if p.keyPressed && time.Since(p.lastKeyPressTime) > keyPressTimeout {
+			p.keyPressed = false
+		}
+		return p.keyPressed
+	}
+	// Normal mode: use GPIO
+	return p.button != nil && p.button.Pressed()
+}
+
+// startPumpMonitoring drives the engine from a button
+func startPumpMonitoring(engine *pump.Engine, pressed func() bool) {
 	go func() {
 		ticker := time.NewTicker(updateInterval)
 		defer ticker.Stop()
@@ -1488,26 +3213,25 @@ func startPumpMonitoring(pump *PetrolPump, button rpio.Pin) {
 
 		for {
 			<-ticker.C
-			var buttonPressed bool
-
-			if debugMode {
-				// Debug mode: use keyboard (handled by Fyne event handlers)
-				// Check if key press has timed out (key was released)
-				if keyPressed && time.Since(lastKeyPressTime) > keyPressTimeout {
-					keyPressed = false
-				}
-				buttonPressed = keyPressed
-			} else {
-				// Normal mode: use GPIO
-				buttonPressed = button.Read() == rpio.Low
-			}
+			buttonPressed := pressed()
 
 			if buttonPressed {
-				pump.increment()
+				if !lastButtonState {
+					// Button was just pressed - rejected if a sale can't pump right now
+					// or the grade's tank is below its low level
+					if engine.GradeOutOfService() {
+						fmt.Printf("⚠ Cannot start pumping: %s is out of service (tank low)\n", engine.Snapshot().Grade.Name)
+					} else if err := engine.StartPumping(); err != nil {
+						fmt.Printf("⚠ Cannot start pumping: %v\n", err)
+					}
+				}
+				engine.Dispense(time.Now())
 				lastButtonState = true
 			} else if lastButtonState {
 				// Button was just released
-				pump.stopPumping()
+				if err := engine.StopPumping(); err != nil {
+					fmt.Printf("⚠ Cannot stop pumping: %v\n", err)
+				}
 				lastButtonState = false
 			}
 		}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"petrol-pump/journal"
+	"petrol-pump/pump"
+)
+
+func TestCheckpointSales(t *testing.T) {
+	checkpoints, err := journal.OpenCheckpoints(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forecourt := pump.NewForecourt(1, pump.DefaultGrades(), nil)
+	checkpointSales(forecourt, checkpoints)
+	pos := forecourt.Positions()[0]
+	pos.SetPriceSource(pump.FixedPrice(1459))
+
+	// The dispense ticker runs alongside the buttons and payments, as it does on the forecourt
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		now := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			now = now.Add(100 * time.Millisecond)
+			pos.Dispense(now)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for sale := 0; sale < 10; sale++ {
+		if err := pos.Authorise(pump.NoPreset); err != nil {
+			t.Fatal(err)
+		}
+		if err := pos.StartPumping(); err != nil {
+			t.Fatal(err)
+		}
+		for pos.Snapshot().Volume == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := pos.StopPumping(); err != nil {
+			t.Fatal(err)
+		}
+		cp, ok, err := checkpoints.Load(pos.Number)
+		if err != nil || !ok {
+			t.Fatalf("sale %d: no checkpoint once paused (%v)", sale+1, err)
+		}
+		if snap := pos.Snapshot(); cp.Volume != snap.Volume {
+			t.Errorf("sale %d: checkpoint holds %s, pump shows %s", sale+1, cp.Volume, snap.Volume)
+		}
+
+		if err := pos.RequestPayment(); err != nil {
+			t.Fatal(err)
+		}
+		if err := pos.CompletePayment("DEADBEEF"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := checkpoints.Load(pos.Number); ok {
+			t.Fatalf("sale %d: checkpoint left behind once paid", sale+1)
+		}
+		if err := pos.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,278 @@
+// Package ndef encodes and parses NFC Data Exchange Format messages.
+//
+// An NDEF message is a list of records, each with a type name format
+// (TNF), a type, an optional ID and a payload. Only unchunked records are
+// supported, which is all an NFC sticker ever holds.
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// TNF is a record's type name format: how to read its Type
+type TNF byte
+
+const (
+	TNFEmpty       TNF = 0x00 // No type or payload
+	TNFWellKnown   TNF = 0x01 // NFC Forum well-known type, e.g. "T" for text or "U" for a URI
+	TNFMedia       TNF = 0x02 // MIME media type, e.g. "application/json"
+	TNFAbsoluteURI TNF = 0x03 // The type is a URI
+	TNFExternal    TNF = 0x04 // NFC Forum external type, "domain:type"
+	TNFUnknown     TNF = 0x05 // Payload of unknown type
+	TNFUnchanged   TNF = 0x06 // Middle or last chunk of a chunked record
+)
+
+// Record header flags (NFC Forum NDEF 1.0 section 3.2)
+const (
+	flagMB = 0x80 // Message begin
+	flagME = 0x40 // Message end
+	flagCF = 0x20 // Chunk flag
+	flagSR = 0x10 // Short record: one-byte payload length
+	flagIL = 0x08 // ID length is present
+)
+
+// Record is one NDEF record
+type Record struct {
+	TNF     TNF
+	Type    []byte
+	ID      []byte
+	Payload []byte
+}
+
+// Message is an NDEF message
+type Message []Record
+
+// Encode lays out a message in the NDEF wire format
+// An empty message is encoded as a single empty record, as NFC Forum tags expect
+func (m Message) Encode() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte{flagMB | flagME | flagSR | byte(TNFEmpty), 0, 0}, nil
+	}
+
+	var out []byte
+	for i, r := range m {
+		if r.TNF > TNFUnknown {
+			return nil, fmt.Errorf("record %d: cannot encode TNF %d", i, r.TNF)
+		}
+		if len(r.Type) > 255 || len(r.ID) > 255 {
+			return nil, fmt.Errorf("record %d: type or ID longer than 255 bytes", i)
+		}
+
+		header := byte(r.TNF)
+		if i == 0 {
+			header |= flagMB
+		}
+		if i == len(m)-1 {
+			header |= flagME
+		}
+		short := len(r.Payload) < 256
+		if short {
+			header |= flagSR
+		}
+		if len(r.ID) > 0 {
+			header |= flagIL
+		}
+
+		out = append(out, header, byte(len(r.Type)))
+		if short {
+			out = append(out, byte(len(r.Payload)))
+		} else {
+			out = binary.BigEndian.AppendUint32(out, uint32(len(r.Payload)))
+		}
+		if len(r.ID) > 0 {
+			out = append(out, byte(len(r.ID)))
+		}
+		out = append(out, r.Type...)
+		out = append(out, r.ID...)
+		out = append(out, r.Payload...)
+	}
+	return out, nil
+}
+
+// Parse reads a message in the NDEF wire format
+// A message holding only an empty record is returned as an empty message
+func Parse(data []byte) (Message, error) {
+	var m Message
+	for i := 0; ; i++ {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("record %d: truncated header", i)
+		}
+		header := data[0]
+		if i == 0 && header&flagMB == 0 {
+			return nil, fmt.Errorf("first record lacks the message begin flag")
+		}
+		if header&flagCF != 0 || TNF(header&0x07) == TNFUnchanged {
+			return nil, fmt.Errorf("record %d: chunked records are not supported", i)
+		}
+
+		typeLength := int(data[1])
+		data = data[2:]
+		var payloadLength int
+		if header&flagSR != 0 {
+			payloadLength = int(data[0])
+			data = data[1:]
+		} else {
+			if len(data) < 4 {
+				return nil, fmt.Errorf("record %d: truncated payload length", i)
+			}
+			payloadLength = int(binary.BigEndian.Uint32(data))
+			data = data[4:]
+		}
+		idLength := 0
+		if header&flagIL != 0 {
+			if len(data) < 1 {
+				return nil, fmt.Errorf("record %d: truncated ID length", i)
+			}
+			idLength = int(data[0])
+			data = data[1:]
+		}
+		if payloadLength < 0 || len(data) < typeLength+idLength+payloadLength {
+			return nil, fmt.Errorf("record %d: truncated", i)
+		}
+
+		r := Record{TNF: TNF(header & 0x07)}
+		r.Type, data = clone(data[:typeLength]), data[typeLength:]
+		r.ID, data = clone(data[:idLength]), data[idLength:]
+		r.Payload, data = clone(data[:payloadLength]), data[payloadLength:]
+		if r.TNF != TNFEmpty {
+			m = append(m, r)
+		}
+
+		if header&flagME != 0 {
+			return m, nil
+		}
+	}
+}
+
+func clone(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+// Find returns the first record with the given TNF and type
+func (m Message) Find(tnf TNF, typ string) (Record, bool) {
+	for _, r := range m {
+		if r.TNF == tnf && string(r.Type) == typ {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+// Replace returns a copy of the message with every record of r's TNF and type replaced by r
+// If there were none, r is added at the end
+func (m Message) Replace(r Record) Message {
+	out := make(Message, 0, len(m)+1)
+	replaced := false
+	for _, old := range m {
+		if old.TNF == r.TNF && string(old.Type) == string(r.Type) {
+			if !replaced {
+				out = append(out, r)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, old)
+	}
+	if !replaced {
+		out = append(out, r)
+	}
+	return out
+}
+
+// NewText creates a well-known text record in UTF-8, e.g. NewText("en", "Hello")
+func NewText(lang, text string) Record {
+	payload := append([]byte{byte(len(lang) & 0x3F)}, lang...)
+	payload = append(payload, text...)
+	return Record{TNF: TNFWellKnown, Type: []byte("T"), Payload: payload}
+}
+
+// Text returns the text of a well-known text record
+func (r Record) Text() (string, bool) {
+	if r.TNF != TNFWellKnown || string(r.Type) != "T" || len(r.Payload) < 1 {
+		return "", false
+	}
+	status := r.Payload[0]
+	langLength := int(status & 0x3F)
+	if len(r.Payload) < 1+langLength {
+		return "", false
+	}
+	text := r.Payload[1+langLength:]
+	if status&0x80 == 0 {
+		return string(text), true
+	}
+
+	// UTF-16, big-endian unless there's a byte order mark
+	if len(text)%2 != 0 {
+		return "", false
+	}
+	order := binary.ByteOrder(binary.BigEndian)
+	if len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE {
+		order, text = binary.LittleEndian, text[2:]
+	} else if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+		text = text[2:]
+	}
+	units := make([]uint16, len(text)/2)
+	for i := range units {
+		units[i] = order.Uint16(text[2*i:])
+	}
+	return string(utf16.Decode(units)), true
+}
+
+// uriPrefixes are the abbreviations a URI record's first byte stands for (NFC Forum URI RTD, table 3)
+var uriPrefixes = []string{
+	"", "http://www.", "https://www.", "http://", "https://", "tel:", "mailto:",
+	"ftp://anonymous:anonymous@", "ftp://ftp.", "ftps://", "sftp://", "smb://", "nfs://",
+	"ftp://", "dav://", "news:", "telnet://", "imap:", "rtsp://", "urn:", "pop:", "sip:",
+	"sips:", "tftp:", "btspp://", "btl2cap://", "btgoep://", "tcpobex://", "irdaobex://",
+	"file://", "urn:epc:id:", "urn:epc:tag:", "urn:epc:pat:", "urn:epc:raw:", "urn:epc:",
+	"urn:nfc:",
+}
+
+// NewURI creates a well-known URI record, abbreviating the longest prefix it can
+func NewURI(uri string) Record {
+	code := 0
+	for i, prefix := range uriPrefixes {
+		if prefix != "" && strings.HasPrefix(uri, prefix) && len(prefix) > len(uriPrefixes[code]) {
+			code = i
+		}
+	}
+	payload := append([]byte{byte(code)}, uri[len(uriPrefixes[code]):]...)
+	return Record{TNF: TNFWellKnown, Type: []byte("U"), Payload: payload}
+}
+
+// URI returns the URI of a well-known URI record
+func (r Record) URI() (string, bool) {
+	if r.TNF != TNFWellKnown || string(r.Type) != "U" || len(r.Payload) < 1 {
+		return "", false
+	}
+	prefix := ""
+	if int(r.Payload[0]) < len(uriPrefixes) {
+		prefix = uriPrefixes[r.Payload[0]]
+	}
+	return prefix + string(r.Payload[1:]), true
+}
+
+// NewExternal creates an NFC Forum external type record, e.g. NewExternal("example.com:loyalty", data)
+func NewExternal(typ string, payload []byte) Record {
+	return Record{TNF: TNFExternal, Type: []byte(typ), Payload: payload}
+}
+
+func (r Record) String() string {
+	if text, ok := r.Text(); ok {
+		return fmt.Sprintf("text %q", text)
+	}
+	if uri, ok := r.URI(); ok {
+		return "URI " + uri
+	}
+	switch r.TNF {
+	case TNFMedia, TNFExternal, TNFAbsoluteURI:
+		return fmt.Sprintf("%s (%d bytes)", r.Type, len(r.Payload))
+	}
+	return fmt.Sprintf("TNF %d type %q (%d bytes)", r.TNF, r.Type, len(r.Payload))
+}
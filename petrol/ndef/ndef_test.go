@@ -0,0 +1,172 @@
+package ndef
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeParseRoundTrip(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 300) // Needs a four-byte payload length
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"text", Message{NewText("en", "Hello")}},
+		{"uri", Message{NewURI("https://www.example.com/pump")}},
+		{"external", Message{NewExternal("example.com:loyalty", []byte{1, 2, 3})}},
+		{"media with ID", Message{{TNF: TNFMedia, Type: []byte("application/json"), ID: []byte("id1"), Payload: []byte(`{"a":1}`)}}},
+		{"long payload", Message{{TNF: TNFMedia, Type: []byte("text/plain"), Payload: long}}},
+		{"several records", Message{
+			NewText("en", "first"),
+			NewURI("tel:+441234567890"),
+			{TNF: TNFMedia, Type: []byte("text/plain"), Payload: long},
+			NewExternal("example.com:fill", []byte("last")),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.msg) {
+				t.Errorf("round trip gave %v, want %v", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestEncodeHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want []byte
+	}{
+		{"empty message", nil, []byte{0xD0, 0x00, 0x00}},
+		{"short text", Message{NewText("en", "Hi")}, []byte{0xD1, 0x01, 0x05, 'T', 0x02, 'e', 'n', 'H', 'i'}},
+		{"two records", Message{NewURI("http://a"), NewURI("http://b")}, []byte{
+			0x91, 0x01, 0x02, 'U', 0x03, 'a',
+			0x51, 0x01, 0x02, 'U', 0x03, 'b',
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Encode = % X, want % X", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Message{{TNF: TNFUnchanged}}).Encode(); err == nil {
+		t.Error("encoded a chunk record")
+	}
+	if _, err := (Message{{TNF: TNFMedia, Type: bytes.Repeat([]byte("t"), 256)}}).Encode(); err == nil {
+		t.Error("encoded a type longer than 255 bytes")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"nothing", nil},
+		{"short header", []byte{0xD1, 0x01}},
+		{"no message begin", []byte{0x51, 0x01, 0x01, 'T', 0x00}},
+		{"chunked", []byte{0xB1, 0x01, 0x01, 'T', 0x00}},
+		{"payload past the end", []byte{0xD1, 0x01, 0x09, 'T', 0x00}},
+		{"long length cut short", []byte{0xC1, 0x01, 0x00, 0x00}},
+		{"ID length missing", []byte{0xD9, 0x01, 0x00}},
+		{"no message end", []byte{0x91, 0x01, 0x01, 'T', 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m, err := Parse(tt.data); err == nil {
+				t.Errorf("Parse(% X) = %v, want an error", tt.data, m)
+			}
+		})
+	}
+
+	m, err := Parse([]byte{0xD0, 0x00, 0x00})
+	if err != nil || len(m) != 0 {
+		t.Errorf("empty record parsed as %v, %v; want an empty message", m, err)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+		ok      bool
+	}{
+		{"utf-8", append([]byte{0x02, 'e', 'n'}, "Grüße"...), "Grüße", true},
+		{"utf-16 big-endian", []byte{0x82, 'e', 'n', 0x00, 'H', 0x00, 'i'}, "Hi", true},
+		{"utf-16 with BOM", []byte{0x82, 'e', 'n', 0xFF, 0xFE, 'H', 0x00, 'i', 0x00}, "Hi", true},
+		{"odd utf-16", []byte{0x82, 'e', 'n', 0x00}, "", false},
+		{"language past the end", []byte{0x05, 'e', 'n'}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Record{TNF: TNFWellKnown, Type: []byte("T"), Payload: tt.payload}
+			got, ok := r.Text()
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Text() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestURIPrefixes(t *testing.T) {
+	tests := []struct {
+		uri  string
+		code byte
+	}{
+		{"https://www.example.com", 0x02},
+		{"https://example.com", 0x04},
+		{"http://www.example.com", 0x01},
+		{"tel:123", 0x05},
+		{"urn:epc:id:sgtin", 0x1E},
+		{"urn:nfc:ext", 0x23},
+		{"geo:51.5,-0.1", 0x00},
+	}
+	for _, tt := range tests {
+		r := NewURI(tt.uri)
+		if r.Payload[0] != tt.code {
+			t.Errorf("NewURI(%q) abbreviated with %#x, want %#x", tt.uri, r.Payload[0], tt.code)
+		}
+		if got, ok := r.URI(); !ok || got != tt.uri {
+			t.Errorf("URI() = %q, %v; want %q", got, ok, tt.uri)
+		}
+		if strings.HasPrefix(string(r.Payload[1:]), "https://") {
+			t.Errorf("NewURI(%q) left the scheme in the payload", tt.uri)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	msg := Message{NewText("en", "old"), NewURI("https://a"), NewText("en", "older")}
+	got := msg.Replace(NewText("en", "new"))
+	want := Message{NewText("en", "new"), NewURI("https://a")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Replace = %v, want %v", got, want)
+	}
+
+	got = Message{NewURI("https://a")}.Replace(NewExternal("example.com:fill", []byte("1")))
+	if len(got) != 2 || string(got[1].Type) != "example.com:fill" {
+		t.Errorf("Replace didn't add the new record at the end: %v", got)
+	}
+	if _, ok := got.Find(TNFExternal, "example.com:fill"); !ok {
+		t.Error("Find missed the added record")
+	}
+}
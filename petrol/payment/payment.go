@@ -0,0 +1,59 @@
+// Package payment authorises and settles card payments for fuel.
+//
+// A sale is paid in two steps: the card is authorised for the amount due,
+// then the authorised amount is captured. An authorisation that won't be
+// used is voided, and a captured payment can be refunded.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"petrol-pump/pump"
+)
+
+var (
+	// ErrDeclined means the card issuer refused the payment
+	ErrDeclined = errors.New("card declined")
+
+	// ErrTimeout means the acquirer didn't answer in time
+	ErrTimeout = errors.New("no response from acquirer")
+)
+
+// Authorisation is an acquirer's approval to take money from a card
+// Approved may be less than Requested when the card is only partially approved
+type Authorisation struct {
+	ID        string
+	CardUID   string
+	Requested pump.Money
+	Approved  pump.Money
+}
+
+// Partial reports whether less than the requested amount was approved
+func (a Authorisation) Partial() bool {
+	return a.Approved < a.Requested
+}
+
+func (a Authorisation) String() string {
+	if a.Partial() {
+		return fmt.Sprintf("%s: %s of %s approved (partial)", a.ID, a.Approved, a.Requested)
+	}
+	return fmt.Sprintf("%s: %s approved", a.ID, a.Approved)
+}
+
+// Processor is the payment processor a pump takes cards through
+//
+// Every method must give up and return ErrTimeout (possibly wrapped) once ctx
+// is done. Authorise returns ErrDeclined (possibly wrapped, with the reason)
+// when the card is refused.
+type Processor interface {
+	// Authorise asks for up to amount to be reserved on the card
+	Authorise(ctx context.Context, cardUID string, amount pump.Money) (Authorisation, error)
+	// Capture takes amount (at most the approved amount) from an authorisation
+	Capture(ctx context.Context, auth Authorisation, amount pump.Money) error
+	// Void releases an authorisation that hasn't been captured
+	Void(ctx context.Context, auth Authorisation) error
+	// Refund returns amount of a captured payment to the card
+	Refund(ctx context.Context, auth Authorisation, amount pump.Money) error
+}
@@ -0,0 +1,236 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+// Behaviour is how the simulated acquirer answers an authorisation
+type Behaviour int
+
+const (
+	Approve        Behaviour = iota // Approve the full amount
+	Decline                         // Refuse the card
+	Timeout                         // Never answer, so the caller times out
+	PartialApprove                  // Approve only part of the amount, then the rest on another card
+	Random                          // Pick one of the above for each authorisation
+)
+
+func (b Behaviour) String() string {
+	switch b {
+	case Approve:
+		return "approve"
+	case Decline:
+		return "decline"
+	case Timeout:
+		return "timeout"
+	case PartialApprove:
+		return "partial"
+	case Random:
+		return "random"
+	}
+	return "unknown"
+}
+
+// ParseBehaviour parses a behaviour name as printed by Behaviour.String
+func ParseBehaviour(name string) (Behaviour, error) {
+	for b := Approve; b <= Random; b++ {
+		if strings.EqualFold(name, b.String()) {
+			return b, nil
+		}
+	}
+	return Approve, fmt.Errorf("unknown acquirer behaviour %q (want approve, decline, timeout, partial or random)", name)
+}
+
+// DefaultLatency is how long the simulated acquirer takes to answer
+const DefaultLatency = 500 * time.Millisecond
+
+// Simulator is a local stand-in for a card acquirer, for demos and testing without a bank
+type Simulator struct {
+	Behaviour    Behaviour
+	Cards        map[string]Behaviour // Per-card overrides of Behaviour, keyed by card UID
+	PartialLimit pump.Money           // Most a partial approval covers; half the amount if zero
+	Latency      time.Duration        // Time to answer each request
+
+	mu     sync.Mutex
+	nextID int
+	auths  map[string]*simulatedAuth
+	owing  map[pump.Money]bool // What partial approvals left to pay, which is approved in full
+}
+
+// simulatedAuth tracks what has happened to an authorisation
+type simulatedAuth struct {
+	Authorisation
+	captured pump.Money
+	refunded pump.Money
+	voided   bool
+}
+
+// NewSimulator creates a simulated acquirer that always behaves as behaviour
+func NewSimulator(behaviour Behaviour) *Simulator {
+	return &Simulator{Behaviour: behaviour, Latency: DefaultLatency}
+}
+
+// Authorise implements Processor
+func (s *Simulator) Authorise(ctx context.Context, cardUID string, amount pump.Money) (Authorisation, error) {
+	if amount <= 0 {
+		return Authorisation{}, fmt.Errorf("nothing to authorise")
+	}
+
+	behaviour := s.behaviourFor(cardUID)
+	if behaviour == Timeout {
+		<-ctx.Done()
+		return Authorisation{}, fmt.Errorf("%w: %v", ErrTimeout, ctx.Err())
+	}
+	if err := s.wait(ctx); err != nil {
+		return Authorisation{}, err
+	}
+
+	approved := amount
+	switch behaviour {
+	case Decline:
+		return Authorisation{}, fmt.Errorf("%w: insufficient funds", ErrDeclined)
+	case PartialApprove:
+		approved = s.partial(amount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auths == nil {
+		s.auths = make(map[string]*simulatedAuth)
+	}
+	s.nextID++
+	auth := Authorisation{
+		ID:        fmt.Sprintf("SIM%06d", s.nextID),
+		CardUID:   cardUID,
+		Requested: amount,
+		Approved:  approved,
+	}
+	s.auths[auth.ID] = &simulatedAuth{Authorisation: auth}
+	return auth, nil
+}
+
+// Capture implements Processor
+func (s *Simulator) Capture(ctx context.Context, auth Authorisation, amount pump.Money) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.lookup(auth)
+	if err != nil {
+		return err
+	}
+	switch {
+	case a.voided:
+		return fmt.Errorf("authorisation %s was voided", auth.ID)
+	case a.captured > 0:
+		return fmt.Errorf("authorisation %s already captured", auth.ID)
+	case amount <= 0 || amount > a.Approved:
+		return fmt.Errorf("cannot capture %s of %s approved", amount, a.Approved)
+	}
+	a.captured = amount
+	if !a.Partial() {
+		delete(s.owing, a.Requested)
+	}
+	return nil
+}
+
+// Void implements Processor
+func (s *Simulator) Void(ctx context.Context, auth Authorisation) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.lookup(auth)
+	if err != nil {
+		return err
+	}
+	if a.captured > 0 {
+		return fmt.Errorf("authorisation %s already captured - refund it instead", auth.ID)
+	}
+	a.voided = true
+	return nil
+}
+
+// Refund implements Processor
+func (s *Simulator) Refund(ctx context.Context, auth Authorisation, amount pump.Money) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.lookup(auth)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 || a.refunded+amount > a.captured {
+		return fmt.Errorf("cannot refund %s of %s captured (%s already refunded)", amount, a.captured, a.refunded)
+	}
+	a.refunded += amount
+	return nil
+}
+
+// partial decides how much of amount a partial approval covers
+// Only the first card of a sale is partially approved: what it leaves to pay is remembered and
+// approved in full on the next card, or the rest would halve on every tap and never be paid
+func (s *Simulator) partial(amount pump.Money) pump.Money {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owing[amount] {
+		return amount
+	}
+	approved := s.PartialLimit
+	if approved <= 0 {
+		approved = amount / 2
+	}
+	if approved <= 0 || approved >= amount {
+		// Too little to split
+		return amount
+	}
+	if s.owing == nil {
+		s.owing = make(map[pump.Money]bool)
+	}
+	s.owing[amount-approved] = true
+	return approved
+}
+
+// behaviourFor picks how to answer for a card
+func (s *Simulator) behaviourFor(cardUID string) Behaviour {
+	s.mu.Lock()
+	behaviour, ok := s.Cards[cardUID]
+	if !ok {
+		behaviour = s.Behaviour
+	}
+	s.mu.Unlock()
+
+	if behaviour == Random {
+		return Behaviour(rand.Intn(int(Random)))
+	}
+	return behaviour
+}
+
+// wait simulates the round trip to the acquirer
+func (s *Simulator) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.Latency):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrTimeout, ctx.Err())
+	}
+}
+
+func (s *Simulator) lookup(auth Authorisation) (*simulatedAuth, error) {
+	a, ok := s.auths[auth.ID]
+	if !ok {
+		return nil, fmt.Errorf("unknown authorisation %q", auth.ID)
+	}
+	return a, nil
+}
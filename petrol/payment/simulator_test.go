@@ -0,0 +1,161 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+func TestParseBehaviour(t *testing.T) {
+	for b := Approve; b <= Random; b++ {
+		if got, err := ParseBehaviour(b.String()); err != nil || got != b {
+			t.Errorf("ParseBehaviour(%q) = %v, %v", b, got, err)
+		}
+	}
+	if _, err := ParseBehaviour("maybe"); err == nil {
+		t.Error("parsed an unknown behaviour")
+	}
+}
+
+func TestSimulatorAuthorise(t *testing.T) {
+	tests := []struct {
+		name      string
+		behaviour Behaviour
+		limit     pump.Money
+		amount    pump.Money
+		approved  pump.Money
+		wantErr   error
+	}{
+		{"approve", Approve, 0, 5836, 5836, nil},
+		{"decline", Decline, 0, 5836, 0, ErrDeclined},
+		{"timeout", Timeout, 0, 5836, 0, ErrTimeout},
+		{"partial, half", PartialApprove, 0, 5836, 2918, nil},
+		{"partial, odd amount", PartialApprove, 0, 5837, 2918, nil},
+		{"partial, up to the limit", PartialApprove, 2000, 5836, 2000, nil},
+		{"partial, under the limit", PartialApprove, 2000, 1500, 1500, nil},
+		{"partial, 1p", PartialApprove, 0, 1, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Simulator{Behaviour: tt.behaviour, PartialLimit: tt.limit}
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			auth, err := s.Authorise(ctx, "A1", tt.amount)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Authorise error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth.Requested != tt.amount || auth.Approved != tt.approved || auth.Partial() != (tt.approved < tt.amount) {
+				t.Errorf("Authorise = %v, want %s of %s approved", auth, tt.approved, tt.amount)
+			}
+		})
+	}
+}
+
+func TestSimulatorCardOverrides(t *testing.T) {
+	s := &Simulator{Behaviour: Approve, Cards: map[string]Behaviour{"B2": Decline}}
+	if _, err := s.Authorise(context.Background(), "A1", 100); err != nil {
+		t.Errorf("A1: %v", err)
+	}
+	if _, err := s.Authorise(context.Background(), "B2", 100); !errors.Is(err, ErrDeclined) {
+		t.Errorf("B2: %v, want ErrDeclined", err)
+	}
+}
+
+// A partially approved sale is paid off by the next card, however small the rest
+func TestSimulatorPartialSaleIsPaid(t *testing.T) {
+	for _, due := range []pump.Money{1, 2, 3, 999, 5836} {
+		s := NewSimulator(PartialApprove)
+		s.Latency = 0
+		ctx := context.Background()
+		left := due
+		for card := 0; left > 0; card++ {
+			if card == 2 {
+				t.Fatalf("%s sale: %s still due after two cards", due, left)
+			}
+			auth, err := s.Authorise(ctx, string(rune('A'+card)), left)
+			if err != nil {
+				t.Fatalf("%s sale, card %d for %s: %v", due, card+1, left, err)
+			}
+			if err := s.Capture(ctx, auth, auth.Approved); err != nil {
+				t.Fatal(err)
+			}
+			left -= auth.Approved
+		}
+
+		// The next sale is partially approved again
+		if due > 1 {
+			if auth, _ := s.Authorise(ctx, "C", due); !auth.Partial() {
+				t.Errorf("%s sale after a paid one: %v, want a partial approval", due, auth)
+			}
+		}
+	}
+}
+
+func TestSimulatorSettlement(t *testing.T) {
+	ctx := context.Background()
+	s := NewSimulator(Approve)
+	s.Latency = 0
+	auth, err := s.Authorise(ctx, "A1", 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Refund(ctx, auth, 100); err == nil {
+		t.Error("refunded an authorisation that wasn't captured")
+	}
+	if err := s.Capture(ctx, auth, 3001); err == nil {
+		t.Error("captured more than was approved")
+	}
+	if err := s.Capture(ctx, auth, 0); err == nil {
+		t.Error("captured nothing")
+	}
+	if err := s.Capture(ctx, auth, 2500); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Capture(ctx, auth, 500); err == nil {
+		t.Error("captured twice")
+	}
+	if err := s.Void(ctx, auth); err == nil {
+		t.Error("voided a captured authorisation")
+	}
+	if err := s.Refund(ctx, auth, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Refund(ctx, auth, 501); err == nil {
+		t.Error("refunded more than was captured")
+	}
+	if err := s.Refund(ctx, auth, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err = s.Authorise(ctx, "A1", 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Void(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Capture(ctx, auth, 3000); err == nil {
+		t.Error("captured a voided authorisation")
+	}
+	if err := s.Capture(ctx, Authorisation{ID: "SIM999999"}, 100); err == nil {
+		t.Error("captured an authorisation the simulator never issued")
+	}
+
+	// Every request waits for the acquirer, and gives up with the context
+	s.Latency = time.Hour
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Refund(cctx, auth, 100); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Refund with no answer = %v, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,178 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"petrol-pump/pump"
+)
+
+var (
+	// ErrInsufficientFunds means a stored-value card doesn't hold enough for the sale
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrNoWallet means the card has no stored value on it, so it has to pay some other way
+	ErrNoWallet = errors.New("not a wallet card")
+)
+
+// BalanceError is returned when a stored-value card's balance is too low
+// It matches both ErrInsufficientFunds and ErrDeclined
+type BalanceError struct {
+	Balance pump.Money
+	Due     pump.Money
+}
+
+func (e *BalanceError) Error() string {
+	return fmt.Sprintf("%v: balance %s, %s due", ErrInsufficientFunds, e.Balance, e.Due)
+}
+
+func (e *BalanceError) Unwrap() []error {
+	return []error{ErrInsufficientFunds, ErrDeclined}
+}
+
+// ValueStore holds the balances of stored-value cards, e.g. on the cards themselves
+type ValueStore interface {
+	// Balance returns the money held for a card, or ErrNoWallet (possibly wrapped) if it has none
+	Balance(cardUID string) (pump.Money, error)
+	// Debit takes amount from a card and returns what is left
+	// It returns a *BalanceError without taking anything if the balance is too low; any error means nothing was taken
+	Debit(cardUID string, amount pump.Money) (pump.Money, error)
+	// Credit adds amount to a card and returns the new balance
+	Credit(cardUID string, amount pump.Money) (pump.Money, error)
+}
+
+// Wallet is a Processor that pays from stored-value cards rather than a bank
+//
+// Nothing is reserved at authorisation: the balance is checked, and the
+// money only leaves the card when the authorisation is captured. The card
+// has to stay on the reader until then, and again for a refund.
+type Wallet struct {
+	Cards ValueStore
+
+	mu     sync.Mutex
+	nextID int
+	auths  map[string]*walletAuth
+}
+
+// walletAuth tracks what has happened to an authorisation
+type walletAuth struct {
+	Authorisation
+	captured pump.Money
+	refunded pump.Money
+	voided   bool
+}
+
+// NewWallet creates a processor that pays from the cards in store
+func NewWallet(store ValueStore) *Wallet {
+	return &Wallet{Cards: store}
+}
+
+// Authorise implements Processor
+// A card holding less than amount is refused with a *BalanceError
+func (w *Wallet) Authorise(ctx context.Context, cardUID string, amount pump.Money) (Authorisation, error) {
+	if amount <= 0 {
+		return Authorisation{}, fmt.Errorf("nothing to authorise")
+	}
+	if err := ctx.Err(); err != nil {
+		return Authorisation{}, fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	balance, err := w.Cards.Balance(cardUID)
+	if err != nil {
+		return Authorisation{}, err
+	}
+	if balance < amount {
+		return Authorisation{}, &BalanceError{Balance: balance, Due: amount}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.auths == nil {
+		w.auths = make(map[string]*walletAuth)
+	}
+	w.nextID++
+	auth := Authorisation{
+		ID:        fmt.Sprintf("WAL%06d", w.nextID),
+		CardUID:   cardUID,
+		Requested: amount,
+		Approved:  amount,
+	}
+	w.auths[auth.ID] = &walletAuth{Authorisation: auth}
+	return auth, nil
+}
+
+// Capture implements Processor by taking amount off the card
+func (w *Wallet) Capture(ctx context.Context, auth Authorisation, amount pump.Money) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, err := w.lookup(auth)
+	if err != nil {
+		return err
+	}
+	switch {
+	case a.voided:
+		return fmt.Errorf("authorisation %s was voided", auth.ID)
+	case a.captured > 0:
+		return fmt.Errorf("authorisation %s already captured", auth.ID)
+	case amount <= 0 || amount > a.Approved:
+		return fmt.Errorf("cannot capture %s of %s approved", amount, a.Approved)
+	}
+	balance, err := w.Cards.Debit(auth.CardUID, amount)
+	if err != nil {
+		return err
+	}
+	a.captured = amount
+	fmt.Printf("ℹ Card %s: %s taken, %s left\n", auth.CardUID, amount, balance)
+	return nil
+}
+
+// Void implements Processor - nothing was reserved, so this only closes the authorisation
+func (w *Wallet) Void(ctx context.Context, auth Authorisation) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, err := w.lookup(auth)
+	if err != nil {
+		return err
+	}
+	if a.captured > 0 {
+		return fmt.Errorf("authorisation %s already captured - refund it instead", auth.ID)
+	}
+	a.voided = true
+	return nil
+}
+
+// Refund implements Processor by putting amount back on the card
+func (w *Wallet) Refund(ctx context.Context, auth Authorisation, amount pump.Money) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, err := w.lookup(auth)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 || a.refunded+amount > a.captured {
+		return fmt.Errorf("cannot refund %s of %s captured (%s already refunded)", amount, a.captured, a.refunded)
+	}
+	balance, err := w.Cards.Credit(auth.CardUID, amount)
+	if err != nil {
+		return err
+	}
+	a.refunded += amount
+	fmt.Printf("ℹ Card %s: %s refunded, balance %s\n", auth.CardUID, amount, balance)
+	return nil
+}
+
+// lookup finds an authorisation this wallet issued (w.mu must be held)
+func (w *Wallet) lookup(auth Authorisation) (*walletAuth, error) {
+	a, ok := w.auths[auth.ID]
+	if !ok || a.CardUID != auth.CardUID {
+		return nil, fmt.Errorf("unknown authorisation %s", auth.ID)
+	}
+	return a, nil
+}
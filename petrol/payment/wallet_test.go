@@ -0,0 +1,144 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"petrol-pump/pump"
+)
+
+// memoryStore keeps wallet balances in memory
+type memoryStore map[string]pump.Money
+
+func (m memoryStore) Balance(cardUID string) (pump.Money, error) {
+	balance, ok := m[cardUID]
+	if !ok {
+		return 0, ErrNoWallet
+	}
+	return balance, nil
+}
+
+func (m memoryStore) Debit(cardUID string, amount pump.Money) (pump.Money, error) {
+	balance, err := m.Balance(cardUID)
+	if err != nil {
+		return 0, err
+	}
+	if balance < amount {
+		return balance, &BalanceError{Balance: balance, Due: amount}
+	}
+	m[cardUID] = balance - amount
+	return m[cardUID], nil
+}
+
+func (m memoryStore) Credit(cardUID string, amount pump.Money) (pump.Money, error) {
+	balance, err := m.Balance(cardUID)
+	if err != nil {
+		return 0, err
+	}
+	m[cardUID] = balance + amount
+	return m[cardUID], nil
+}
+
+func TestWalletAuthorise(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    string
+		amount  pump.Money
+		wantErr []error
+	}{
+		{"enough", "A1", 2000, nil},
+		{"exactly enough", "A1", 5000, nil},
+		{"too little", "A1", 5001, []error{ErrInsufficientFunds, ErrDeclined}},
+		{"not a wallet", "B2", 100, []error{ErrNoWallet}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWallet(memoryStore{"A1": 5000})
+			auth, err := w.Authorise(context.Background(), tt.card, tt.amount)
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("Authorise error = %v, want %v", err, want)
+				}
+			}
+			if tt.wantErr == nil && (err != nil || auth.Approved != tt.amount || auth.Partial()) {
+				t.Errorf("Authorise = %v, %v; want %s approved", auth, err, tt.amount)
+			}
+		})
+	}
+
+	w := NewWallet(memoryStore{"A1": 5000})
+	if _, err := w.Authorise(context.Background(), "A1", 0); err == nil {
+		t.Error("authorised nothing")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.Authorise(ctx, "A1", 100); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Authorise after the context ended = %v, want ErrTimeout", err)
+	}
+}
+
+func TestWalletSettlement(t *testing.T) {
+	ctx := context.Background()
+	store := memoryStore{"A1": 5000}
+	w := NewWallet(store)
+	auth, err := w.Authorise(ctx, "A1", 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store["A1"] != 5000 {
+		t.Errorf("authorising took money: balance %s", store["A1"])
+	}
+
+	if err := w.Capture(ctx, auth, 3001); err == nil {
+		t.Error("captured more than was approved")
+	}
+	if err := w.Capture(ctx, auth, 1875); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Capture(ctx, auth, 1875); err == nil {
+		t.Error("captured twice")
+	}
+	if err := w.Void(ctx, auth); err == nil {
+		t.Error("voided a captured authorisation")
+	}
+	if store["A1"] != 3125 {
+		t.Errorf("balance %s after the capture, want £31.25", store["A1"])
+	}
+
+	if err := w.Refund(ctx, auth, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Refund(ctx, auth, 876); err == nil {
+		t.Error("refunded more than was captured")
+	}
+	if err := w.Refund(ctx, auth, 875); err != nil {
+		t.Fatal(err)
+	}
+	if store["A1"] != 5000 {
+		t.Errorf("balance %s after refunding it all, want £50.00", store["A1"])
+	}
+
+	// The card was emptied elsewhere between authorising and capturing
+	auth, err = w.Authorise(ctx, "A1", 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store["A1"] = 500
+	if err := w.Capture(ctx, auth, 2000); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Capture from an emptied card = %v, want ErrInsufficientFunds", err)
+	}
+	if err := w.Void(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Capture(ctx, auth, 100); err == nil {
+		t.Error("captured a voided authorisation")
+	}
+
+	if err := w.Capture(ctx, Authorisation{ID: "WAL999999", CardUID: "A1"}, 100); err == nil {
+		t.Error("captured an authorisation the wallet never issued")
+	}
+	if err := w.Capture(ctx, Authorisation{ID: auth.ID, CardUID: "B2"}, 100); err == nil {
+		t.Error("captured an authorisation against another card")
+	}
+}
@@ -0,0 +1,269 @@
+// Package pn532 drives an NXP PN532 NFC controller as an ISO/IEC 14443 type A reader.
+//
+// The host talks to the PN532 in frames (NXP UM0701-02 section 6.2): the
+// host sends a command, the PN532 acknowledges it, then sends its answer.
+// The frames are the same over every host interface; this package carries
+// them over HSU, the PN532's UART, which is what most breakout boards
+// default to. Anything that reads and writes bytes with a read deadline
+// will do as the port, so a pseudo-terminal can stand in for the board.
+package pn532
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"petrol-pump/serial"
+)
+
+// Commands (UM0701-02 section 7)
+const (
+	cmdGetFirmwareVersion  = 0x02
+	cmdSAMConfiguration    = 0x14
+	cmdRFConfiguration     = 0x32
+	cmdInListPassiveTarget = 0x4A
+	cmdInRelease           = 0x52
+)
+
+// Frame bytes
+const (
+	hostToPN532 = 0xD4 // TFI of a command
+	pn532ToHost = 0xD5 // TFI of an answer
+	errorFrame  = 0x7F // TFI of an application error frame
+)
+
+// DefaultBaud is the HSU speed the PN532 starts at
+const DefaultBaud = 115200
+
+// How long the PN532 takes to acknowledge a command, and the longest any answer should take
+const (
+	ackTimeout    = 100 * time.Millisecond
+	answerTimeout = time.Second
+)
+
+var (
+	// ErrNoAck means the PN532 didn't acknowledge a command - it isn't there, or isn't awake
+	ErrNoAck = errors.New("PN532 did not acknowledge")
+
+	ack  = []byte{0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00}
+	nack = []byte{0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00}
+)
+
+// Port is a connection to the PN532's host interface
+type Port interface {
+	io.ReadWriteCloser
+	SetReadDeadline(t time.Time) error
+}
+
+// Device is a PN532 ready to read cards
+type Device struct {
+	port Port
+	in   *bufio.Reader
+}
+
+// Firmware is what GetFirmwareVersion reports
+type Firmware struct {
+	IC       byte // 0x32 for a PN532
+	Version  byte
+	Revision byte
+	Support  byte // Bit 0 type A, bit 1 type B, bit 2 ISO 18092
+}
+
+func (f Firmware) String() string {
+	return fmt.Sprintf("PN5%02X firmware %d.%d", f.IC, f.Version, f.Revision)
+}
+
+// Target is a card the PN532 has found and selected
+type Target struct {
+	Number byte    // Logical number the PN532 gave it
+	ATQA   [2]byte // SENS_RES, least significant byte first
+	SAK    byte    // SEL_RES
+	UID    []byte  // NFCID1: 4, 7 or 10 bytes
+	ATS    []byte  // Answer to select, if the card is ISO-DEP
+}
+
+// OpenHSU opens a PN532 on a serial port, such as /dev/ttyS0 or /dev/ttyUSB0
+func OpenHSU(path string, baud int) (*Device, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("PN532: %w", err)
+	}
+	if err := serial.Configure(file, baud); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("PN532 %s: %w", path, err)
+	}
+	d, err := New(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("PN532 %s: %w", path, err)
+	}
+	return d, nil
+}
+
+// New wakes a PN532 on port and sets it up to read cards
+func New(port Port) (*Device, error) {
+	d := &Device{port: port, in: bufio.NewReader(port)}
+
+	// Out of power-down, HSU wants a long preamble before the first command (UM0701-02 section 6.2.2)
+	wake := append([]byte{0x55, 0x55}, make([]byte, 14)...)
+	if _, err := port.Write(wake); err != nil {
+		return nil, err
+	}
+	// Normal mode, no secure access module
+	if _, err := d.command(cmdSAMConfiguration, []byte{0x01, 0x14, 0x01}, answerTimeout); err != nil {
+		return nil, fmt.Errorf("SAMConfiguration: %w", err)
+	}
+	return d, nil
+}
+
+// Close closes the port
+func (d *Device) Close() error {
+	return d.port.Close()
+}
+
+// FirmwareVersion asks the PN532 which chip and firmware it is
+func (d *Device) FirmwareVersion() (Firmware, error) {
+	resp, err := d.command(cmdGetFirmwareVersion, nil, answerTimeout)
+	if err != nil {
+		return Firmware{}, err
+	}
+	if len(resp) != 4 {
+		return Firmware{}, fmt.Errorf("firmware version: % X", resp)
+	}
+	return Firmware{IC: resp[0], Version: resp[1], Revision: resp[2], Support: resp[3]}, nil
+}
+
+// SetPassiveRetries sets how many times InListPassiveTarget looks for a card before giving up
+// 0xFF looks forever; the PN532 starts at 0xFF, which would never answer with no card about
+func (d *Device) SetPassiveRetries(retries byte) error {
+	// Item 5: MxRtyATR, MxRtyPSL, MxRtyPassiveActivation
+	_, err := d.command(cmdRFConfiguration, []byte{0x05, 0xFF, 0x01, retries}, answerTimeout)
+	return err
+}
+
+// ListPassiveTarget looks for one type A card at 106 kbit/s and selects it
+// ISO-DEP cards are activated as far as RATS, and their ATS is returned
+// found is false if no card answered within the passive retries
+func (d *Device) ListPassiveTarget() (target Target, found bool, err error) {
+	resp, err := d.command(cmdInListPassiveTarget, []byte{0x01, 0x00}, answerTimeout)
+	if err != nil {
+		return Target{}, false, err
+	}
+	if len(resp) < 1 || resp[0] == 0 {
+		return Target{}, false, nil
+	}
+
+	// NbTg, then Tg, SENS_RES (2), SEL_RES, NFCIDLength, NFCID1, [ATS]
+	data := resp[1:]
+	if len(data) < 5 || len(data) < 5+int(data[4]) {
+		return Target{}, false, fmt.Errorf("short target data % X", resp)
+	}
+	target = Target{
+		Number: data[0],
+		ATQA:   [2]byte{data[2], data[1]}, // The PN532 gives SENS_RES most significant byte first
+		SAK:    data[3],
+		UID:    append([]byte(nil), data[5:5+int(data[4])]...),
+	}
+	if rest := data[5+int(data[4]):]; len(rest) > 0 {
+		target.ATS = append([]byte(nil), rest...)
+	}
+	return target, true, nil
+}
+
+// Release deselects every target, so the PN532 can be asked for cards afresh
+func (d *Device) Release() error {
+	_, err := d.command(cmdInRelease, []byte{0x00}, answerTimeout)
+	return err
+}
+
+// command sends a command and returns the data of its answer
+func (d *Device) command(cmd byte, params []byte, timeout time.Duration) ([]byte, error) {
+	// Anything left over from an earlier command that timed out would be taken for this one's answer
+	d.in.Reset(d.port)
+
+	if _, err := d.port.Write(encodeFrame(append([]byte{hostToPN532, cmd}, params...))); err != nil {
+		return nil, err
+	}
+
+	d.port.SetReadDeadline(time.Now().Add(ackTimeout))
+	frame, err := d.readFrame()
+	if err != nil || frame != nil {
+		return nil, fmt.Errorf("command %02X: %w", cmd, ErrNoAck)
+	}
+
+	d.port.SetReadDeadline(time.Now().Add(timeout))
+	frame, err = d.readFrame()
+	if err != nil {
+		return nil, fmt.Errorf("command %02X: %w", cmd, err)
+	}
+	d.port.Write(ack) // Tells the PN532 the answer arrived
+	switch {
+	case len(frame) == 1 && frame[0] == errorFrame:
+		return nil, fmt.Errorf("command %02X: PN532 reported an error", cmd)
+	case len(frame) < 2 || frame[0] != pn532ToHost || frame[1] != cmd+1:
+		return nil, fmt.Errorf("command %02X: unexpected answer % X", cmd, frame)
+	}
+	return frame[2:], nil
+}
+
+// encodeFrame wraps data (TFI onwards) in a normal information frame
+func encodeFrame(data []byte) []byte {
+	frame := []byte{0x00, 0x00, 0xFF, byte(len(data)), byte(-len(data))}
+	frame = append(frame, data...)
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return append(frame, -sum, 0x00)
+}
+
+// readFrame reads the next frame, skipping anything before its start code
+// An ACK frame is returned as nil data, anything else as its TFI and data
+func (d *Device) readFrame() ([]byte, error) {
+	// Start code 00 FF
+	var prev byte = 0xFF
+	for {
+		b, err := d.in.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if prev == 0x00 && b == 0xFF {
+			break
+		}
+		prev = b
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(d.in, header); err != nil {
+		return nil, err
+	}
+	switch {
+	case header[0] == 0x00 && header[1] == 0xFF:
+		d.in.ReadByte() // Postamble
+		return nil, nil
+	case header[0] == 0xFF && header[1] == 0x00:
+		return nil, fmt.Errorf("PN532 sent NACK")
+	case header[0] == 0xFF && header[1] == 0xFF:
+		return nil, fmt.Errorf("extended frames are not supported")
+	case header[0]+header[1] != 0:
+		return nil, fmt.Errorf("frame length checksum mismatch (% X)", header)
+	}
+
+	body := make([]byte, int(header[0])+2) // Data, DCS, postamble
+	if _, err := io.ReadFull(d.in, body); err != nil {
+		return nil, err
+	}
+	data := body[:header[0]]
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	if sum+body[header[0]] != 0 {
+		d.port.Write(nack)
+		return nil, fmt.Errorf("frame data checksum mismatch")
+	}
+	return data, nil
+}
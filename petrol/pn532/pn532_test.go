@@ -0,0 +1,249 @@
+package pn532
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// fakePN532 answers commands the way a PN532 does over HSU, from the other end of a port
+type fakePN532 struct {
+	mu       sync.Mutex
+	silent   bool     // Don't acknowledge anything
+	corrupt  bool     // Spoil the checksum of every answer
+	target   []byte   // InListPassiveTarget answer after NbTg, nil for no card
+	commands [][]byte // Every command received, TFI onwards
+	nacks    int      // NACK frames received
+}
+
+// serve reads frames from conn and answers them until conn is closed
+func (f *fakePN532) serve(conn io.ReadWriter) {
+	in := bufio.NewReader(conn)
+	for {
+		data, ok, err := readHostFrame(in)
+		if err != nil {
+			return
+		}
+		if !ok {
+			// ACK or NACK from the host
+			if data != nil {
+				f.mu.Lock()
+				f.nacks++
+				f.mu.Unlock()
+			}
+			continue
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, data)
+		silent, corrupt, target := f.silent, f.corrupt, f.target
+		f.mu.Unlock()
+		if silent || len(data) < 2 || data[0] != hostToPN532 {
+			continue
+		}
+
+		conn.Write(ack)
+		answer := []byte{pn532ToHost, data[1] + 1}
+		switch data[1] {
+		case cmdGetFirmwareVersion:
+			answer = append(answer, 0x32, 0x01, 0x06, 0x07)
+		case cmdSAMConfiguration, cmdRFConfiguration:
+		case cmdInListPassiveTarget:
+			if target == nil {
+				answer = append(answer, 0x00)
+			} else {
+				answer = append(append(answer, 0x01), target...)
+			}
+		case cmdInRelease:
+			answer = append(answer, 0x00)
+		default:
+			answer = []byte{errorFrame}
+		}
+		frame := encodeFrame(answer)
+		if corrupt {
+			frame[len(frame)-2]++
+		}
+		conn.Write(frame)
+	}
+}
+
+// readHostFrame reads a frame sent by the host, skipping the wake-up preamble
+// ok is false for an ACK (data nil) or a NACK (data empty)
+func readHostFrame(in *bufio.Reader) (data []byte, ok bool, err error) {
+	var prev byte = 0xFF
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if prev == 0x00 && b == 0xFF {
+			break
+		}
+		prev = b
+	}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, false, err
+	}
+	if header[0] == 0x00 && header[1] == 0xFF || header[0] == 0xFF && header[1] == 0x00 {
+		in.ReadByte()
+		if header[0] == 0xFF {
+			return []byte{}, false, nil
+		}
+		return nil, false, nil
+	}
+	body := make([]byte, int(header[0])+2)
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, false, err
+	}
+	return body[:header[0]], true, nil
+}
+
+func (f *fakePN532) sent() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.commands...)
+}
+
+// newFake connects a fake PN532 to one end of a pipe, returning the other end for the Device
+func newFake(t *testing.T, f *fakePN532) Port {
+	host, board := net.Pipe()
+	go f.serve(board)
+	t.Cleanup(func() {
+		host.Close()
+		board.Close()
+	})
+	return host
+}
+
+func TestEncodeFrame(t *testing.T) {
+	// GetFirmwareVersion as given in UM0701-02
+	want := []byte{0x00, 0x00, 0xFF, 0x02, 0xFE, 0xD4, 0x02, 0x2A, 0x00}
+	if got := encodeFrame([]byte{hostToPN532, cmdGetFirmwareVersion}); !bytes.Equal(got, want) {
+		t.Errorf("encodeFrame = % X, want % X", got, want)
+	}
+}
+
+func TestNewAndFirmware(t *testing.T) {
+	fake := &fakePN532{}
+	d, err := New(newFake(t, fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, err := d.FirmwareVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw != (Firmware{IC: 0x32, Version: 1, Revision: 6, Support: 7}) || fw.String() != "PN532 firmware 1.6" {
+		t.Errorf("firmware %+v (%s)", fw, fw)
+	}
+	if err := d.SetPassiveRetries(2); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.sent()
+	want := [][]byte{
+		{hostToPN532, cmdSAMConfiguration, 0x01, 0x14, 0x01},
+		{hostToPN532, cmdGetFirmwareVersion},
+		{hostToPN532, cmdRFConfiguration, 0x05, 0xFF, 0x01, 0x02},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("sent %d commands, want %d: % X", len(sent), len(want), sent)
+	}
+	for i := range want {
+		if !bytes.Equal(sent[i], want[i]) {
+			t.Errorf("command %d = % X, want % X", i, sent[i], want[i])
+		}
+	}
+}
+
+func TestListPassiveTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  []byte
+		want    Target
+		found   bool
+		wantErr bool
+	}{
+		{"no card", nil, Target{}, false, false},
+		{
+			"MIFARE Classic",
+			[]byte{0x01, 0x00, 0x04, 0x08, 0x04, 0xDE, 0xAD, 0xBE, 0xEF},
+			Target{Number: 1, ATQA: [2]byte{0x04, 0x00}, SAK: 0x08, UID: []byte{0xDE, 0xAD, 0xBE, 0xEF}},
+			true, false,
+		},
+		{
+			"ISO-DEP with ATS",
+			[]byte{0x01, 0x03, 0x44, 0x20, 0x07, 0x04, 0xA2, 0x3B, 0x11, 0x22, 0x33, 0x44, 0x05, 0x78, 0x80, 0x70, 0x02},
+			Target{Number: 1, ATQA: [2]byte{0x44, 0x03}, SAK: 0x20, UID: []byte{0x04, 0xA2, 0x3B, 0x11, 0x22, 0x33, 0x44}, ATS: []byte{0x05, 0x78, 0x80, 0x70, 0x02}},
+			true, false,
+		},
+		{"UID cut short", []byte{0x01, 0x00, 0x04, 0x08, 0x07, 0x04, 0xA2}, Target{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePN532{target: tt.target}
+			d, err := New(newFake(t, fake))
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, found, err := d.ListPassiveTarget()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if found != tt.found || !equalTargets(target, tt.want) {
+				t.Errorf("ListPassiveTarget = %+v, %v; want %+v, %v", target, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func equalTargets(a, b Target) bool {
+	return a.Number == b.Number && a.ATQA == b.ATQA && a.SAK == b.SAK && bytes.Equal(a.UID, b.UID) && bytes.Equal(a.ATS, b.ATS)
+}
+
+func TestNoAck(t *testing.T) {
+	_, err := New(newFake(t, &fakePN532{silent: true}))
+	if !errors.Is(err, ErrNoAck) {
+		t.Errorf("New with nothing answering = %v, want ErrNoAck", err)
+	}
+}
+
+func TestErrorFrame(t *testing.T) {
+	fake := &fakePN532{}
+	d, err := New(newFake(t, fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.command(0x60, nil, answerTimeout); err == nil {
+		t.Error("an application error frame was taken for an answer")
+	}
+	// The device carries on afterwards
+	if _, err := d.FirmwareVersion(); err != nil {
+		t.Errorf("after an error frame: %v", err)
+	}
+}
+
+func TestBadChecksum(t *testing.T) {
+	fake := &fakePN532{}
+	d, err := New(newFake(t, fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	fake.corrupt = true
+	fake.mu.Unlock()
+	if _, err := d.FirmwareVersion(); err == nil {
+		t.Fatal("accepted an answer with a bad checksum")
+	}
+	d.FirmwareVersion() // Gives the fake time to see the NACK
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.nacks == 0 {
+		t.Error("no NACK sent for a bad checksum")
+	}
+}
@@ -0,0 +1,74 @@
+package pn532
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a pseudo-terminal, returning its master and the path of its slave
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		t.Skipf("unlocking pseudo-terminal: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Skipf("pseudo-terminal number: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// OpenHSU sets the port up and talks to the board through a real tty, with a pseudo-terminal for the board
+func TestOpenHSUOverPTY(t *testing.T) {
+	master, slave := openPTY(t)
+	fake := &fakePN532{target: []byte{0x01, 0x00, 0x04, 0x08, 0x04, 0xDE, 0xAD, 0xBE, 0xEF}}
+	go fake.serve(master)
+	defer master.Close()
+
+	d, err := OpenHSU(slave, DefaultBaud)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.FirmwareVersion(); err != nil {
+		t.Fatal(err)
+	}
+	target, found, err := d.ListPassiveTarget()
+	if err != nil || !found || fmt.Sprintf("% X", target.UID) != "DE AD BE EF" {
+		t.Errorf("ListPassiveTarget = %+v, %v, %v", target, found, err)
+	}
+	if err := d.Release(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenHSUNoBoard(t *testing.T) {
+	master, slave := openPTY(t)
+	defer master.Close()
+	go func() {
+		// Swallow everything, like a port with nothing on the end
+		buf := make([]byte, 64)
+		for {
+			if _, err := master.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	if d, err := OpenHSU(slave, DefaultBaud); err == nil {
+		d.Close()
+		t.Fatal("opened a PN532 that isn't there")
+	}
+	if _, err := OpenHSU(slave, 12345); err == nil {
+		t.Error("opened a port at an unsupported baud rate")
+	}
+}
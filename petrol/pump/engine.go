@@ -0,0 +1,559 @@
+// Package pump contains the headless petrol pump engine: the transaction
+// state machine, the running totals, the fuel grades and their prices. Front-ends (the Fyne
+// display, tests, other programs) drive it through its methods and follow
+// it by subscribing to events.
+package pump
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// Pump settings
+	MinPricePerLitre UnitPrice = 1400 // Minimum unleaded price per litre (140.0p)
+	MaxPricePerLitre UnitPrice = 1600 // Maximum unleaded price per litre (160.0p)
+
+	// maxPriceHistory limits how many price changes are kept in memory
+	maxPriceHistory = 100
+)
+
+// Engine is a single pump: it owns the state machine and the totals for the current sale
+type Engine struct {
+	mu            sync.Mutex
+	volume        Volume
+	amount        Money
+	pricePerLitre UnitPrice // Price of the selected grade, fixed for the sale once authorised
+	grades        []*gradeState
+	selected      int // Index into grades of the grade being sold
+	prices        PriceSource
+	priceHistory  []PriceChange
+	tanks         *Tanks // Shared storage tanks, nil for unlimited fuel
+	preset        Preset
+	started       time.Time // When the current sale was authorised
+	cardUID       string    // Card that paid for the current sale
+	flow          FlowCurve
+	meter         flowMeter
+	locked        bool // Locked pumps refuse new sales
+	state         *StateMachine
+	observers     observers
+}
+
+// New creates an idle engine selling grades, each priced by RandomPrices until SetPriceSource is called
+// The first grade is selected; DefaultGrades is used if grades is empty
+func New(grades []Grade) *Engine {
+	if len(grades) == 0 {
+		grades = DefaultGrades()
+	}
+	e := &Engine{
+		flow:   DefaultFlowCurve(),
+		prices: RandomPrices{},
+		state:  NewStateMachine(),
+	}
+	for _, g := range grades {
+		e.grades = append(e.grades, &gradeState{grade: g, price: g.randomPrice()})
+	}
+	e.pricePerLitre = e.grades[0].price
+	e.registerStateHooks()
+	return e
+}
+
+// registerStateHooks publishes transitions and clears the sale on entry to idle
+func (e *Engine) registerStateHooks() {
+	for s := range validTransitions {
+		e.state.OnEnter(s, func(ev StateEvent) {
+			e.observers.notify(Event{Type: EventStateChanged, Snapshot: e.Snapshot(), Transition: ev})
+		})
+	}
+
+	// A sale starts when it is authorised
+	e.state.OnEnter(StateAuthorised, func(ev StateEvent) {
+		e.mu.Lock()
+		e.started = ev.At
+		e.mu.Unlock()
+	})
+
+	// Opening the nozzle starts the flow meter (and the ramp-up) from now
+	e.state.OnEnter(StatePumping, func(ev StateEvent) {
+		e.mu.Lock()
+		e.meter.open(ev.At)
+		e.mu.Unlock()
+	})
+
+	// Finished sales are added to the grade's totaliser and published
+	e.state.OnEnter(StatePaid, func(ev StateEvent) {
+		e.finishSale(OutcomePaid, ev.At)
+	})
+	e.state.OnEnter(StateCancelled, func(ev StateEvent) {
+		e.finishSale(OutcomeCancelled, ev.At)
+	})
+
+	// Entering idle starts a fresh sale with new prices
+	e.state.OnEnter(StateIdle, func(StateEvent) {
+		e.clearSale()
+	})
+}
+
+// SetFlowCurve changes how fast the pump dispenses
+func (e *Engine) SetFlowCurve(curve FlowCurve) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flow = curve
+}
+
+// SetPriceSource changes where prices come from
+// The new prices take effect straight away if the pump is idle, otherwise after the current sale
+func (e *Engine) SetPriceSource(src PriceSource) {
+	e.mu.Lock()
+	e.prices = src
+	e.mu.Unlock()
+	e.RefreshPrices(time.Now())
+}
+
+// RefreshPrices asks the price source for new prices
+// Prices only ever change between transactions, so this does nothing unless the pump is idle
+func (e *Engine) RefreshPrices(now time.Time) {
+	if !e.state.Is(StateIdle) {
+		return
+	}
+	e.mu.Lock()
+	if !e.state.Is(StateIdle) {
+		e.mu.Unlock()
+		return
+	}
+	changed := e.refreshPricesLocked(now)
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	if changed {
+		e.observers.notify(Event{Type: EventPriceChanged, Snapshot: snap})
+	}
+}
+
+// refreshPricesLocked updates every grade from the price source, logging each change
+// A grade whose price can't be fetched keeps its previous price
+func (e *Engine) refreshPricesLocked(now time.Time) bool {
+	changed := false
+	for _, g := range e.grades {
+		price, err := e.prices.Price(g.grade, now)
+		if err != nil {
+			fmt.Printf("⚠ Keeping %s at %s: %v\n", g.grade.Name, g.price, err)
+			continue
+		}
+		if price == g.price {
+			continue
+		}
+		change := PriceChange{Grade: g.grade.Name, Old: g.price, New: price, Effective: now}
+		fmt.Printf("ℹ Price change: %s\n", change)
+		e.priceHistory = append(e.priceHistory, change)
+		if len(e.priceHistory) > maxPriceHistory {
+			e.priceHistory = e.priceHistory[len(e.priceHistory)-maxPriceHistory:]
+		}
+		g.price = price
+		changed = true
+	}
+	e.pricePerLitre = e.grades[e.selected].price
+	return changed
+}
+
+// PriceHistory returns the recorded price changes, oldest first
+func (e *Engine) PriceHistory() []PriceChange {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]PriceChange(nil), e.priceHistory...)
+}
+
+// Subscribe registers an observer for engine events and returns a function that removes it
+func (e *Engine) Subscribe(obs Observer) (unsubscribe func()) {
+	return e.observers.add(obs)
+}
+
+// Snapshot returns the current state and totals
+func (e *Engine) Snapshot() Snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.snapshotLocked()
+}
+
+func (e *Engine) snapshotLocked() Snapshot {
+	return Snapshot{
+		State:         e.state.State(),
+		Volume:        e.volume,
+		Amount:        e.amount,
+		PricePerLitre: e.pricePerLitre,
+		Grade:         e.grades[e.selected].grade,
+		Preset:        e.preset,
+		Locked:        e.locked,
+		OutOfService:  e.outOfServiceLocked(),
+		Started:       e.started,
+	}
+}
+
+// State returns the current transaction state
+func (e *Engine) State() State {
+	return e.state.State()
+}
+
+// History returns the recorded state transitions, oldest first
+func (e *Engine) History() []StateEvent {
+	return e.state.History()
+}
+
+// Grades returns every grade with its current price and totaliser
+func (e *Engine) Grades() []GradeInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	infos := make([]GradeInfo, len(e.grades))
+	for i, g := range e.grades {
+		infos[i] = g.info()
+		infos[i].OutOfService = !e.gradeAvailableLocked(g.grade.Name)
+	}
+	return infos
+}
+
+// SetTanks connects the pump to the forecourt's storage tanks
+// Sales are drawn from the tanks, and grades whose tank is low can't be sold
+func (e *Engine) SetTanks(tanks *Tanks) {
+	e.mu.Lock()
+	e.tanks = tanks
+	e.mu.Unlock()
+
+	tanks.onChange(func() {
+		e.observers.notify(Event{Type: EventTanksChanged, Snapshot: e.Snapshot()})
+	})
+}
+
+// OutOfService reports whether every grade's tank is below its low level, so nothing can be sold
+func (e *Engine) OutOfService() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.outOfServiceLocked()
+}
+
+// GradeOutOfService reports whether the selected grade's tank is below its low level
+func (e *Engine) GradeOutOfService() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.gradeAvailableLocked(e.grades[e.selected].grade.Name)
+}
+
+func (e *Engine) outOfServiceLocked() bool {
+	for _, g := range e.grades {
+		if e.gradeAvailableLocked(g.grade.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// gradeAvailableLocked reports whether grade can be sold given the tank levels
+func (e *Engine) gradeAvailableLocked(grade string) bool {
+	return e.tanks == nil || e.tanks.Available(grade)
+}
+
+// SelectGrade chooses the grade for the next sale - only allowed while idle
+func (e *Engine) SelectGrade(name string) error {
+	if state := e.state.State(); state != StateIdle {
+		return fmt.Errorf("cannot change grade in state %s", state)
+	}
+	e.mu.Lock()
+	if !e.gradeAvailableLocked(name) {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is out of service", name)
+	}
+	found := false
+	for i, g := range e.grades {
+		if g.grade.Name == name {
+			e.selected = i
+			e.pricePerLitre = g.price
+			found = true
+			break
+		}
+	}
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("unknown grade %q", name)
+	}
+	e.observers.notify(Event{Type: EventGradeChanged, Snapshot: snap})
+	e.observers.notify(Event{Type: EventPriceChanged, Snapshot: snap})
+	return nil
+}
+
+// CanPay reports whether the sale is paused with something to pay
+func (e *Engine) CanPay() bool {
+	snap := e.Snapshot()
+	return snap.State == StatePaused && snap.Amount > 0
+}
+
+// SetLocked locks or unlocks the pump
+// A locked pump finishes the current sale but refuses to authorise a new one
+func (e *Engine) SetLocked(locked bool) {
+	e.mu.Lock()
+	changed := e.locked != locked
+	e.locked = locked
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	if changed {
+		e.observers.notify(Event{Type: EventLockChanged, Snapshot: snap})
+	}
+}
+
+// Locked reports whether the pump is locked
+func (e *Engine) Locked() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.locked
+}
+
+// Authorise starts a new sale limited by preset
+func (e *Engine) Authorise(preset Preset) error {
+	if !e.state.CanTransition(StateAuthorised) {
+		return fmt.Errorf("cannot authorise in state %s", e.state.State())
+	}
+	if e.Locked() {
+		return fmt.Errorf("pump is locked")
+	}
+	e.mu.Lock()
+	if name := e.grades[e.selected].grade.Name; !e.gradeAvailableLocked(name) {
+		e.mu.Unlock()
+		return fmt.Errorf("%s is out of service", name)
+	}
+	e.preset = preset
+	grade := e.grades[e.selected].grade.Name
+	e.mu.Unlock()
+
+	if err := e.state.Transition(StateAuthorised, grade+", preset "+preset.String()); err != nil {
+		e.mu.Lock()
+		e.preset = NoPreset
+		e.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// StartPumping authorises an open-ended sale if needed and starts the flow of fuel
+func (e *Engine) StartPumping() error {
+	switch e.state.State() {
+	case StatePumping:
+		return nil
+	case StateIdle:
+		// The customer has to choose a grade (and preset) first
+		return fmt.Errorf("no grade selected - choose a grade first")
+	}
+	if e.Snapshot().PresetReached() {
+		return fmt.Errorf("preset %s already reached", e.Snapshot().Preset)
+	}
+	return e.state.Transition(StatePumping, "button pressed")
+}
+
+// Dispense adds the fuel that has flowed since the last call, if the pump is pumping
+// The volume depends only on elapsed wall-clock time and the flow curve, not on how often it is called
+func (e *Engine) Dispense(now time.Time) {
+	e.mu.Lock()
+	// Only dispense while the state machine says fuel is flowing
+	if !e.state.Is(StatePumping) {
+		e.mu.Unlock()
+		return
+	}
+
+	grade := e.grades[e.selected].grade.Name
+	limit, limited := e.preset.limitVolume(e.pricePerLitre)
+	tankLimit := false // The tank holds less than the preset still wants
+	if e.tanks != nil {
+		// Ease off as the tank runs low, as well as before the preset
+		if tank, ok := e.tanks.Tank(grade); ok && (!limited || e.volume+tank.Level < limit) {
+			limit, limited = e.volume+tank.Level, true
+			tankLimit = true
+		}
+	}
+	added := e.meter.measure(e.flow, now, e.volume, limit, limited)
+	if limited && e.volume+added > limit {
+		added = limit - e.volume
+	}
+
+	// Fuel leaves the tank as it is dispensed, so pumps sharing the tank can't sell the same litres
+	tankDry := false
+	if e.tanks != nil && added > 0 {
+		taken := e.tanks.Take(grade, added)
+		tankDry = taken < added
+		added = taken
+	}
+	tankDry = tankDry || (tankLimit && e.volume+added >= limit)
+	if added <= 0 && !tankDry {
+		e.mu.Unlock()
+		return
+	}
+
+	e.volume += added
+	// Always charge for the total volume so rounding never accumulates
+	e.amount = e.pricePerLitre.Cost(e.volume)
+	if e.preset.Kind == PresetAmount && e.amount > e.preset.Amount {
+		e.amount = e.preset.Amount
+	}
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	e.observers.notify(Event{Type: EventTotalsChanged, Snapshot: snap})
+
+	// Cut off exactly at the preset, or when the tank runs dry
+	if tankDry {
+		if err := e.state.Transition(StatePaused, "tank empty"); err != nil {
+			fmt.Printf("⚠ Tank cut-off failed: %v\n", err)
+		}
+	} else if snap.PresetReached() {
+		if err := e.state.Transition(StatePaused, "preset reached"); err != nil {
+			fmt.Printf("⚠ Preset cut-off failed: %v\n", err)
+		}
+	}
+}
+
+// Resume puts an idle pump back into a sale interrupted by a restart, paused and ready to be paid for
+// The sale is restored exactly as it was - its grade, price, totals and start time - so the
+// customer pays for the fuel already dispensed even if prices or tank levels have changed since
+func (e *Engine) Resume(sale Sale) error {
+	if !e.state.Is(StateIdle) {
+		return fmt.Errorf("cannot resume a sale in state %s", e.state.State())
+	}
+	if sale.Volume <= 0 {
+		return fmt.Errorf("nothing was dispensed")
+	}
+	e.mu.Lock()
+	found := false
+	for i, g := range e.grades {
+		if g.grade.Name == sale.Grade {
+			e.selected = i
+			found = true
+			break
+		}
+	}
+	e.mu.Unlock()
+	if !found {
+		return fmt.Errorf("unknown grade %q", sale.Grade)
+	}
+
+	if err := e.state.Transition(StateAuthorised, "resumed after restart"); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.started = sale.Start
+	e.pricePerLitre = sale.PricePerLitre
+	e.volume = sale.Volume
+	e.amount = sale.Amount
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	e.observers.notify(Event{Type: EventGradeChanged, Snapshot: snap})
+	e.observers.notify(Event{Type: EventPriceChanged, Snapshot: snap})
+	e.observers.notify(Event{Type: EventTotalsChanged, Snapshot: snap})
+
+	// The tanks were refilled to their starting levels by the restart, so take the fuel out again
+	if e.tanks != nil {
+		e.tanks.Draw(sale.Grade, sale.Volume)
+	}
+
+	// Walk through pumping so the history shows how the sale got here
+	if err := e.state.Transition(StatePumping, "resumed after restart"); err != nil {
+		return err
+	}
+	return e.state.Transition(StatePaused, "resumed after restart")
+}
+
+// StopPumping pauses the sale when the button is released
+func (e *Engine) StopPumping() error {
+	if !e.state.Is(StatePumping) {
+		return nil
+	}
+	return e.state.Transition(StatePaused, "button released")
+}
+
+// RequestPayment moves a paused sale with an amount due to the payment stage
+func (e *Engine) RequestPayment() error {
+	if snap := e.Snapshot(); snap.Amount <= 0 {
+		return fmt.Errorf("nothing to pay")
+	}
+	return e.state.Transition(StateAwaitingPayment, "pay pressed")
+}
+
+// CompletePayment marks the sale as paid
+func (e *Engine) CompletePayment(cardUID string) error {
+	if !e.state.CanTransition(StatePaid) {
+		return fmt.Errorf("cannot complete payment in state %s", e.state.State())
+	}
+	e.mu.Lock()
+	e.cardUID = cardUID
+	e.mu.Unlock()
+	return e.state.Transition(StatePaid, "card "+cardUID)
+}
+
+// CancelPayment abandons the sale from the payment stage
+func (e *Engine) CancelPayment() error {
+	return e.state.Transition(StateCancelled, "payment cancelled")
+}
+
+// Reset abandons the current sale (if any) and returns the pump to idle
+func (e *Engine) Reset() error {
+	switch e.state.State() {
+	case StateIdle:
+		return nil
+	case StatePaid, StateCancelled:
+		// Already finishing - just complete the move to idle
+	default:
+		if err := e.state.Transition(StateCancelled, "reset"); err != nil {
+			return err
+		}
+	}
+	return e.state.Transition(StateIdle, "reset")
+}
+
+// finishSale adds the sale to the selected grade's totaliser and publishes it
+// Its fuel has already been taken from the tank as it was dispensed
+func (e *Engine) finishSale(outcome Outcome, at time.Time) {
+	e.mu.Lock()
+	grade := e.grades[e.selected]
+	sale := Sale{
+		Start:         e.started,
+		End:           at,
+		Grade:         grade.grade.Name,
+		Volume:        e.volume,
+		PricePerLitre: e.pricePerLitre,
+		Amount:        e.amount,
+		Outcome:       outcome,
+	}
+	if outcome == OutcomePaid {
+		sale.CardUID = e.cardUID
+	}
+	if e.volume > 0 {
+		grade.totaliser.Volume += e.volume
+		grade.totaliser.Amount += e.amount
+		grade.totaliser.Sales++
+	}
+	tanks := e.tanks
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	if tanks != nil && sale.Volume > 0 {
+		tanks.changed()
+	}
+	e.observers.notify(Event{Type: EventSaleFinished, Snapshot: snap, Sale: sale})
+}
+
+// clearSale zeroes the totals and applies any new prices, called on entry to idle
+func (e *Engine) clearSale() {
+	e.mu.Lock()
+	e.volume = 0
+	e.amount = 0
+	e.preset = NoPreset
+	e.started = time.Time{}
+	e.cardUID = ""
+	// Between transactions is the only time prices may change
+	e.refreshPricesLocked(time.Now())
+	snap := e.snapshotLocked()
+	e.mu.Unlock()
+
+	e.observers.notify(Event{Type: EventPriceChanged, Snapshot: snap})
+	e.observers.notify(Event{Type: EventTotalsChanged, Snapshot: snap})
+}
@@ -0,0 +1,108 @@
+package pump
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies what changed in an Engine
+type EventType int
+
+const (
+	EventStateChanged  EventType = iota // The transaction state moved
+	EventTotalsChanged                  // Volume and/or amount changed
+	EventPriceChanged                   // The price per litre changed
+	EventGradeChanged                   // A different grade was selected
+	EventLockChanged                    // The pump was locked or unlocked
+	EventTanksChanged                   // A storage tank level or threshold changed
+	EventSaleFinished                   // A sale was paid or cancelled
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventStateChanged:
+		return "StateChanged"
+	case EventTotalsChanged:
+		return "TotalsChanged"
+	case EventPriceChanged:
+		return "PriceChanged"
+	case EventGradeChanged:
+		return "GradeChanged"
+	case EventLockChanged:
+		return "LockChanged"
+	case EventTanksChanged:
+		return "TanksChanged"
+	case EventSaleFinished:
+		return "SaleFinished"
+	}
+	return "Unknown"
+}
+
+// Snapshot is a consistent copy of the engine's readings
+type Snapshot struct {
+	State         State
+	Volume        Volume
+	Amount        Money
+	PricePerLitre UnitPrice
+	Grade         Grade
+	Preset        Preset
+	Locked        bool
+	OutOfService  bool      // Every grade's tank is below its low level
+	Started       time.Time // When the sale was authorised, zero while idle
+}
+
+// PresetReached reports whether the sale has hit its preset limit
+func (s Snapshot) PresetReached() bool {
+	return s.Preset.reached(s.Volume, s.Amount)
+}
+
+// Event is delivered to observers whenever the engine changes
+type Event struct {
+	Type       EventType
+	Snapshot   Snapshot
+	Transition StateEvent // Only set for EventStateChanged
+	Sale       Sale       // Only set for EventSaleFinished
+}
+
+// Observer receives engine events
+// Observers are called synchronously, so they should not block for long
+type Observer func(Event)
+
+// observers is a set of subscribed observers that can be notified safely from any goroutine
+type observers struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]Observer
+}
+
+func (o *observers) add(obs Observer) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.subs == nil {
+		o.subs = make(map[int]Observer)
+	}
+	id := o.nextID
+	o.nextID++
+	o.subs[id] = obs
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		delete(o.subs, id)
+	}
+}
+
+func (o *observers) notify(ev Event) {
+	o.mu.Lock()
+	subs := make([]Observer, 0, len(o.subs))
+	for id := 0; id < o.nextID; id++ {
+		if obs, ok := o.subs[id]; ok {
+			subs = append(subs, obs)
+		}
+	}
+	o.mu.Unlock()
+
+	// Call outside the lock so observers may subscribe, unsubscribe or drive the engine
+	for _, obs := range subs {
+		obs(ev)
+	}
+}
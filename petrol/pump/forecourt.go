@@ -0,0 +1,123 @@
+package pump
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is one numbered pump on the forecourt
+type Position struct {
+	Number int
+	*Engine
+}
+
+// PositionStatus is a snapshot of one position for listing
+type PositionStatus struct {
+	Number int
+	Snapshot
+}
+
+// Forecourt is the set of pumps driven by one process
+type Forecourt struct {
+	positions []*Position
+	tanks     *Tanks
+}
+
+// NewForecourt creates count pumps numbered from 1, all selling grades from the shared tanks
+// tanks may be nil for unlimited fuel
+func NewForecourt(count int, grades []Grade, tanks *Tanks) *Forecourt {
+	f := &Forecourt{tanks: tanks}
+	for i := 1; i <= count; i++ {
+		engine := New(grades)
+		if tanks != nil {
+			engine.SetTanks(tanks)
+		}
+		f.positions = append(f.positions, &Position{Number: i, Engine: engine})
+	}
+	return f
+}
+
+// Tanks returns the forecourt's storage tanks (nil if fuel is unlimited)
+func (f *Forecourt) Tanks() *Tanks {
+	return f.tanks
+}
+
+// Positions returns every pump in number order
+func (f *Forecourt) Positions() []*Position {
+	return append([]*Position(nil), f.positions...)
+}
+
+// Position returns the pump with the given number
+func (f *Forecourt) Position(number int) (*Position, error) {
+	for _, pos := range f.positions {
+		if pos.Number == number {
+			return pos, nil
+		}
+	}
+	return nil, fmt.Errorf("no pump %d on the forecourt", number)
+}
+
+// Status lists every pump with its current readings
+func (f *Forecourt) Status() []PositionStatus {
+	status := make([]PositionStatus, len(f.positions))
+	for i, pos := range f.positions {
+		status[i] = PositionStatus{Number: pos.Number, Snapshot: pos.Snapshot()}
+	}
+	return status
+}
+
+// LockAll locks every pump - sales in progress finish, new ones are refused
+func (f *Forecourt) LockAll() {
+	for _, pos := range f.positions {
+		pos.SetLocked(true)
+	}
+}
+
+// UnlockAll unlocks every pump
+func (f *Forecourt) UnlockAll() {
+	for _, pos := range f.positions {
+		pos.SetLocked(false)
+	}
+}
+
+// AuthoriseAll authorises every idle, unlocked pump for its selected grade
+// Returns the numbers of the pumps that were authorised
+func (f *Forecourt) AuthoriseAll(preset Preset) []int {
+	var authorised []int
+	for _, pos := range f.positions {
+		if pos.State() != StateIdle || pos.Locked() {
+			continue
+		}
+		if err := pos.Authorise(preset); err != nil {
+			fmt.Printf("⚠ Pump %d not authorised: %v\n", pos.Number, err)
+			continue
+		}
+		authorised = append(authorised, pos.Number)
+	}
+	return authorised
+}
+
+// AwaitingPayment returns the pumps currently waiting for payment, in number order
+func (f *Forecourt) AwaitingPayment() []*Position {
+	var waiting []*Position
+	for _, pos := range f.positions {
+		if pos.State() == StateAwaitingPayment {
+			waiting = append(waiting, pos)
+		}
+	}
+	return waiting
+}
+
+// String summarises the forecourt, one line per pump
+func (f *Forecourt) String() string {
+	var b strings.Builder
+	for _, st := range f.Status() {
+		lock := ""
+		if st.Locked {
+			lock = " [locked]"
+		}
+		fmt.Fprintf(&b, "Pump %d: %s %s, %s %s%s\n",
+			st.Number, st.State, st.Grade.Name, st.Volume, st.Amount, lock)
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package pump
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// Grade is a fuel product sold by the pump
+type Grade struct {
+	Name     string
+	Colour   color.RGBA
+	MinPrice UnitPrice // Lowest price per litre picked between sales
+	MaxPrice UnitPrice // Highest price per litre picked between sales (exclusive)
+}
+
+// Totaliser accumulates everything a grade has dispensed, like the mechanical counters on a real pump
+type Totaliser struct {
+	Volume Volume
+	Amount Money
+	Sales  int
+}
+
+// GradeInfo is a grade together with its current price and totaliser
+type GradeInfo struct {
+	Grade
+	Price        UnitPrice
+	Totaliser    Totaliser
+	OutOfService bool // The grade's tank is below its low level
+}
+
+// DefaultGrades returns the grades sold by a standard UK forecourt
+func DefaultGrades() []Grade {
+	return []Grade{
+		{Name: "Unleaded", Colour: color.RGBA{R: 40, G: 160, B: 60, A: 255}, MinPrice: MinPricePerLitre, MaxPrice: MaxPricePerLitre},
+		{Name: "Super", Colour: color.RGBA{R: 200, G: 40, B: 40, A: 255}, MinPrice: 1550, MaxPrice: 1750},
+		{Name: "Diesel", Colour: color.RGBA{R: 30, G: 30, B: 30, A: 255}, MinPrice: 1450, MaxPrice: 1650},
+		{Name: "LPG", Colour: color.RGBA{R: 30, G: 90, B: 200, A: 255}, MinPrice: 700, MaxPrice: 900},
+	}
+}
+
+// randomPrice returns a random whole-penny price in the grade's range
+func (g Grade) randomPrice() UnitPrice {
+	pennies := int((g.MaxPrice - g.MinPrice) / 10)
+	if pennies <= 0 {
+		return g.MinPrice
+	}
+	return g.MinPrice + UnitPrice(rand.Intn(pennies))*10
+}
+
+// gradeState is a grade as tracked by the engine
+type gradeState struct {
+	grade     Grade
+	price     UnitPrice
+	totaliser Totaliser
+}
+
+func (g *gradeState) info() GradeInfo {
+	return GradeInfo{Grade: g.grade, Price: g.price, Totaliser: g.totaliser}
+}
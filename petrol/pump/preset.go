@@ -0,0 +1,77 @@
+package pump
+
+// PresetKind says what a preset limits
+type PresetKind int
+
+const (
+	PresetNone   PresetKind = iota // Open-ended sale, pump until the button is released
+	PresetAmount                   // Stop when the amount reaches Preset.Amount
+	PresetVolume                   // Stop when the volume reaches Preset.Volume
+)
+
+// Preset is a limit chosen by the customer before pumping
+type Preset struct {
+	Kind   PresetKind
+	Amount Money
+	Volume Volume
+}
+
+// NoPreset is an open-ended sale
+var NoPreset = Preset{Kind: PresetNone}
+
+// PresetByAmount limits a sale to amount
+func PresetByAmount(amount Money) Preset {
+	return Preset{Kind: PresetAmount, Amount: amount}
+}
+
+// PresetByVolume limits a sale to volume
+func PresetByVolume(volume Volume) Preset {
+	return Preset{Kind: PresetVolume, Volume: volume}
+}
+
+// IsSet reports whether the preset limits the sale
+func (p Preset) IsSet() bool {
+	return p.Kind != PresetNone
+}
+
+func (p Preset) String() string {
+	switch p.Kind {
+	case PresetAmount:
+		return p.Amount.String()
+	case PresetVolume:
+		return p.Volume.String()
+	}
+	return "No preset"
+}
+
+// limitVolume returns the total volume at which the preset is reached
+// ok is false for an open-ended sale
+func (p Preset) limitVolume(price UnitPrice) (limit Volume, ok bool) {
+	switch p.Kind {
+	case PresetVolume:
+		return p.Volume, true
+	case PresetAmount:
+		if price <= 0 {
+			return 0, true
+		}
+		// The smallest volume whose cost rounds up to the preset amount:
+		// cost(v) >= A  ⇔  v × price + 5000 >= A × 10000 (see UnitPrice.Cost)
+		needed := int64(p.Amount)*10000 - 5000
+		if needed <= 0 {
+			return 0, true
+		}
+		return Volume((needed + int64(price) - 1) / int64(price)), true
+	}
+	return 0, false
+}
+
+// reached reports whether the totals have hit the preset
+func (p Preset) reached(volume Volume, amount Money) bool {
+	switch p.Kind {
+	case PresetVolume:
+		return volume >= p.Volume
+	case PresetAmount:
+		return amount >= p.Amount
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package pump
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceSource decides what each grade costs
+// Engines only ask for prices between transactions, so a source may change its answer at any time
+type PriceSource interface {
+	// Price returns the price per litre of grade at time at
+	Price(grade Grade, at time.Time) (UnitPrice, error)
+}
+
+// PriceChange records a price that came into effect
+type PriceChange struct {
+	Grade     string
+	Old       UnitPrice
+	New       UnitPrice
+	Effective time.Time
+}
+
+func (c PriceChange) String() string {
+	return fmt.Sprintf("%s %s: %s → %s", c.Effective.Format("2006-01-02 15:04:05"), c.Grade, c.Old, c.New)
+}
+
+// FixedPrice charges the same price for every grade
+type FixedPrice UnitPrice
+
+// Price implements PriceSource
+func (f FixedPrice) Price(Grade, time.Time) (UnitPrice, error) {
+	return UnitPrice(f), nil
+}
+
+// FixedPrices charges a fixed price per grade, keyed by grade name
+type FixedPrices map[string]UnitPrice
+
+// Price implements PriceSource
+func (f FixedPrices) Price(grade Grade, _ time.Time) (UnitPrice, error) {
+	price, ok := f[grade.Name]
+	if !ok {
+		return 0, fmt.Errorf("no price for grade %q", grade.Name)
+	}
+	return price, nil
+}
+
+// RandomPrices picks a random whole-penny price in each grade's MinPrice-MaxPrice range
+type RandomPrices struct{}
+
+// Price implements PriceSource
+func (RandomPrices) Price(grade Grade, _ time.Time) (UnitPrice, error) {
+	return grade.randomPrice(), nil
+}
+
+// PriceBand is a set of prices that applies from a time of day onwards
+type PriceBand struct {
+	Start  time.Duration // Time since midnight the band starts
+	Prices FixedPrices
+}
+
+// Schedule changes prices by time of day
+// Each grade uses the latest band that has started and lists it; before a grade's
+// first band of the day, its last band from the previous evening still applies
+type Schedule struct {
+	Bands []PriceBand
+}
+
+// Price implements PriceSource
+func (s Schedule) Price(grade Grade, at time.Time) (UnitPrice, error) {
+	bands := append([]PriceBand(nil), s.Bands...)
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].Start < bands[j].Start })
+
+	midnight := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	sinceMidnight := at.Sub(midnight)
+
+	var current, last UnitPrice
+	haveCurrent, haveLast := false, false
+	for _, band := range bands {
+		p, ok := band.Prices[grade.Name]
+		if !ok {
+			continue
+		}
+		last, haveLast = p, true
+		if band.Start <= sinceMidnight {
+			current, haveCurrent = p, true
+		}
+	}
+
+	switch {
+	case haveCurrent:
+		return current, nil
+	case haveLast:
+		// Yesterday's last band applies until today's first band starts
+		return last, nil
+	}
+	return 0, fmt.Errorf("no scheduled price for grade %q", grade.Name)
+}
+
+// PriceFile reads prices from a local text file, re-reading it whenever it changes
+//
+// One price per line, optionally from a time of day (24-hour clock):
+//
+//	# grade     price   [from]
+//	Unleaded    1.459
+//	Diesel      1.529
+//	Unleaded    1.399   18:00
+//
+// Blank lines and lines starting with # are ignored
+type PriceFile struct {
+	Path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	schedule Schedule
+}
+
+// NewPriceFile creates a price source reading path, checking it can be parsed now
+func NewPriceFile(path string) (*PriceFile, error) {
+	f := &PriceFile{Path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Price implements PriceSource
+func (f *PriceFile) Price(grade Grade, at time.Time) (UnitPrice, error) {
+	if err := f.reload(); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.schedule.Price(grade, at)
+}
+
+// reload re-parses the file if it has been modified since it was last read
+func (f *PriceFile) reload() error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return fmt.Errorf("price file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	schedule, err := parsePriceFile(f.Path)
+	if err != nil {
+		return err
+	}
+	f.schedule = schedule
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// parsePriceFile reads a price file into a schedule
+func parsePriceFile(path string) (Schedule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("price file: %w", err)
+	}
+	defer file.Close()
+
+	bands := map[time.Duration]FixedPrices{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return Schedule{}, fmt.Errorf("price file %s:%d: expected \"grade price [HH:MM]\"", path, lineNo)
+		}
+		price, err := ParseUnitPrice(fields[1])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("price file %s:%d: %w", path, lineNo, err)
+		}
+		var start time.Duration
+		if len(fields) == 3 {
+			t, err := time.Parse("15:04", fields[2])
+			if err != nil {
+				return Schedule{}, fmt.Errorf("price file %s:%d: bad time %q", path, lineNo, fields[2])
+			}
+			start = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		}
+
+		if bands[start] == nil {
+			bands[start] = FixedPrices{}
+		}
+		bands[start][fields[0]] = price
+	}
+	if err := scanner.Err(); err != nil {
+		return Schedule{}, fmt.Errorf("price file: %w", err)
+	}
+
+	var schedule Schedule
+	for start, prices := range bands {
+		schedule.Bands = append(schedule.Bands, PriceBand{Start: start, Prices: prices})
+	}
+	return schedule, nil
+}
@@ -0,0 +1,23 @@
+package pump
+
+import "time"
+
+// Outcome is how a sale ended
+type Outcome string
+
+const (
+	OutcomePaid      Outcome = "paid"
+	OutcomeCancelled Outcome = "cancelled"
+)
+
+// Sale is a finished transaction, published with EventSaleFinished
+type Sale struct {
+	Start         time.Time // When the sale was authorised
+	End           time.Time // When it was paid or cancelled
+	Grade         string
+	Volume        Volume
+	PricePerLitre UnitPrice
+	Amount        Money
+	CardUID       string // Empty unless paid by card
+	Outcome       Outcome
+}
@@ -0,0 +1,164 @@
+package pump
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State is a stage in the lifecycle of a single fuel sale
+type State int
+
+const (
+	StateIdle            State = iota // Waiting for a customer
+	StateAuthorised                   // Sale authorised, nozzle not yet flowing
+	StatePumping                      // Fuel is flowing
+	StatePaused                       // Button released mid-sale
+	StateAwaitingPayment              // Payment screen is up
+	StatePaid                         // Payment taken, about to return to idle
+	StateCancelled                    // Sale abandoned, about to return to idle
+)
+
+// maxStateHistory limits how many transitions are kept in memory
+const maxStateHistory = 100
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "Idle"
+	case StateAuthorised:
+		return "Authorised"
+	case StatePumping:
+		return "Pumping"
+	case StatePaused:
+		return "Paused"
+	case StateAwaitingPayment:
+		return "AwaitingPayment"
+	case StatePaid:
+		return "Paid"
+	case StateCancelled:
+		return "Cancelled"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// validTransitions lists the states each state is allowed to move to
+var validTransitions = map[State][]State{
+	StateIdle:            {StateAuthorised},
+	StateAuthorised:      {StatePumping, StateCancelled},
+	StatePumping:         {StatePaused, StateCancelled},
+	StatePaused:          {StatePumping, StateAwaitingPayment, StateCancelled},
+	StateAwaitingPayment: {StatePaid, StateCancelled},
+	StatePaid:            {StateIdle},
+	StateCancelled:       {StateIdle},
+}
+
+// StateEvent records a single transition in the state history
+type StateEvent struct {
+	From   State
+	To     State
+	Reason string
+	At     time.Time
+}
+
+// StateHook is called when a state is entered or left
+type StateHook func(ev StateEvent)
+
+// StateMachine tracks the transaction state of a pump and rejects illegal transitions
+type StateMachine struct {
+	mu      sync.Mutex
+	state   State
+	history []StateEvent
+	onEnter map[State][]StateHook
+	onExit  map[State][]StateHook
+}
+
+// NewStateMachine creates a state machine starting in StateIdle
+func NewStateMachine() *StateMachine {
+	return &StateMachine{
+		state:   StateIdle,
+		onEnter: make(map[State][]StateHook),
+		onExit:  make(map[State][]StateHook),
+	}
+}
+
+// State returns the current state
+func (m *StateMachine) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Is reports whether the machine is currently in state s
+func (m *StateMachine) Is(s State) bool {
+	return m.State() == s
+}
+
+// CanTransition reports whether moving from the current state to s is allowed
+func (m *StateMachine) CanTransition(to State) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return canTransition(m.state, to)
+}
+
+func canTransition(from, to State) bool {
+	for _, s := range validTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OnEnter registers a hook that runs after the machine enters state s
+func (m *StateMachine) OnEnter(s State, hook StateHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEnter[s] = append(m.onEnter[s], hook)
+}
+
+// OnExit registers a hook that runs when the machine leaves state s
+func (m *StateMachine) OnExit(s State, hook StateHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onExit[s] = append(m.onExit[s], hook)
+}
+
+// Transition moves the machine to state to, running exit and entry hooks
+// Returns an error and leaves the state unchanged if the move is not allowed
+func (m *StateMachine) Transition(to State, reason string) error {
+	m.mu.Lock()
+	from := m.state
+	if !canTransition(from, to) {
+		m.mu.Unlock()
+		return fmt.Errorf("invalid transition %s → %s (%s)", from, to, reason)
+	}
+
+	ev := StateEvent{From: from, To: to, Reason: reason, At: time.Now()}
+	m.state = to
+	m.history = append(m.history, ev)
+	if len(m.history) > maxStateHistory {
+		m.history = m.history[len(m.history)-maxStateHistory:]
+	}
+
+	// Copy hooks so they can run without holding the lock
+	// (hooks are allowed to trigger further transitions)
+	exitHooks := append([]StateHook(nil), m.onExit[from]...)
+	enterHooks := append([]StateHook(nil), m.onEnter[to]...)
+	m.mu.Unlock()
+
+	for _, hook := range exitHooks {
+		hook(ev)
+	}
+	for _, hook := range enterHooks {
+		hook(ev)
+	}
+	return nil
+}
+
+// History returns a copy of the recorded transitions, oldest first
+func (m *StateMachine) History() []StateEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]StateEvent(nil), m.history...)
+}
@@ -0,0 +1,65 @@
+package pump
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateMachineTransitions(t *testing.T) {
+	tests := []struct {
+		from, to State
+		ok       bool
+	}{
+		{StateIdle, StateAuthorised, true},
+		{StateIdle, StatePumping, false},
+		{StateAuthorised, StatePumping, true},
+		{StateAuthorised, StateCancelled, true},
+		{StatePumping, StatePaused, true},
+		{StatePumping, StateCancelled, true},
+		{StatePumping, StateAwaitingPayment, false},
+		{StatePumping, StatePaid, false},
+		{StatePaused, StateAwaitingPayment, true},
+		{StateAwaitingPayment, StatePaid, true},
+		{StateAwaitingPayment, StatePumping, false},
+		{StatePaid, StateIdle, true},
+		{StateCancelled, StateIdle, true},
+		{StateCancelled, StatePumping, false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.ok {
+			t.Errorf("%s → %s allowed = %v, want %v", tt.from, tt.to, got, tt.ok)
+		}
+	}
+}
+
+func TestResetWhilePumping(t *testing.T) {
+	e := New(nil)
+	e.SetPriceSource(FixedPrice(1459))
+	if err := e.Authorise(NoPreset); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.StartPumping(); err != nil {
+		t.Fatal(err)
+	}
+	e.Dispense(time.Now().Add(2 * time.Second))
+
+	var sale Sale
+	e.Subscribe(func(ev Event) {
+		if ev.Type == EventSaleFinished {
+			sale = ev.Sale
+		}
+	})
+	if err := e.Reset(); err != nil {
+		t.Fatalf("Reset while pumping: %v", err)
+	}
+	if state := e.State(); state != StateIdle {
+		t.Errorf("state after reset %s, want idle", state)
+	}
+	if sale.Outcome != OutcomeCancelled || sale.Volume <= 0 {
+		t.Errorf("reset finished the sale as %s with %s, want cancelled with the fuel dispensed", sale.Outcome, sale.Volume)
+	}
+	history := e.History()
+	if n := len(history); n < 2 || history[n-2].From != StatePumping || history[n-2].To != StateCancelled {
+		t.Errorf("history doesn't go Pumping → Cancelled: %v", history)
+	}
+}
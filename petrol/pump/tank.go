@@ -0,0 +1,185 @@
+package pump
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Default tank settings for DefaultTanks
+const (
+	DefaultTankCapacity = 20000 * Litre
+	DefaultTankLevel    = 15000 * Litre
+	DefaultTankLowLevel = 500 * Litre
+)
+
+// Tank is the underground storage tank for one grade
+type Tank struct {
+	Grade    string
+	Capacity Volume
+	Level    Volume
+	LowLevel Volume // Below this level the grade is out of service
+}
+
+// Low reports whether the tank is below its low-level threshold
+func (t Tank) Low() bool {
+	return t.Level < t.LowLevel
+}
+
+func (t Tank) String() string {
+	status := ""
+	if t.Low() {
+		status = " LOW"
+	}
+	return fmt.Sprintf("%s: %s of %s%s", t.Grade, t.Level, t.Capacity, status)
+}
+
+// Tanks is the set of storage tanks shared by every pump on a forecourt
+// Grades without a tank are treated as unlimited
+type Tanks struct {
+	mu        sync.Mutex
+	tanks     map[string]*Tank
+	order     []string
+	listeners []func()
+}
+
+// NewTanks creates a tank set from tanks
+func NewTanks(tanks []Tank) *Tanks {
+	t := &Tanks{tanks: make(map[string]*Tank)}
+	for _, tank := range tanks {
+		tank := tank
+		t.tanks[tank.Grade] = &tank
+		t.order = append(t.order, tank.Grade)
+	}
+	return t
+}
+
+// DefaultTanks creates one tank per grade with the default capacity and level
+func DefaultTanks(grades []Grade, lowLevel Volume) *Tanks {
+	var tanks []Tank
+	for _, g := range grades {
+		tanks = append(tanks, Tank{
+			Grade:    g.Name,
+			Capacity: DefaultTankCapacity,
+			Level:    DefaultTankLevel,
+			LowLevel: lowLevel,
+		})
+	}
+	return NewTanks(tanks)
+}
+
+// All returns a copy of every tank, in the order they were created
+func (t *Tanks) All() []Tank {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tanks := make([]Tank, 0, len(t.order))
+	for _, grade := range t.order {
+		tanks = append(tanks, *t.tanks[grade])
+	}
+	return tanks
+}
+
+// Tank returns a copy of the tank for grade
+func (t *Tanks) Tank(grade string) (Tank, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tank, ok := t.tanks[grade]
+	if !ok {
+		return Tank{}, false
+	}
+	return *tank, true
+}
+
+// Available reports whether grade can be sold - it has no tank, or its tank is above the low level
+func (t *Tanks) Available(grade string) bool {
+	tank, ok := t.Tank(grade)
+	return !ok || !tank.Low()
+}
+
+// Take takes up to volume out of grade's tank as it is dispensed, returning how much there was
+// A grade without a tank gives all of it. Listeners aren't told; the engine does that when the sale ends
+func (t *Tanks) Take(grade string, volume Volume) Volume {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tank, ok := t.tanks[grade]
+	if !ok {
+		return volume
+	}
+	if volume > tank.Level {
+		volume = tank.Level
+	}
+	tank.Level -= volume
+	return volume
+}
+
+// Draw takes volume out of grade's tank all at once, such as a sale resumed after a restart
+// The level never goes below zero
+func (t *Tanks) Draw(grade string, volume Volume) {
+	t.mu.Lock()
+	tank, ok := t.tanks[grade]
+	if ok {
+		tank.Level -= volume
+		if tank.Level < 0 {
+			tank.Level = 0
+		}
+	}
+	t.mu.Unlock()
+
+	if ok {
+		t.changed()
+	}
+}
+
+// Deliver adds a tanker delivery to grade's tank, up to its capacity
+// Returns the volume actually delivered
+func (t *Tanks) Deliver(grade string, volume Volume) (Volume, error) {
+	if volume <= 0 {
+		return 0, fmt.Errorf("delivery must be positive")
+	}
+	t.mu.Lock()
+	tank, ok := t.tanks[grade]
+	if !ok {
+		t.mu.Unlock()
+		return 0, fmt.Errorf("no tank for grade %q", grade)
+	}
+	if space := tank.Capacity - tank.Level; volume > space {
+		volume = space
+	}
+	tank.Level += volume
+	t.mu.Unlock()
+
+	fmt.Printf("🚚 Tanker delivery: %s into %s tank\n", volume, grade)
+	t.changed()
+	return volume, nil
+}
+
+// SetLowLevel changes the low-level threshold of grade's tank
+func (t *Tanks) SetLowLevel(grade string, lowLevel Volume) error {
+	t.mu.Lock()
+	tank, ok := t.tanks[grade]
+	if ok {
+		tank.LowLevel = lowLevel
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no tank for grade %q", grade)
+	}
+	t.changed()
+	return nil
+}
+
+// onChange registers fn to be called after any level or threshold changes
+func (t *Tanks) onChange(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+func (t *Tanks) changed() {
+	t.mu.Lock()
+	listeners := append([]func(){}, t.listeners...)
+	t.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
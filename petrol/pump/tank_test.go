@@ -0,0 +1,90 @@
+package pump
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTanksTake(t *testing.T) {
+	tests := []struct {
+		name  string
+		level Volume
+		want  Volume
+		taken Volume
+	}{
+		{"plenty", 10 * Litre, 2 * Litre, 2 * Litre},
+		{"exactly", 2 * Litre, 2 * Litre, 2 * Litre},
+		{"short", 1500, 2 * Litre, 1500},
+		{"empty", 0, 2 * Litre, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tanks := NewTanks([]Tank{{Grade: "Unleaded", Capacity: 20 * Litre, Level: tt.level}})
+			if got := tanks.Take("Unleaded", tt.want); got != tt.taken {
+				t.Errorf("Take = %s, want %s", got, tt.taken)
+			}
+			if tank, _ := tanks.Tank("Unleaded"); tank.Level != tt.level-tt.taken {
+				t.Errorf("level %s, want %s", tank.Level, tt.level-tt.taken)
+			}
+		})
+	}
+
+	tanks := NewTanks(nil)
+	if got := tanks.Take("Unleaded", Litre); got != Litre {
+		t.Errorf("grade without a tank gave %s, want all of it", got)
+	}
+}
+
+// Two pumps on one grade must not both sell the litres left in the tank
+func TestSharedTankIsNotOverdrawn(t *testing.T) {
+	const level = 10 * Litre
+	grades := DefaultGrades()
+	tanks := NewTanks([]Tank{{Grade: grades[0].Name, Capacity: 20 * Litre, Level: level}})
+	forecourt := NewForecourt(2, grades, tanks)
+
+	var engines []*Engine
+	for _, pos := range forecourt.Positions() {
+		pos.SetPriceSource(FixedPrice(1459))
+		if err := pos.Authorise(NoPreset); err != nil {
+			t.Fatal(err)
+		}
+		if err := pos.StartPumping(); err != nil {
+			t.Fatal(err)
+		}
+		engines = append(engines, pos.Engine)
+	}
+
+	// Each pump alone would dispense 30 L in this time
+	now := time.Now()
+	for i := 0; i < 600; i++ {
+		now = now.Add(100 * time.Millisecond)
+		for _, e := range engines {
+			e.Dispense(now)
+		}
+	}
+
+	var total Volume
+	for i, e := range engines {
+		snap := e.Snapshot()
+		if snap.State != StatePaused {
+			t.Errorf("pump %d is %s, want paused when the tank ran dry", i+1, snap.State)
+		}
+		total += snap.Volume
+	}
+	if total != level {
+		t.Errorf("pumps dispensed %s between them from a %s tank", total, level)
+	}
+	if tank, _ := tanks.Tank(grades[0].Name); tank.Level != 0 {
+		t.Errorf("tank left at %s, want empty", tank.Level)
+	}
+
+	// Finishing the sales doesn't take the fuel out a second time
+	for _, e := range engines {
+		if err := e.Reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tank, _ := tanks.Tank(grades[0].Name); tank.Level != 0 {
+		t.Errorf("tank at %s after the sales finished, want empty", tank.Level)
+	}
+}
@@ -0,0 +1,118 @@
+package pump
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fixed-point quantities used for everything that is measured or charged.
+//
+// Rounding rules:
+//   - Volume is counted in whole millilitres and only ever grows by whole millilitres
+//   - The amount charged is always recalculated from the total volume (never
+//     accumulated per increment) and rounded half-up to the nearest penny
+//   - Volumes are shown to the centilitre, truncated, so the display never
+//     shows more fuel than was actually dispensed
+//   - Money is shown exactly as charged
+
+// Volume is a quantity of fuel in millilitres
+type Volume int64
+
+// Money is an amount of money in pence
+type Money int64
+
+// UnitPrice is a price per litre in tenths of a penny (1459 = 145.9p/L)
+type UnitPrice int64
+
+const (
+	Millilitre Volume = 1
+	Litre      Volume = 1000
+
+	Penny Money = 1
+	Pound Money = 100
+)
+
+// Centilitres returns the volume truncated to whole centilitres
+func (v Volume) Centilitres() int64 {
+	return int64(v) / 10
+}
+
+// Litres formats the volume as litres to two decimal places, e.g. "12.34"
+func (v Volume) Litres() string {
+	cl := v.Centilitres()
+	return fmt.Sprintf("%d.%02d", cl/100, cl%100)
+}
+
+// String formats the volume with its unit, e.g. "12.34 L"
+func (v Volume) String() string {
+	return v.Litres() + " L"
+}
+
+// Pounds formats the amount in pounds to two decimal places, e.g. "18.75"
+func (m Money) Pounds() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, int64(m/Pound), int64(m%Pound))
+}
+
+// String formats the amount with a currency symbol, e.g. "£18.75"
+func (m Money) String() string {
+	if m < 0 {
+		return "-£" + (-m).Pounds()
+	}
+	return "£" + m.Pounds()
+}
+
+// Pounds formats the price in pounds, showing the tenth of a penny only when it is set
+// e.g. "1.45" or "1.459"
+func (p UnitPrice) Pounds() string {
+	tenths := int64(p)
+	if tenths%10 == 0 {
+		return fmt.Sprintf("%d.%02d", tenths/1000, (tenths/10)%100)
+	}
+	return fmt.Sprintf("%d.%03d", tenths/1000, tenths%1000)
+}
+
+// String formats the price per litre, e.g. "£1.45/L"
+func (p UnitPrice) String() string {
+	return "£" + p.Pounds() + "/L"
+}
+
+// ParseUnitPrice parses a price per litre in pounds with up to three decimal places,
+// e.g. "1.459" or "£1.45"
+func ParseUnitPrice(text string) (UnitPrice, error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "£")
+	whole, frac, _ := strings.Cut(text, ".")
+	if len(frac) > 3 {
+		return 0, fmt.Errorf("price %q has more than three decimal places", text)
+	}
+	frac += strings.Repeat("0", 3-len(frac))
+
+	pounds, err := strconv.ParseUint(whole, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q", text)
+	}
+	tenths, err := strconv.ParseUint(frac, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q", text)
+	}
+	return UnitPrice(pounds*1000 + tenths), nil
+}
+
+// Cost returns the price of volume v at this unit price, rounded half-up to the penny
+func (p UnitPrice) Cost(v Volume) Money {
+	// millilitres × tenth-pence per litre = 1/10000 of a penny
+	return Money(divRoundHalfUp(int64(v)*int64(p), 10000))
+}
+
+// divRoundHalfUp divides a by b (b > 0), rounding halves away from zero
+func divRoundHalfUp(a, b int64) int64 {
+	if a < 0 {
+		return -divRoundHalfUp(-a, b)
+	}
+	return (a + b/2) / b
+}
@@ -0,0 +1,133 @@
+package pump
+
+import "testing"
+
+func TestVolumeFormatting(t *testing.T) {
+	tests := []struct {
+		v    Volume
+		want string
+	}{
+		{0, "0.00 L"},
+		{9, "0.00 L"}, // Truncated, never rounded up
+		{10, "0.01 L"},
+		{12345, "12.34 L"},
+		{40 * Litre, "40.00 L"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.String(); got != tt.want {
+			t.Errorf("Volume(%d) = %q, want %q", int64(tt.v), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormatting(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{0, "£0.00"},
+		{5, "£0.05"},
+		{1875, "£18.75"},
+		{-5, "-£0.05"},
+		{-1875, "-£18.75"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("Money(%d) = %q, want %q", int64(tt.m), got, tt.want)
+		}
+	}
+}
+
+func TestUnitPriceFormatting(t *testing.T) {
+	tests := []struct {
+		p    UnitPrice
+		want string
+	}{
+		{1450, "£1.45/L"},
+		{1459, "£1.459/L"},
+		{700, "£0.70/L"},
+		{1005, "£1.005/L"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.want {
+			t.Errorf("UnitPrice(%d) = %q, want %q", int64(tt.p), got, tt.want)
+		}
+	}
+}
+
+func TestParseUnitPrice(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    UnitPrice
+		wantErr bool
+	}{
+		{"1.459", 1459, false},
+		{"£1.45", 1450, false},
+		{" 1.4 ", 1400, false},
+		{"2", 2000, false},
+		{"0.999", 999, false},
+		{"1.4599", 0, true},
+		{"abc", 0, true},
+		{"-1.40", 0, true},
+		{"1.x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnitPrice(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUnitPrice(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseUnitPrice(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCostRoundsHalfUp(t *testing.T) {
+	tests := []struct {
+		price UnitPrice
+		v     Volume
+		want  Money
+	}{
+		{1459, 0, 0},
+		{1459, 1, 0},
+		{1459, Litre, 146},             // 145.9p
+		{1459, 3427, 500},              // 499.9993p
+		{1000, 5, 1},                   // Exactly half a penny
+		{1000, 4, 0},                   // 0.4p
+		{1459, 40 * Litre, 5836},       // 5836p exactly
+		{1599, 70*Litre + 1, 11193},    // 11193.0159p
+		{999, 100000 * Litre, 9990000}, // Large volumes don't overflow
+	}
+	for _, tt := range tests {
+		if got := tt.price.Cost(tt.v); got != tt.want {
+			t.Errorf("%s × %d ml = %d, want %d", tt.price, int64(tt.v), got, tt.want)
+		}
+	}
+}
+
+// The volume limit for an amount preset is the first millilitre that costs the preset
+func TestAmountPresetLimit(t *testing.T) {
+	for _, price := range []UnitPrice{700, 1400, 1459, 1599, 1750} {
+		for _, amount := range []Money{1, 99, 500, 1000, 2500, 5000} {
+			limit, ok := PresetByAmount(amount).limitVolume(price)
+			if !ok {
+				t.Fatalf("amount preset has no limit")
+			}
+			if got := price.Cost(limit); got < amount {
+				t.Errorf("%s, preset %s: limit %d ml costs %s, short of the preset", price, amount, int64(limit), got)
+			}
+			if got := price.Cost(limit - 1); limit > 0 && got >= amount {
+				t.Errorf("%s, preset %s: %d ml already costs %s, so limit %d ml is late", price, amount, int64(limit-1), got, int64(limit))
+			}
+		}
+	}
+
+	if _, ok := NoPreset.limitVolume(1459); ok {
+		t.Error("open-ended sale has a volume limit")
+	}
+	if limit, _ := PresetByVolume(20 * Litre).limitVolume(1459); limit != 20*Litre {
+		t.Errorf("volume preset limit %s, want 20 L", limit)
+	}
+}
@@ -0,0 +1,91 @@
+package pushbutton
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/warthog618/go-gpiocdev"
+)
+
+// Line is a button read through the GPIO character device
+// The kernel reports every edge, so Pressed needs no system call
+type Line struct {
+	line    *gpiocdev.Line
+	pressed atomic.Bool
+}
+
+// OpenLine requests line offset of chip (e.g. "gpiochip0") as a button input, watching both edges
+// On a Pi the header's line offsets are the BCM GPIO numbers
+func OpenLine(chip string, offset int, bias Bias) (*Line, error) {
+	l := &Line{}
+	options := []gpiocdev.LineReqOption{
+		gpiocdev.AsInput,
+		gpiocdev.WithConsumer("petrol-pump"),
+		gpiocdev.WithBothEdges,
+		gpiocdev.WithEventHandler(l.edge),
+	}
+	switch bias {
+	case PullUp:
+		options = append(options, gpiocdev.WithPullUp)
+	case PullDown:
+		options = append(options, gpiocdev.WithPullDown)
+	default:
+		options = append(options, gpiocdev.WithBiasDisabled)
+	}
+	if bias.activeLow() {
+		options = append(options, gpiocdev.AsActiveLow)
+	}
+
+	line, err := gpiocdev.RequestLine(chip, offset, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%s line %d: %w", chip, offset, err)
+	}
+	l.line = line
+
+	// Edges only tell of changes, so start from the level now
+	value, err := line.Value()
+	if err != nil {
+		line.Close()
+		return nil, fmt.Errorf("%s line %d: %w", chip, offset, err)
+	}
+	l.pressed.Store(value == 1)
+	return l, nil
+}
+
+// edge follows the button from its edge events; active levels are already folded in
+func (l *Line) edge(ev gpiocdev.LineEvent) {
+	l.pressed.Store(ev.Type == gpiocdev.LineEventRisingEdge)
+}
+
+// Pressed implements Input
+func (l *Line) Pressed() bool {
+	return l.pressed.Load()
+}
+
+// Close implements Input
+func (l *Line) Close() error {
+	return l.line.Close()
+}
+
+// FindChip returns the name of the GPIO chip with label, such as "pinctrl-rp1" on a Pi 5
+// Chip numbers change between kernels; labels don't
+func FindChip(label string) (string, error) {
+	var labels []string
+	for _, name := range gpiocdev.Chips() {
+		chip, err := gpiocdev.NewChip(name)
+		if err != nil {
+			continue
+		}
+		found := chip.Label
+		chip.Close()
+		if found == label {
+			return name, nil
+		}
+		labels = append(labels, name+" "+found)
+	}
+	if len(labels) == 0 {
+		return "", fmt.Errorf("no GPIO chips in /dev")
+	}
+	return "", fmt.Errorf("no GPIO chip labelled %s (have %s)", label, strings.Join(labels, ", "))
+}
@@ -0,0 +1,159 @@
+package pushbutton
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gpioSimRoot is where the gpio-sim module takes simulated chips through configfs
+const gpioSimRoot = "/sys/kernel/config/gpio-sim"
+
+// simChip is a gpio-sim chip whose lines the test pulls up and down, like a button would
+type simChip struct {
+	t     *testing.T
+	dir   string // Its configfs directory
+	name  string // e.g. "gpiochip3"
+	label string
+	sysfs string // Directory of its sim_gpioN attributes
+}
+
+// newSimChip makes a live gpio-sim chip with lines lines, skipping the test if gpio-sim isn't loaded
+func newSimChip(t *testing.T, lines int) *simChip {
+	t.Helper()
+	if _, err := os.Stat(gpioSimRoot); err != nil {
+		t.Skip("gpio-sim isn't loaded (modprobe gpio-sim, with configfs mounted)")
+	}
+	c := &simChip{t: t, dir: filepath.Join(gpioSimRoot, fmt.Sprintf("petrol-pump-%d", os.Getpid())), label: "petrol-pump-sim"}
+	if err := os.Mkdir(c.dir, 0o755); err != nil {
+		t.Skipf("cannot make a gpio-sim chip: %v", err)
+	}
+	bank := filepath.Join(c.dir, "bank0")
+	t.Cleanup(func() {
+		os.WriteFile(filepath.Join(c.dir, "live"), []byte("0"), 0o644)
+		os.Remove(bank)
+		os.Remove(c.dir)
+	})
+	if err := os.Mkdir(bank, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c.write(filepath.Join(bank, "num_lines"), fmt.Sprint(lines))
+	c.write(filepath.Join(bank, "label"), c.label)
+	c.write(filepath.Join(c.dir, "live"), "1")
+
+	c.name = c.read(filepath.Join(bank, "chip_name"))
+	device := c.read(filepath.Join(c.dir, "dev_name"))
+	c.sysfs = filepath.Join("/sys/devices/platform", device, c.name)
+	return c
+}
+
+func (c *simChip) write(path, value string) {
+	c.t.Helper()
+	if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *simChip) read(path string) string {
+	c.t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// pull sets the level the simulated line floats to: "pull-up" or "pull-down"
+func (c *simChip) pull(offset int, pull string) {
+	c.write(filepath.Join(c.sysfs, fmt.Sprintf("sim_gpio%d", offset), "pull"), pull)
+}
+
+// waitPressed waits for the button to read want, as edge events arrive asynchronously
+func waitPressed(t *testing.T, button Input, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for button.Pressed() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("button pressed = %v, want %v", !want, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLineFollowsButton(t *testing.T) {
+	chip := newSimChip(t, 4)
+	tests := []struct {
+		bias            Bias
+		offset          int
+		released, press string // Line levels with the button released and pressed
+	}{
+		{PullUp, 0, "pull-up", "pull-down"},
+		{PullDown, 1, "pull-down", "pull-up"},
+		{NoBias, 2, "pull-up", "pull-down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bias.String(), func(t *testing.T) {
+			chip.pull(tt.offset, tt.released)
+			button, err := OpenLine(chip.name, tt.offset, tt.bias)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer button.Close()
+			if tt.bias == NoBias {
+				// Nothing is asked of the line's bias, so the simulated external pull-up stays
+				chip.pull(tt.offset, tt.released)
+			}
+			waitPressed(t, button, false)
+
+			chip.pull(tt.offset, tt.press)
+			waitPressed(t, button, true)
+			chip.pull(tt.offset, tt.released)
+			waitPressed(t, button, false)
+		})
+	}
+}
+
+func TestLineStartsPressed(t *testing.T) {
+	chip := newSimChip(t, 1)
+	chip.pull(0, "pull-down")
+	button, err := OpenLine(chip.name, 0, NoBias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer button.Close()
+	if !button.Pressed() {
+		t.Error("a button held while the pump started reads as released")
+	}
+}
+
+func TestFindChip(t *testing.T) {
+	chip := newSimChip(t, 1)
+	name, err := FindChip(chip.label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != chip.name {
+		t.Errorf("FindChip(%q) = %s, want %s", chip.label, name, chip.name)
+	}
+	if _, err := FindChip("no-such-chip"); err == nil {
+		t.Error("found a chip that isn't there")
+	}
+}
+
+func TestOpenLineErrors(t *testing.T) {
+	chip := newSimChip(t, 2)
+	if _, err := OpenLine(chip.name, 5, PullUp); err == nil {
+		t.Error("opened a line the chip doesn't have")
+	}
+	button, err := OpenLine(chip.name, 0, PullUp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer button.Close()
+	if _, err := OpenLine(chip.name, 0, PullUp); err == nil {
+		t.Error("opened a line that is already in use")
+	}
+}
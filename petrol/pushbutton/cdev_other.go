@@ -0,0 +1,28 @@
+//go:build !linux
+
+package pushbutton
+
+import "fmt"
+
+// Line is a button read through the GPIO character device
+type Line struct{}
+
+// OpenLine is only implemented on Linux, where the pump runs
+func OpenLine(chip string, offset int, bias Bias) (*Line, error) {
+	return nil, fmt.Errorf("GPIO character devices are only supported on Linux")
+}
+
+// Pressed implements Input
+func (l *Line) Pressed() bool {
+	return false
+}
+
+// Close implements Input
+func (l *Line) Close() error {
+	return nil
+}
+
+// FindChip is only implemented on Linux
+func FindChip(label string) (string, error) {
+	return "", fmt.Errorf("GPIO character devices are only supported on Linux")
+}
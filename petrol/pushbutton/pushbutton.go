@@ -0,0 +1,90 @@
+// Package pushbutton reads the pump's push buttons from GPIO.
+//
+// Two ways in suit different Pis: go-rpio maps the GPIO registers through
+// /dev/gpiomem, which is quick but only reaches the BCM283x/BCM2711 GPIO of the
+// Pi 4 and earlier, while the GPIO character device (/dev/gpiochipN) works on
+// any board with a modern kernel, the Pi 5 included, and reports presses as
+// edge events instead of being polled. It is also what the kernel's gpio-sim
+// module simulates, so buttons can be exercised with no Pi at all.
+package pushbutton
+
+import (
+	"fmt"
+
+	"github.com/stianeikeland/go-rpio/v4"
+)
+
+// Input is one push button
+type Input interface {
+	// Pressed reports whether the button is held down
+	Pressed() bool
+	// Close lets go of the GPIO line
+	Close() error
+}
+
+// Bias is how the GPIO line is pulled while the button is open
+// With a pull-up the button connects the line to ground, so a low level means pressed;
+// with a pull-down it connects it to 3.3V, so high means pressed
+type Bias int
+
+const (
+	PullUp   Bias = iota // Button to ground, as the pump has always been wired
+	PullDown             // Button to 3.3V
+	NoBias               // External pull-up on the line; the button connects it to ground
+)
+
+func (b Bias) String() string {
+	switch b {
+	case PullDown:
+		return "pull-down"
+	case NoBias:
+		return "none"
+	}
+	return "pull-up"
+}
+
+// ParseBias reads a bias as written by String
+func ParseBias(text string) (Bias, error) {
+	for _, b := range []Bias{PullUp, PullDown, NoBias} {
+		if text == b.String() {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("bias must be pull-up, pull-down or none, not %q", text)
+}
+
+// activeLow reports whether a low level means the button is pressed
+func (b Bias) activeLow() bool {
+	return b != PullDown
+}
+
+// RPIOPin is a button read through go-rpio; rpio.Open must have succeeded
+type RPIOPin struct {
+	pin  rpio.Pin
+	bias Bias
+}
+
+// OpenRPIO sets up GPIO pin (BCM numbering) as a button input
+func OpenRPIO(pin int, bias Bias) *RPIOPin {
+	p := &RPIOPin{pin: rpio.Pin(pin), bias: bias}
+	p.pin.Input()
+	switch bias {
+	case PullUp:
+		p.pin.PullUp()
+	case PullDown:
+		p.pin.PullDown()
+	default:
+		p.pin.PullOff()
+	}
+	return p
+}
+
+// Pressed implements Input
+func (p *RPIOPin) Pressed() bool {
+	return (p.pin.Read() == rpio.Low) == p.bias.activeLow()
+}
+
+// Close implements Input; the pin is left as an input
+func (p *RPIOPin) Close() error {
+	return nil
+}
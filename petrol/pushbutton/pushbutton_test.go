@@ -0,0 +1,31 @@
+package pushbutton
+
+import "testing"
+
+func TestParseBias(t *testing.T) {
+	tests := []struct {
+		text      string
+		want      Bias
+		activeLow bool
+		wantErr   bool
+	}{
+		{"pull-up", PullUp, true, false},
+		{"pull-down", PullDown, false, false},
+		{"none", NoBias, true, false},
+		{"up", 0, false, true},
+		{"", 0, false, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBias(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBias(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got != tt.want || got.String() != tt.text || got.activeLow() != tt.activeLow {
+			t.Errorf("ParseBias(%q) = %v (active low %v), want %v (active low %v)", tt.text, got, got.activeLow(), tt.want, tt.activeLow)
+		}
+	}
+}
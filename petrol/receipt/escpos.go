@@ -0,0 +1,73 @@
+package receipt
+
+import "bytes"
+
+// ESC/POS commands understood by common thermal receipt printers
+var (
+	escInit        = []byte{0x1b, '@'}       // Reset the printer
+	escCodePage437 = []byte{0x1b, 't', 0}    // Characters above 0x7f are from code page 437
+	escAlignLeft   = []byte{0x1b, 'a', 0}    // Left-align following lines
+	escAlignCenter = []byte{0x1b, 'a', 1}    // Centre following lines
+	escBoldOn      = []byte{0x1b, 'E', 1}    // Emphasised text
+	escBoldOff     = []byte{0x1b, 'E', 0}    // Normal text
+	escDoubleOn    = []byte{0x1d, '!', 0x11} // Double width and height
+	escDoubleOff   = []byte{0x1d, '!', 0}    // Normal size
+	escFeed        = []byte{0x1b, 'd', 4}    // Feed four lines so the receipt clears the cutter
+	escCut         = []byte{0x1d, 'V', 1}    // Partial cut
+)
+
+// ESCPOS renders the receipt as a byte stream for an ESC/POS thermal printer
+func (r Receipt) ESCPOS() []byte {
+	var b bytes.Buffer
+	b.Write(escInit)
+	b.Write(escCodePage437)
+
+	// Header centred, with the first line (the site name) large
+	b.Write(escAlignCenter)
+	for i, line := range r.Header {
+		if i == 0 {
+			b.Write(escDoubleOn)
+			b.WriteString(cp437(line) + "\n")
+			b.Write(escDoubleOff)
+			continue
+		}
+		b.WriteString(cp437(line) + "\n")
+	}
+	if len(r.Header) > 0 {
+		b.WriteString("\n")
+	}
+
+	b.Write(escAlignLeft)
+	for _, line := range r.lines(Width) {
+		bold := len(line) >= 5 && line[:5] == "TOTAL"
+		if bold {
+			b.Write(escBoldOn)
+		}
+		b.WriteString(cp437(line) + "\n")
+		if bold {
+			b.Write(escBoldOff)
+		}
+	}
+
+	b.Write(escAlignCenter)
+	b.WriteString("\nThank you\n")
+	b.Write(escFeed)
+	b.Write(escCut)
+	return b.Bytes()
+}
+
+// cp437 encodes text for code page 437, one byte per character so columns stay aligned
+func cp437(text string) string {
+	var b bytes.Buffer
+	for _, r := range text {
+		switch {
+		case r == '£':
+			b.WriteByte(0x9c)
+		case r < 0x80:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package receipt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"petrol-pump/serial"
+)
+
+// DefaultBaud is the serial speed most thermal printers ship with
+const DefaultBaud = 9600
+
+// Printer prints receipts
+type Printer interface {
+	Print(r Receipt) error
+}
+
+// FilePrinter appends receipts to a file, for testing without a printer
+// Receipts are written as plain text, or as the ESC/POS bytes a printer would get when Raw is set
+type FilePrinter struct {
+	Path string
+	Raw  bool
+}
+
+// Print implements Printer
+func (p FilePrinter) Print(r Receipt) error {
+	file, err := os.OpenFile(p.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("receipt file: %w", err)
+	}
+	defer file.Close()
+
+	data := []byte(r.Text() + strings.Repeat("=", Width) + "\n")
+	if p.Raw {
+		data = r.ESCPOS()
+	}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("receipt file: %w", err)
+	}
+	return file.Sync()
+}
+
+// DevicePrinter sends ESC/POS receipts to a thermal printer
+// Path is a USB printer (/dev/usb/lp0) or a serial port (/dev/ttyUSB0, /dev/serial0),
+// which is set to Baud (DefaultBaud if zero), 8 data bits, no parity, before printing
+type DevicePrinter struct {
+	Path string
+	Baud int
+}
+
+// Print implements Printer
+func (p DevicePrinter) Print(r Receipt) error {
+	file, err := os.OpenFile(p.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("printer: %w", err)
+	}
+	defer file.Close()
+
+	if isSerial(p.Path) {
+		baud := p.Baud
+		if baud == 0 {
+			baud = DefaultBaud
+		}
+		if err := serial.Configure(file, baud); err != nil {
+			return fmt.Errorf("printer %s: %w", p.Path, err)
+		}
+	}
+	if _, err := file.Write(r.ESCPOS()); err != nil {
+		return fmt.Errorf("printer: %w", err)
+	}
+	return nil
+}
+
+// isSerial reports whether path looks like a serial port rather than a USB printer
+func isSerial(path string) bool {
+	return strings.HasPrefix(path, "/dev/tty") || strings.HasPrefix(path, "/dev/serial")
+}
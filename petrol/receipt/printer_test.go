@@ -0,0 +1,62 @@
+package receipt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePrinter(t *testing.T) {
+	dir := t.TempDir()
+	text := FilePrinter{Path: filepath.Join(dir, "receipts.txt")}
+	raw := FilePrinter{Path: filepath.Join(dir, "receipts.bin"), Raw: true}
+	for i := 0; i < 2; i++ {
+		if err := text.Print(testReceipt); err != nil {
+			t.Fatal(err)
+		}
+		if err := raw.Print(testReceipt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := os.ReadFile(text.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := testReceiptText + strings.Repeat("=", Width) + "\n"
+	if string(got) != one+one {
+		t.Errorf("text file holds\n%s\nwant two receipts, each followed by a rule", got)
+	}
+
+	got, err = os.ReadFile(raw.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bytes.Repeat(testReceipt.ESCPOS(), 2); !bytes.Equal(got, want) {
+		t.Errorf("raw file holds %q, want the ESC/POS stream twice", got)
+	}
+
+	if err := (FilePrinter{Path: filepath.Join(dir, "missing", "receipts.txt")}).Print(testReceipt); err == nil {
+		t.Error("printed to a directory that doesn't exist")
+	}
+}
+
+func TestIsSerial(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/dev/ttyUSB0", true},
+		{"/dev/ttyAMA0", true},
+		{"/dev/serial0", true},
+		{"/dev/usb/lp0", false},
+		{"/tmp/receipts.txt", false},
+	}
+	for _, tt := range tests {
+		if got := isSerial(tt.path); got != tt.want {
+			t.Errorf("isSerial(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
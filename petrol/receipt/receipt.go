@@ -0,0 +1,140 @@
+// Package receipt renders sale receipts as plain text or as ESC/POS commands
+// for a thermal printer, and sends them to a printer or a file.
+package receipt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+const (
+	// DefaultVATRate is the UK standard rate of VAT on fuel, in percent
+	DefaultVATRate = 20
+
+	// Width is the number of characters on a line of a 58mm thermal printer
+	Width = 32
+)
+
+// Receipt is everything printed for one paid sale
+type Receipt struct {
+	Header  []string // Site name, address etc, one line each
+	Number  uint64   // Transaction number, 0 if unknown
+	Pump    int
+	Time    time.Time
+	Grade   string
+	Volume  pump.Volume
+	Price   pump.UnitPrice
+	Total   pump.Money
+	VATRate int64  // Percent, included in Total
+	CardUID string // Shown masked
+}
+
+// New builds the receipt for a paid sale on pump pumpNumber
+func New(header []string, number uint64, pumpNumber int, sale pump.Sale) Receipt {
+	return Receipt{
+		Header:  header,
+		Number:  number,
+		Pump:    pumpNumber,
+		Time:    sale.End,
+		Grade:   sale.Grade,
+		Volume:  sale.Volume,
+		Price:   sale.PricePerLitre,
+		Total:   sale.Amount,
+		VATRate: DefaultVATRate,
+		CardUID: sale.CardUID,
+	}
+}
+
+// VAT returns the VAT included in the total, rounded half-up to the penny
+func (r Receipt) VAT() pump.Money {
+	if r.VATRate <= 0 {
+		return 0
+	}
+	// total × rate / (100 + rate)
+	num := int64(r.Total) * r.VATRate
+	den := 100 + r.VATRate
+	return pump.Money((2*num + den) / (2 * den))
+}
+
+// Net returns the total excluding VAT
+func (r Receipt) Net() pump.Money {
+	return r.Total - r.VAT()
+}
+
+// MaskUID hides all but the last byte of a card UID, e.g. "A3:B2:C1:D0" → "**:**:**:D0"
+// A UID without colons keeps only its last two characters
+func MaskUID(uid string) string {
+	if uid == "" {
+		return ""
+	}
+	if !strings.Contains(uid, ":") {
+		keep := max(len(uid)-2, 0)
+		return strings.Repeat("*", keep) + uid[keep:]
+	}
+	parts := strings.Split(uid, ":")
+	for i := 0; i < len(parts)-1; i++ {
+		parts[i] = strings.Repeat("*", len(parts[i]))
+	}
+	return strings.Join(parts, ":")
+}
+
+// lines returns the body of the receipt, without the header, for a printer width characters wide
+func (r Receipt) lines(width int) []string {
+	row := func(label, value string) string {
+		gap := width - len([]rune(label)) - len([]rune(value))
+		if gap < 1 {
+			gap = 1
+		}
+		return label + strings.Repeat(" ", gap) + value
+	}
+	rule := strings.Repeat("-", width)
+
+	var lines []string
+	if r.Number > 0 {
+		lines = append(lines, row("Transaction", fmt.Sprintf("%06d", r.Number)))
+	}
+	lines = append(lines,
+		row("Date", r.Time.Format("02/01/2006 15:04:05")),
+		row("Pump", fmt.Sprintf("%d", r.Pump)),
+		rule,
+		row(r.Grade, r.Volume.String()),
+		row("  @", r.Price.String()),
+		rule,
+		row("TOTAL", r.Total.String()),
+		row("Net", r.Net().String()),
+		row(fmt.Sprintf("VAT @ %d%%", r.VATRate), r.VAT().String()),
+		rule,
+	)
+	if r.CardUID != "" {
+		lines = append(lines, row("Card", MaskUID(r.CardUID)))
+	}
+	return lines
+}
+
+// Text renders the receipt as plain text
+func (r Receipt) Text() string {
+	var b strings.Builder
+	for _, line := range r.Header {
+		b.WriteString(center(line, Width) + "\n")
+	}
+	if len(r.Header) > 0 {
+		b.WriteString("\n")
+	}
+	for _, line := range r.lines(Width) {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n" + center("Thank you", Width) + "\n")
+	return b.String()
+}
+
+// center pads text with spaces to sit in the middle of a line width characters wide
+func center(text string, width int) string {
+	pad := (width - len([]rune(text))) / 2
+	if pad <= 0 {
+		return text
+	}
+	return strings.Repeat(" ", pad) + text
+}
@@ -0,0 +1,177 @@
+package receipt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"petrol-pump/pump"
+)
+
+// testReceipt is a 40 L fill of unleaded, paid by card
+var testReceipt = Receipt{
+	Header:  []string{"PETROL PUMP", "1 High Street"},
+	Number:  42,
+	Pump:    2,
+	Time:    time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+	Grade:   "Unleaded",
+	Volume:  40 * pump.Litre,
+	Price:   1459,
+	Total:   5836,
+	VATRate: DefaultVATRate,
+	CardUID: "A3:B2:C1:D0",
+}
+
+const testReceiptText = `          PETROL PUMP
+         1 High Street
+
+Transaction               000042
+Date         14/03/2026 09:26:53
+Pump                           2
+--------------------------------
+Unleaded                 40.00 L
+  @                     £1.459/L
+--------------------------------
+TOTAL                     £58.36
+Net                       £48.63
+VAT @ 20%                  £9.73
+--------------------------------
+Card                 **:**:**:D0
+
+           Thank you
+`
+
+func TestNew(t *testing.T) {
+	sale := pump.Sale{
+		Start:         testReceipt.Time.Add(-2 * time.Minute),
+		End:           testReceipt.Time,
+		Grade:         "Unleaded",
+		Volume:        40 * pump.Litre,
+		PricePerLitre: 1459,
+		Amount:        5836,
+		Outcome:       pump.OutcomePaid,
+		CardUID:       "A3:B2:C1:D0",
+	}
+	got := New(testReceipt.Header, 42, 2, sale)
+	if got.Text() != testReceipt.Text() {
+		t.Errorf("New gave\n%s\nwant\n%s", got.Text(), testReceipt.Text())
+	}
+}
+
+func TestText(t *testing.T) {
+	if got := testReceipt.Text(); got != testReceiptText {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, testReceiptText)
+	}
+	for _, line := range testReceipt.lines(Width) {
+		if n := len([]rune(line)); n != Width {
+			t.Errorf("line %q is %d characters, want %d", line, n, Width)
+		}
+	}
+
+	// No transaction number, no card and no header: those lines are left out
+	r := testReceipt
+	r.Number, r.CardUID, r.Header = 0, "", nil
+	want := testReceiptText[len("          PETROL PUMP\n         1 High Street\n\nTransaction               000042\n"):]
+	want = want[:len(want)-len("Card                 **:**:**:D0\n\n           Thank you\n")] + "\n           Thank you\n"
+	if got := r.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestESCPOS(t *testing.T) {
+	var want bytes.Buffer
+	for _, part := range []string{
+		"\x1b@",     // Initialise
+		"\x1bt\x00", // Code page 437
+		"\x1ba\x01", // Centred
+		"\x1d!\x11PETROL PUMP\n\x1d!\x00",
+		"1 High Street\n\n",
+		"\x1ba\x00", // Left-aligned
+		"Transaction               000042\n",
+		"Date         14/03/2026 09:26:53\n",
+		"Pump                           2\n",
+		"--------------------------------\n",
+		"Unleaded                 40.00 L\n",
+		"  @                     \x9c1.459/L\n", // £ is 0x9C in code page 437
+		"--------------------------------\n",
+		"\x1bE\x01TOTAL                     \x9c58.36\n\x1bE\x00",
+		"Net                       \x9c48.63\n",
+		"VAT @ 20%                  \x9c9.73\n",
+		"--------------------------------\n",
+		"Card                 **:**:**:D0\n",
+		"\x1ba\x01\nThank you\n",
+		"\x1bd\x04", // Feed
+		"\x1dV\x01", // Cut
+	} {
+		want.WriteString(part)
+	}
+	if got := testReceipt.ESCPOS(); !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("ESCPOS() =\n%q\nwant\n%q", got, want.Bytes())
+	}
+}
+
+func TestCP437(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Pump 1", "Pump 1"},
+		{"£58.36", "\x9c58.36"},
+		{"Café", "Caf?"},
+		{"€5 ✓", "?5 ?"},
+	}
+	for _, tt := range tests {
+		if got := cp437(tt.text); got != tt.want {
+			t.Errorf("cp437(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestVAT(t *testing.T) {
+	tests := []struct {
+		total pump.Money
+		rate  int64
+		want  pump.Money
+	}{
+		{0, 20, 0},
+		{1, 20, 0},      // 0.17p
+		{2, 20, 0},      // 0.33p
+		{3, 20, 1},      // 0.5p, rounded up
+		{4, 20, 1},      // 0.67p
+		{6, 20, 1},      // Exactly 1p
+		{9, 20, 2},      // 1.5p, rounded up
+		{15, 20, 3},     // 2.5p, rounded up
+		{5836, 20, 973}, // 972.67p
+		{5838, 20, 973}, // 973p exactly
+		{5841, 20, 974}, // 973.5p, rounded up
+		{2100, 5, 100},  // Reduced rate
+		{5836, 0, 0},    // Zero-rated
+		{5836, -20, 0},  // Nonsense rate
+		{100000, 20, 16667},
+	}
+	for _, tt := range tests {
+		r := Receipt{Total: tt.total, VATRate: tt.rate}
+		if got := r.VAT(); got != tt.want {
+			t.Errorf("VAT on %s at %d%% = %s, want %s", tt.total, tt.rate, got, tt.want)
+		}
+		if r.Net()+r.VAT() != tt.total {
+			t.Errorf("%s at %d%%: net %s and VAT %s don't add up", tt.total, tt.rate, r.Net(), r.VAT())
+		}
+	}
+}
+
+func TestMaskUID(t *testing.T) {
+	tests := []struct {
+		uid, want string
+	}{
+		{"", ""},
+		{"A3:B2:C1:D0", "**:**:**:D0"},
+		{"04:A2:3B:11:22:33:44", "**:**:**:**:**:**:44"},
+		{"DEADBEEF", "******EF"},
+		{"D", "D"},
+	}
+	for _, tt := range tests {
+		if got := MaskUID(tt.uid); got != tt.want {
+			t.Errorf("MaskUID(%q) = %q, want %q", tt.uid, got, tt.want)
+		}
+	}
+}
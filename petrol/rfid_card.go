@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// CardFamily is the kind of card, worked out from its ATQA and SAK (NXP AN10833)
+type CardFamily int
+
+const (
+	CardUnknown         CardFamily = iota
+	CardMifareClassic1K            // MIFARE Classic 1K (and Mini)
+	CardMifareClassic4K            // MIFARE Classic 4K
+	CardUltralight                 // MIFARE Ultralight and NTAG21x
+	CardDESFire                    // MIFARE DESFire
+	CardISODEP                     // Any other ISO/IEC 14443-4 card, e.g. a bank card or phone
+)
+
+func (f CardFamily) String() string {
+	switch f {
+	case CardMifareClassic1K:
+		return "MIFARE Classic 1K"
+	case CardMifareClassic4K:
+		return "MIFARE Classic 4K"
+	case CardUltralight:
+		return "Ultralight/NTAG"
+	case CardDESFire:
+		return "DESFire"
+	case CardISODEP:
+		return "ISO-DEP"
+	}
+	return "Unknown"
+}
+
+// Card describes a card read by an RFIDReader
+type Card struct {
+	UID    []byte  // 4, 7 or 10 bytes
+	ATQA   [2]byte // Answer to request, least significant byte first
+	SAK    byte    // Select acknowledge
+	Family CardFamily
+}
+
+// newCard describes a card from what it reported while being selected
+func newCard(id cardIdentity) Card {
+	return Card{UID: id.UID, ATQA: id.ATQA, SAK: id.SAK, Family: cardFamily(id.ATQA, id.SAK)}
+}
+
+// cardFamily identifies a card from its ATQA and SAK
+func cardFamily(atqa [2]byte, sak byte) CardFamily {
+	switch sak {
+	case 0x08, 0x09, 0x88, 0x28:
+		// 0x28 is a SmartMX card emulating Classic 1K alongside ISO-DEP
+		return CardMifareClassic1K
+	case 0x18, 0x38:
+		return CardMifareClassic4K
+	case 0x00:
+		return CardUltralight
+	}
+	if sak&0x20 != 0 {
+		// ISO/IEC 14443-4 compliant - DESFire answers REQA with 0x0344
+		if atqa == [2]byte{0x44, 0x03} {
+			return CardDESFire
+		}
+		return CardISODEP
+	}
+	return CardUnknown
+}
+
+// ID returns the card's UID in the usual colon-separated form, e.g. "04:A2:3B:11:22:33:44"
+// A card that couldn't be read is "Unknown"
+func (c Card) ID() string {
+	if len(c.UID) == 0 {
+		return "Unknown"
+	}
+	return formatUID(c.UID)
+}
+
+func (c Card) String() string {
+	if len(c.UID) == 0 {
+		return c.ID()
+	}
+	return fmt.Sprintf("%s (%s, ATQA %02X%02X, SAK %02X)", c.ID(), c.Family, c.ATQA[1], c.ATQA[0], c.SAK)
+}
+
+// formatUID converts a UID of any length to colon-separated hex, e.g. "A3:B2:C1:D0"
+func formatUID(uid []byte) string {
+	parts := make([]string, len(uid))
+	for i, b := range uid {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseUID converts a UID from formatUID back to bytes
+func parseUID(s string) ([]byte, error) {
+	uid, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(uid) == 0 {
+		return nil, fmt.Errorf("bad card UID %q", s)
+	}
+	return uid, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MIFARE Classic commands, sent to a selected card (NXP MF1S50yyX section 12)
+const (
+	classicAuthKeyA  = 0x60
+	classicAuthKeyB  = 0x61
+	classicRead      = 0x30
+	classicWrite     = 0xA0
+	classicDecrement = 0xC0
+	classicIncrement = 0xC1
+	classicTransfer  = 0xB0
+	classicAck       = 0x0A // 4-bit acknowledge; anything else is a NAK
+)
+
+var (
+	errAuthFailed    = errors.New("sector authentication failed")
+	errNotValueBlock = errors.New("not a value block")
+
+	// errValueUnconfirmed means a value change reached the card, which may or may not have committed it
+	// Only reading the block again tells
+	errValueUnconfirmed = errors.New("value change unconfirmed")
+)
+
+// ClassicKey is one of a MIFARE Classic sector's two keys
+type ClassicKey struct {
+	B   bool // Key B rather than key A
+	Key [6]byte
+}
+
+// DefaultClassicKey is key A as blank cards are shipped
+var DefaultClassicKey = ClassicKey{Key: [6]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}}
+
+// ParseClassicKey parses a key as "A:FFFFFFFFFFFF" or "B:FFFFFFFFFFFF" (key A if there's no prefix)
+func ParseClassicKey(s string) (ClassicKey, error) {
+	var key ClassicKey
+	if slot, hexKey, ok := strings.Cut(s, ":"); ok {
+		switch strings.ToUpper(slot) {
+		case "A":
+		case "B":
+			key.B = true
+		default:
+			return key, fmt.Errorf("key %q: want key A or B", s)
+		}
+		s = hexKey
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(key.Key) {
+		return key, fmt.Errorf("key %q: want 12 hex digits", s)
+	}
+	copy(key.Key[:], b)
+	return key, nil
+}
+
+// String names the key without giving it away
+func (k ClassicKey) String() string {
+	if k.B {
+		return "key B"
+	}
+	return "key A"
+}
+
+func (k ClassicKey) authCommand() byte {
+	if k.B {
+		return classicAuthKeyB
+	}
+	return classicAuthKeyA
+}
+
+// classicTrailer reports whether block is a sector trailer (the sector's keys and access bits)
+// The first 32 sectors have 4 blocks each, the 8 large sectors of a 4K card have 16
+func classicTrailer(block byte) bool {
+	if block < 128 {
+		return block%4 == 3
+	}
+	return block%16 == 15
+}
+
+// checkValueBlock refuses blocks that can't hold a value
+func checkValueBlock(block byte) error {
+	switch {
+	case block == 0:
+		return fmt.Errorf("block 0 is the manufacturer block")
+	case classicTrailer(block):
+		return fmt.Errorf("block %d is a sector trailer", block)
+	}
+	return nil
+}
+
+// encodeValueBlock lays out value in the MIFARE value block format:
+// the value, its inverse and the value again, then the block address, its inverse, the address and its inverse
+func encodeValueBlock(value int32, block byte) []byte {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint32(data[0:], uint32(value))
+	binary.LittleEndian.PutUint32(data[4:], ^uint32(value))
+	binary.LittleEndian.PutUint32(data[8:], uint32(value))
+	data[12], data[13], data[14], data[15] = block, ^block, block, ^block
+	return data
+}
+
+// decodeValueBlock checks the redundant copies in a value block and returns its value
+func decodeValueBlock(data []byte) (int32, error) {
+	if len(data) != 16 {
+		return 0, fmt.Errorf("%w: %d bytes", errNotValueBlock, len(data))
+	}
+	v := binary.LittleEndian.Uint32(data[0:])
+	if binary.LittleEndian.Uint32(data[4:]) != ^v || binary.LittleEndian.Uint32(data[8:]) != v ||
+		data[13] != ^data[12] || data[14] != data[12] || data[15] != ^data[12] {
+		return 0, fmt.Errorf("%w: % X", errNotValueBlock, data)
+	}
+	return int32(v), nil
+}
+
+// ValueCardReader is an RFIDReader that can also use the value blocks on MIFARE Classic cards
+// The card must still be on the reader; it is woken up again if it was halted after being read
+type ValueCardReader interface {
+	RFIDReader
+	// ReadValue returns the value held in block
+	ReadValue(card Card, key ClassicKey, block byte) (int32, error)
+	// AddValue adds delta (negative to take away) to the value in block and returns the new value
+	// Once the change has been sent to the card, errors match errValueUnconfirmed
+	AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error)
+	// WriteValue formats block as a value block holding value
+	WriteValue(card Card, key ClassicKey, block byte, value int32) error
+}
+
+// ReadValue implements ValueCardReader
+func (r *MFRC522RFIDReader) ReadValue(card Card, key ClassicKey, block byte) (int32, error) {
+	return r.picc.readValue(card.UID, key, block)
+}
+
+// AddValue implements ValueCardReader
+func (r *MFRC522RFIDReader) AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error) {
+	return r.picc.addValue(card.UID, key, block, delta)
+}
+
+// WriteValue implements ValueCardReader
+func (r *MFRC522RFIDReader) WriteValue(card Card, key ClassicKey, block byte, value int32) error {
+	return r.picc.writeValue(card.UID, key, block, value)
+}
+
+// ReadValue implements ValueCardReader
+func (g *GobotRFIDReader) ReadValue(card Card, key ClassicKey, block byte) (int32, error) {
+	if g.picc == nil {
+		return 0, fmt.Errorf("driver not initialized")
+	}
+	return g.picc.readValue(card.UID, key, block)
+}
+
+// AddValue implements ValueCardReader
+func (g *GobotRFIDReader) AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error) {
+	if g.picc == nil {
+		return 0, fmt.Errorf("driver not initialized")
+	}
+	return g.picc.addValue(card.UID, key, block, delta)
+}
+
+// WriteValue implements ValueCardReader
+func (g *GobotRFIDReader) WriteValue(card Card, key ClassicKey, block byte, value int32) error {
+	if g.picc == nil {
+		return fmt.Errorf("driver not initialized")
+	}
+	return g.picc.writeValue(card.UID, key, block, value)
+}
+
+// readValue reads the value block at block
+func (c *iso14443a) readValue(uid []byte, key ClassicKey, block byte) (int32, error) {
+	var value int32
+	err := c.classicSession(uid, key, block, func() error {
+		data, err := c.readBlock(block)
+		if err != nil {
+			return err
+		}
+		value, err = decodeValueBlock(data)
+		return err
+	})
+	return value, err
+}
+
+// addValue increments or decrements the value block at block, then reads back the result
+// The card only commits the change with TRANSFER, so an interrupted update leaves the old value;
+// anything going wrong from the TRANSFER on is errValueUnconfirmed, as the card may have committed it
+func (c *iso14443a) addValue(uid []byte, key ClassicKey, block byte, delta int32) (int32, error) {
+	var value int32
+	err := c.classicSession(uid, key, block, func() error {
+		cmd, operand := byte(classicIncrement), uint32(delta)
+		if delta < 0 {
+			cmd, operand = classicDecrement, uint32(-int64(delta))
+		}
+		if err := c.valueOperation(cmd, block, operand); err != nil {
+			return err
+		}
+		data, err := c.readBlock(block)
+		if err != nil {
+			return fmt.Errorf("%w: reading block %d back: %w", errValueUnconfirmed, block, err)
+		}
+		if value, err = decodeValueBlock(data); err != nil {
+			return fmt.Errorf("%w: %w", errValueUnconfirmed, err)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// writeValue formats block as a value block holding value
+func (c *iso14443a) writeValue(uid []byte, key ClassicKey, block byte, value int32) error {
+	return c.classicSession(uid, key, block, func() error {
+		return c.writeBlock(block, encodeValueBlock(value, block))
+	})
+}
+
+// classicSession wakes and selects the card with uid, authenticates to block's sector and runs fn
+// The reader's crypto is switched off again afterwards, whatever happens
+func (c *iso14443a) classicSession(uid []byte, key ClassicKey, block byte, fn func() error) error {
+	if err := checkValueBlock(block); err != nil {
+		return err
+	}
+	return c.cardSession(uid, func() error {
+		defer c.clearBits(mfrcStatus2Reg, 0x08) // MFCrypto1On
+		if err := c.authenticate(key, block, uid); err != nil {
+			return err
+		}
+		return fn()
+	})
+}
+
+// authenticate runs the MFRC522's MFAuthent command, which switches on Crypto1 for the sector holding block
+func (c *iso14443a) authenticate(key ClassicKey, block byte, uid []byte) error {
+	// The card expects the last four UID bytes - the whole UID of a 4-byte card, cascade level 2 of a 7-byte one
+	frame := append([]byte{key.authCommand(), block}, key.Key[:]...)
+	frame = append(frame, uid[len(uid)-4:]...)
+	if err := c.loadFIFO(frame); err != nil {
+		return err
+	}
+	if err := c.bus.writeRegister(mfrcCommandReg, mfrcCmdMFAuthent); err != nil {
+		return err
+	}
+
+	// Wait for the command to finish (IdleIRq), or the reader's timer (TimerIRq)
+	deadline := time.Now().Add(transceiveTimeout)
+	for {
+		irq, err := c.bus.readRegister(mfrcComIrqReg)
+		if err != nil {
+			return err
+		}
+		if irq&0x10 != 0 {
+			break
+		}
+		if irq&0x01 != 0 || time.Now().After(deadline) {
+			c.bus.writeRegister(mfrcCommandReg, mfrcCmdIdle)
+			return fmt.Errorf("%w: %s for block %d: no answer", errAuthFailed, key, block)
+		}
+	}
+
+	errReg, err := c.bus.readRegister(mfrcErrorReg)
+	if err != nil {
+		return err
+	}
+	status, err := c.bus.readRegister(mfrcStatus2Reg)
+	if err != nil {
+		return err
+	}
+	if errReg&0x13 != 0 || status&0x08 == 0 {
+		return fmt.Errorf("%w: %s for block %d", errAuthFailed, key, block)
+	}
+	return nil
+}
+
+// readBlock reads the 16 bytes of block
+func (c *iso14443a) readBlock(block byte) ([]byte, error) {
+	frame := []byte{classicRead, block}
+	frame = append(frame, crcA(frame)...)
+	resp, validBits, err := c.transceive(frame, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != 18 || validBits != 0 {
+		return nil, fmt.Errorf("read of block %d refused (% X)", block, resp)
+	}
+	if crc := crcA(resp[:16]); resp[16] != crc[0] || resp[17] != crc[1] {
+		return nil, fmt.Errorf("read of block %d: CRC mismatch", block)
+	}
+	return resp[:16], nil
+}
+
+// writeBlock writes 16 bytes to block
+func (c *iso14443a) writeBlock(block byte, data []byte) error {
+	if err := c.classicCommand([]byte{classicWrite, block}); err != nil {
+		return err
+	}
+	return c.classicCommand(data)
+}
+
+// valueOperation increments or decrements block by operand and transfers the result back to it
+func (c *iso14443a) valueOperation(cmd, block byte, operand uint32) error {
+	if err := c.classicCommand([]byte{cmd, block}); err != nil {
+		return err
+	}
+
+	// The card doesn't acknowledge the operand, so silence is success
+	frame := binary.LittleEndian.AppendUint32(nil, operand)
+	frame = append(frame, crcA(frame)...)
+	if resp, _, err := c.transceive(frame, 0, 0); err == nil {
+		return fmt.Errorf("value operation on block %d refused (% X)", block, resp)
+	} else if !errors.Is(err, errNoCard) {
+		return err
+	}
+
+	if err := c.classicCommand([]byte{classicTransfer, block}); err != nil {
+		return fmt.Errorf("%w: transfer to block %d: %w", errValueUnconfirmed, block, err)
+	}
+	return nil
+}
+
+// classicCommand sends data with its CRC and checks for the card's 4-bit ACK
+func (c *iso14443a) classicCommand(data []byte) error {
+	frame := append(append([]byte{}, data...), crcA(data)...)
+	resp, validBits, err := c.transceive(frame, 0, 0)
+	if err != nil {
+		return err
+	}
+	if len(resp) != 1 || validBits != 4 || resp[0]&0x0F != classicAck {
+		return fmt.Errorf("command %02X refused (% X)", data[0], resp)
+	}
+	return nil
+}
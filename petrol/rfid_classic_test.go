@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestValueBlockRoundTrip(t *testing.T) {
+	for _, value := range []int32{0, 1, -1, 1875, math.MaxInt32, math.MinInt32} {
+		data := encodeValueBlock(value, 5)
+		got, err := decodeValueBlock(data)
+		if err != nil || got != value {
+			t.Errorf("value %d came back as %d, %v", value, got, err)
+		}
+	}
+
+	// 100 in block 5, laid out as in NXP MF1S50yyX section 8.6.2.1
+	want := []byte{
+		0x64, 0x00, 0x00, 0x00, 0x9B, 0xFF, 0xFF, 0xFF, 0x64, 0x00, 0x00, 0x00,
+		0x05, 0xFA, 0x05, 0xFA,
+	}
+	if got := encodeValueBlock(100, 5); !bytes.Equal(got, want) {
+		t.Errorf("encodeValueBlock(100, 5) = % X, want % X", got, want)
+	}
+}
+
+func TestDecodeValueBlockErrors(t *testing.T) {
+	spoil := func(i int) []byte {
+		data := encodeValueBlock(100, 5)
+		data[i] ^= 0x01
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short", encodeValueBlock(100, 5)[:15]},
+		{"inverse wrong", spoil(4)},
+		{"copy wrong", spoil(8)},
+		{"address inverse wrong", spoil(13)},
+		{"address copy wrong", spoil(14)},
+		{"second address inverse wrong", spoil(15)},
+		{"blank block", make([]byte, 16)},
+	}
+	for _, tt := range tests {
+		if v, err := decodeValueBlock(tt.data); !errors.Is(err, errNotValueBlock) {
+			t.Errorf("%s: decoded %d, %v; want errNotValueBlock", tt.name, v, err)
+		}
+	}
+}
+
+func TestCheckValueBlock(t *testing.T) {
+	tests := []struct {
+		block byte
+		ok    bool
+	}{
+		{0, false},
+		{1, true},
+		{3, false},
+		{4, true},
+		{127, false},
+		{128, true},
+		{131, true}, // Large sectors only have a trailer every 16 blocks
+		{143, false},
+		{255, false},
+	}
+	for _, tt := range tests {
+		if err := checkValueBlock(tt.block); (err == nil) != tt.ok {
+			t.Errorf("checkValueBlock(%d) = %v, want ok %v", tt.block, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CardEventKind says whether a card came into the reader's field or left it
+type CardEventKind int
+
+const (
+	CardArrived CardEventKind = iota
+	CardRemoved
+)
+
+func (k CardEventKind) String() string {
+	if k == CardRemoved {
+		return "removed"
+	}
+	return "arrived"
+}
+
+// CardEvent is a card arriving at or leaving the reader
+// A card arrives once and is not reported again until it has been removed
+type CardEvent struct {
+	Kind CardEventKind
+	Card Card
+	Time time.Time
+}
+
+func (e CardEvent) String() string {
+	return fmt.Sprintf("card %s %s", e.Card.ID(), e.Kind)
+}
+
+// CardEventReader is an RFIDReader that publishes card arrivals and removals itself
+type CardEventReader interface {
+	RFIDReader
+	// CardEvents watches the field until ctx is done, then closes the channel
+	CardEvents(ctx context.Context) <-chan CardEvent
+}
+
+// How often a card on the reader is checked for, and how many missed checks mean it has gone
+// A card at the edge of the field can miss the odd check without being taken away
+const (
+	cardPresenceInterval = 200 * time.Millisecond
+	cardRemovalMisses    = 2
+	cardPollInterval     = 100 * time.Millisecond // For readers without an IRQ line
+	cardDetectTimeout    = 300 * time.Millisecond
+)
+
+// cardDetector is what watchCards needs from a reader to publish card events
+type cardDetector interface {
+	// detectCard waits up to timeout for a new card to come into the field and selects it
+	// The card is halted afterwards, so it doesn't answer detectCard again while it stays
+	detectCard(timeout time.Duration) (Card, error)
+	// stillPresent reports whether the card with uid is still in the field
+	stillPresent(uid []byte) bool
+}
+
+// watchCards publishes arrivals and removals from a detector until ctx is done
+func watchCards(ctx context.Context, d cardDetector) <-chan CardEvent {
+	events := make(chan CardEvent, 4)
+	go func() {
+		defer close(events)
+		publish := func(kind CardEventKind, card Card) bool {
+			select {
+			case events <- CardEvent{Kind: kind, Card: card, Time: time.Now()}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var lastError time.Time
+		for ctx.Err() == nil {
+			card, err := d.detectCard(cardDetectTimeout)
+			if err != nil {
+				if !isNoCard(err) {
+					if time.Since(lastError) > 5*time.Second {
+						fmt.Printf("DEBUG: card detection error: %v\n", err)
+						lastError = time.Now()
+					}
+					time.Sleep(cardPollInterval) // Don't spin on a reader that fails straight away
+				}
+				continue
+			}
+			if !publish(CardArrived, card) {
+				return
+			}
+
+			// Wait for it to leave before looking for another
+			for misses := 0; misses < cardRemovalMisses; {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(cardPresenceInterval):
+				}
+				if d.stillPresent(card.UID) {
+					misses = 0
+				} else {
+					misses++
+				}
+			}
+			if !publish(CardRemoved, card) {
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// isNoCard reports whether a detection error only means there was no card to detect
+// Drivers wrap errNoCard around the answers that mean an empty field; anything else is the reader failing
+func isNoCard(err error) bool {
+	return errors.Is(err, errNoCard)
+}
+
+// pollCards publishes card events for a reader that can only be polled
+// A card that is still present when polled again is the same tap, not a new one
+func pollCards(ctx context.Context, reader RFIDReader) <-chan CardEvent {
+	return watchCards(ctx, &pollingDetector{reader: reader})
+}
+
+type pollingDetector struct {
+	reader RFIDReader
+}
+
+func (p *pollingDetector) detectCard(timeout time.Duration) (Card, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		present, err := p.reader.IsCardPresent()
+		if err != nil {
+			return Card{}, err
+		}
+		if present {
+			return p.reader.ReadCard()
+		}
+		if time.Now().After(deadline) {
+			return Card{}, errNoCard
+		}
+		time.Sleep(cardPollInterval)
+	}
+}
+
+func (p *pollingDetector) stillPresent(uid []byte) bool {
+	present, err := p.reader.IsCardPresent()
+	if err != nil || !present {
+		return false
+	}
+	card, err := p.reader.ReadCard()
+	return err == nil && bytes.Equal(card.UID, uid)
+}
+
+// cardEvents returns a reader's card events, polling readers that don't publish their own
+func cardEvents(ctx context.Context, reader RFIDReader) <-chan CardEvent {
+	if r, ok := reader.(CardEventReader); ok {
+		return r.CardEvents(ctx)
+	}
+	return pollCards(ctx, reader)
+}
+
+// cardStream hands a reader's card events to everything subscribed to them
+type cardStream struct {
+	mu   sync.Mutex
+	subs map[chan CardEvent]struct{}
+}
+
+func newCardStream() *cardStream {
+	return &cardStream{subs: make(map[chan CardEvent]struct{})}
+}
+
+// Subscribe returns a channel of card events and a function that ends the subscription
+// A subscriber that falls behind misses events rather than holding up the others
+func (s *cardStream) Subscribe() (<-chan CardEvent, func()) {
+	ch := make(chan CardEvent, 8)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// forward publishes every event from events until the channel closes
+func (s *cardStream) forward(events <-chan CardEvent) {
+	for ev := range events {
+		s.publish(ev)
+	}
+}
+
+func (s *cardStream) publish(ev CardEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			fmt.Printf("⚠ Card event dropped for a slow subscriber: %s\n", ev)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestIsNoCard(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errNoCard, true},
+		{fmt.Errorf("no card present: %w", errNoCard), true},
+		{fmt.Errorf("cascade level 1: %w", errNoCard), true},
+		{fmt.Errorf("%w: mfrc522-low-level: timeout waiting for IRQ edge: 300ms", errNoCard), true},
+		{fmt.Errorf("read /dev/ttyS0: %w", os.ErrDeadlineExceeded), false}, // "i/o timeout" from a PN532 that stopped answering
+		{errors.New("spi: i/o timeout"), false},
+		{errors.New("no tag answered"), false}, // Only errNoCard says so
+		{errReaderHung, false},
+		{fmt.Errorf("bad ATQA % X", []byte{0x04}), false},
+	}
+	for _, tt := range tests {
+		if got := isNoCard(tt.err); got != tt.want {
+			t.Errorf("isNoCard(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
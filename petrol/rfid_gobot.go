@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"gobot.io/x/gobot/v2"
@@ -11,35 +13,80 @@ import (
 
 // GobotRFIDReader implements RFIDReader using gobot's MFRC522 driver
 type GobotRFIDReader struct {
-	adaptor    *raspi.Adaptor
-	driver     *spi.MFRC522Driver
-	robot      *gobot.Robot
-	lastCardID string
-	lastSeen   time.Time
+	adaptor  *raspi.Adaptor
+	driver   *spi.MFRC522Driver
+	robot    *gobot.Robot
+	picc     *iso14443a // Card activation over the driver's SPI connection
+	lastCard Card
+	lastSeen time.Time
+}
+
+func init() {
+	registerReader("gobot-mfrc522", readerKind{
+		summary: "MFRC522 on SPI through gobot, polling the chip instead of waiting on IRQ",
+		options: []string{"bus", "cs", "gain"},
+		open: func(opts readerOptions) (RFIDReader, error) {
+			cfg, err := mfrc522Options(opts, -1)
+			if err != nil {
+				return nil, err
+			}
+			// Check SPI devices exist before trying gobot
+			if spidevFiles, err := filepath.Glob("/dev/spidev*"); err != nil || len(spidevFiles) == 0 {
+				return nil, fmt.Errorf("no SPI devices found - run: make setup-spi")
+			}
+			// NOTE: gobot polls the MFRC522's internal interrupt registers via SPI
+			// It does NOT use GPIO IRQ pin - this is why it's more reliable!
+			fmt.Println("  Attempting gobot MFRC522 driver (SPI register polling mode)...")
+			gobotReader, err := NewGobotRFIDReader(cfg)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("✓ Gobot MFRC522 RFID reader initialized successfully!")
+			fmt.Println("  ✓ Using gobot library with SPI interrupt register polling")
+			fmt.Println("  ✓ No GPIO IRQ pin required - more reliable than periph.io!")
+			fmt.Println("  ✓ Hardware ready - tap your card on the reader to pay")
+			return gobotReader, nil
+		},
+		pins: func(opts readerOptions) (map[int]string, error) {
+			cfg, err := mfrc522Options(opts, -1)
+			if err != nil {
+				return nil, err
+			}
+			return cfg.spiPins()
+		},
+	})
 }
 
 // NewGobotRFIDReader creates a new RFID reader using gobot
-func NewGobotRFIDReader() (*GobotRFIDReader, error) {
+// gobot polls the chip's interrupt register over SPI, so cfg's rst and irq pins are not used
+func NewGobotRFIDReader(cfg mfrc522Config) (*GobotRFIDReader, error) {
 	// Create Raspberry Pi adaptor
 	adaptor := raspi.NewAdaptor()
-	
+
 	// Create MFRC522 driver with SPI
 	// Default SPI bus 0, chip select 0
-	driver := spi.NewMFRC522Driver(adaptor)
-	
+	var options []func(spi.Config)
+	if cfg.bus >= 0 {
+		options = append(options, spi.WithBusNumber(cfg.bus))
+	}
+	if cfg.chip >= 0 {
+		options = append(options, spi.WithChipNumber(cfg.chip))
+	}
+	driver := spi.NewMFRC522Driver(adaptor, options...)
+
 	// Create robot to manage lifecycle
 	robot := gobot.NewRobot("rfid",
 		[]gobot.Connection{adaptor},
 		[]gobot.Device{driver},
 	)
-	
+
 	// Start the robot (initializes hardware) with AutoRun=false so it returns immediately
 	// robot.Start(false) returns after initialization instead of blocking
 	// The robot.Start() will:
 	// 1. Start connections (adaptor)
 	// 2. Start devices (driver) - which calls driver.Start()
 	// 3. Driver.Start() calls GetSpiConnection() which needs the adaptor to be connected
-	// 
+	//
 	// With AutoRun=false, Start() returns immediately after init (doesn't wait for signals)
 	// Use panic recovery in case SPI access fails
 	var startErr error
@@ -52,16 +99,16 @@ func NewGobotRFIDReader() (*GobotRFIDReader, error) {
 		}()
 		startErr = robot.Start(false)
 	}()
-	
+
 	if startErr != nil {
 		robot.Stop()
 		return nil, fmt.Errorf("failed to start RFID reader: %w", startErr)
 	}
-	
+
 	// Give the driver a moment to fully initialize
 	// The afterStart callback sets up the connection wrapper
 	time.Sleep(300 * time.Millisecond)
-	
+
 	// Verify the driver is actually working by trying a simple operation
 	// This will catch any initialization issues early
 	// Use panic recovery to catch SPI connection issues
@@ -76,21 +123,55 @@ func NewGobotRFIDReader() (*GobotRFIDReader, error) {
 		// Try to check for card - this will trigger SPI connection setup
 		_ = driver.IsCardPresent()
 	}()
-	
+
 	if !initOK {
 		robot.Stop()
 		return nil, fmt.Errorf("SPI connection failed - gobot adaptor not properly initialized")
 	}
-	
+
+	// gobot keeps the anticollision cascade to itself, so run our own over the same SPI
+	// connection - the adaptor hands back the connection it already opened for the driver
+	conn, err := adaptor.GetSpiConnection(
+		driver.GetBusNumberOrDefault(adaptor.SpiDefaultBusNumber()),
+		driver.GetChipNumberOrDefault(adaptor.SpiDefaultChipNumber()),
+		driver.GetModeOrDefault(adaptor.SpiDefaultMode()),
+		driver.GetBitCountOrDefault(adaptor.SpiDefaultBitCount()),
+		driver.GetSpeedOrDefault(adaptor.SpiDefaultMaxSpeed()),
+	)
+	if err != nil {
+		robot.Stop()
+		return nil, fmt.Errorf("failed to get SPI connection: %w", err)
+	}
+
 	reader := &GobotRFIDReader{
 		adaptor: adaptor,
 		driver:  driver,
 		robot:   robot,
+		picc:    &iso14443a{bus: gobotBus{conn: conn}},
 	}
-	
+	if cfg.gain >= 0 {
+		if err := reader.picc.setAntennaGain(cfg.gain); err != nil {
+			fmt.Printf("  ⚠ Warning: Could not set antenna gain: %v\n", err)
+		}
+	}
+
 	return reader, nil
 }
 
+// gobotBus gives register access to the MFRC522 over a gobot SPI connection
+// The address byte is the register shifted left one bit, with the top bit set for reads
+type gobotBus struct {
+	conn spi.Connection
+}
+
+func (b gobotBus) readRegister(reg byte) (byte, error) {
+	return b.conn.ReadByteData(0x80 | reg<<1)
+}
+
+func (b gobotBus) writeRegister(reg, val byte) error {
+	return b.conn.WriteByteData(reg<<1, val)
+}
+
 // IsCardPresent checks if an RFID card is present
 // Uses gobot's SPI polling of interrupt registers (no GPIO IRQ needed)
 func (g *GobotRFIDReader) IsCardPresent() (bool, error) {
@@ -98,101 +179,88 @@ func (g *GobotRFIDReader) IsCardPresent() (bool, error) {
 	if g.driver == nil {
 		return false, fmt.Errorf("driver not initialized")
 	}
-	
+
 	// Try to read UID directly - this is more reliable than IsCardPresent()
 	// because IsCardPresent() might halt the card, making UID read fail
 	// Reading UID will detect the card and get its ID in one operation
-	uid, err := g.readUID()
+	card, err := g.selectCard()
 	if err != nil {
 		// No card present or read failed - not a real error
 		// Only log errors occasionally to avoid spam
 		if time.Since(g.lastSeen) > 5*time.Second {
-			fmt.Printf("DEBUG: readUID error (no card?): %v\n", err)
+			fmt.Printf("DEBUG: selectCard error (no card?): %v\n", err)
 			g.lastSeen = time.Now()
 		}
 		return false, nil
 	}
-	
-	if len(uid) > 0 {
-		// Card detected!
-		g.lastCardID = formatUID(uid)
-		g.lastSeen = time.Now()
-		fmt.Printf("✓✓✓ Card detected! UID: %s\n", g.lastCardID)
-		return true, nil
-	}
-	
-	return false, nil
+
+	// Card detected!
+	g.lastCard = card
+	g.lastSeen = time.Now()
+	fmt.Printf("✓✓✓ Card detected! %s\n", card)
+	return true, nil
 }
 
-// ReadCardID reads the unique ID from an RFID card
-func (g *GobotRFIDReader) ReadCardID() (string, error) {
-	// Return cached UID if recent
-	if time.Since(g.lastSeen) < 2*time.Second && g.lastCardID != "" {
-		return g.lastCardID, nil
+// ReadCard describes the card on the reader
+func (g *GobotRFIDReader) ReadCard() (Card, error) {
+	// Return cached card if recent
+	if time.Since(g.lastSeen) < 2*time.Second && len(g.lastCard.UID) > 0 {
+		return g.lastCard, nil
 	}
-	
-	// Read UID directly
-	uid, err := g.readUID()
+
+	card, err := g.selectCard()
 	if err != nil {
-		return "", fmt.Errorf("failed to read card: %w", err)
+		return Card{}, fmt.Errorf("failed to read card: %w", err)
 	}
-	
-	if len(uid) == 0 {
-		return "", fmt.Errorf("no card present")
-	}
-	
-	g.lastCardID = formatUID(uid)
+
+	g.lastCard = card
 	g.lastSeen = time.Now()
-	return g.lastCardID, nil
+	return card, nil
 }
 
-// readUID reads the card UID
-// NOTE: gobot's piccActivate() is unexported, so we can't access it directly
-// Workaround: Use IsCardPresent() to detect card, then generate a UID
-// This allows card detection to work, though the UID won't be the real card UID
-func (g *GobotRFIDReader) readUID() ([]byte, error) {
-	// Safety check
-	if g.driver == nil {
-		return nil, fmt.Errorf("driver is nil")
+// CardEvents implements CardEventReader
+// The gobot driver has no IRQ line, so new cards are polled for
+func (g *GobotRFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, g)
+}
+
+func (g *GobotRFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		card, err := g.selectCard()
+		if err == nil || !isNoCard(err) || time.Now().After(deadline) {
+			return card, err
+		}
+		time.Sleep(cardPollInterval)
 	}
-	if g.driver.MFRC522Common == nil {
-		return nil, fmt.Errorf("MFRC522Common is nil - driver not initialized")
+}
+
+func (g *GobotRFIDReader) stillPresent(uid []byte) bool {
+	if g.picc == nil {
+		return false
 	}
-	
-	// Check if card is present (this detects the card)
-	// IsCardPresent() returns error if no card is detected
-	err := g.driver.IsCardPresent()
+	return g.picc.cardSession(uid, func() error { return nil }) == nil
+}
+
+// selectCard selects the card in the field and describes it (UID of 4, 7 or 10 bytes)
+// The card is halted afterwards, so it isn't read again until it has left the field
+func (g *GobotRFIDReader) selectCard() (Card, error) {
+	// Safety check
+	if g.driver == nil || g.picc == nil {
+		return Card{}, fmt.Errorf("driver not initialized")
+	}
+
+	g.picc.mu.Lock()
+	defer g.picc.mu.Unlock()
+
+	id, err := g.picc.activate(piccReqA)
 	if err != nil {
-		// No card present - this is normal when no card is on reader
-		// Log occasionally for debugging
-		if time.Since(g.lastSeen) > 10*time.Second {
-			fmt.Printf("DEBUG: IsCardPresent() returned: %v (no card on reader)\n", err)
-			g.lastSeen = time.Now()
-		}
-		return nil, fmt.Errorf("no card present: %w", err)
-	}
-	
-	// Card detected! IsCardPresent() succeeded
-	fmt.Printf("DEBUG: IsCardPresent() succeeded - card detected!\n")
-	
-	// Card is detected! But IsCardPresent() halts the card, and we can't
-	// access piccActivate() to read the real UID (it's unexported).
-	// 
-	// Workaround: Generate a time-based UID for detection purposes.
-	// This allows the payment flow to work, though the UID shown won't
-	// be the actual card UID.
-	//
-	// TODO: Implement UID reading by replicating the anticollision sequence
-	// using the driver's connection directly, or fork gobot to export piccActivate()
-	
-	timeBasedUID := []byte{
-		byte(time.Now().Unix() & 0xFF),
-		byte((time.Now().Unix() >> 8) & 0xFF),
-		byte((time.Now().Unix() >> 16) & 0xFF),
-		byte((time.Now().Unix() >> 24) & 0xFF),
-	}
-	
-	return timeBasedUID, nil
+		return Card{}, fmt.Errorf("no card present: %w", err)
+	}
+	if err := g.picc.halt(); err != nil {
+		fmt.Printf("DEBUG: HLTA failed: %v\n", err)
+	}
+	return newCard(id), nil
 }
 
 // Close cleans up resources
@@ -202,4 +270,3 @@ func (g *GobotRFIDReader) Close() error {
 	}
 	return nil
 }
-
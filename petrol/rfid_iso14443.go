@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MFRC522 registers used to talk to ISO/IEC 14443 type A cards (datasheet section 9)
+const (
+	mfrcCommandReg    = 0x01
+	mfrcComIrqReg     = 0x04
+	mfrcErrorReg      = 0x06
+	mfrcStatus2Reg    = 0x08
+	mfrcFIFODataReg   = 0x09
+	mfrcFIFOLevelReg  = 0x0A
+	mfrcControlReg    = 0x0C
+	mfrcBitFramingReg = 0x0D
+	mfrcCollReg       = 0x0E
+	mfrcTxModeReg     = 0x12
+	mfrcRxModeReg     = 0x13
+	mfrcModWidthReg   = 0x24
+	mfrcRFCfgReg      = 0x26
+	mfrcTModeReg      = 0x2A
+	mfrcTPrescalerReg = 0x2B
+	mfrcTReloadRegH   = 0x2C
+	mfrcTReloadRegL   = 0x2D
+)
+
+// MFRC522 commands
+const (
+	mfrcCmdIdle       = 0x00
+	mfrcCmdTransceive = 0x0C
+	mfrcCmdMFAuthent  = 0x0E
+)
+
+// ISO/IEC 14443-3 type A commands
+const (
+	piccReqA          = 0x26 // Request idle cards (7-bit frame)
+	piccWupA          = 0x52 // Wake up idle and halted cards (7-bit frame)
+	piccHaltA         = 0x50 // Put the selected card to sleep
+	piccCascadeTag    = 0x88 // First UID byte when the UID continues at the next cascade level
+	piccSelectNVBFull = 0x70 // NVB for a SELECT with all 40 UID bits
+)
+
+// piccSelectCommands are the SELECT/ANTICOLLISION commands for cascade levels 1-3
+var piccSelectCommands = []byte{0x93, 0x95, 0x97}
+
+// transceiveTimeout bounds a single exchange with a card, in case the reader's own timer isn't running
+const transceiveTimeout = 50 * time.Millisecond
+
+var (
+	errNoCard    = errors.New("no card in the field")
+	errCollision = errors.New("bit collision")
+)
+
+// mfrc522Bus is raw register access to an MFRC522, whichever library owns the connection
+type mfrc522Bus interface {
+	readRegister(reg byte) (byte, error)
+	writeRegister(reg, val byte) error
+}
+
+// cardIdentity is what a card reports while it is being selected
+type cardIdentity struct {
+	UID  []byte  // 4, 7 or 10 bytes
+	ATQA [2]byte // Answer to request, least significant byte first
+	SAK  byte    // Select acknowledge of the last cascade level
+}
+
+// iso14443a runs the ISO/IEC 14443-3 type A activation sequence through an MFRC522
+// mu must be held for a whole conversation with a card, as the reader is shared
+type iso14443a struct {
+	mu  sync.Mutex
+	bus mfrc522Bus
+}
+
+// activate wakes a card with req (REQA or WUPA) and selects it through as many cascade levels as its UID needs
+func (c *iso14443a) activate(req byte) (cardIdentity, error) {
+	var card cardIdentity
+
+	// 106 kbit/s with no CRC in either direction - the CRC is added by hand where needed
+	if err := c.bus.writeRegister(mfrcTxModeReg, 0x00); err != nil {
+		return card, err
+	}
+	if err := c.bus.writeRegister(mfrcRxModeReg, 0x00); err != nil {
+		return card, err
+	}
+
+	atqa, err := c.requestA(req)
+	if err != nil {
+		return card, err
+	}
+	card.ATQA = [2]byte{atqa[0], atqa[1]}
+
+	for i, sel := range piccSelectCommands {
+		part, sak, err := c.selectLevel(sel)
+		if err != nil {
+			return card, fmt.Errorf("cascade level %d: %w", i+1, err)
+		}
+		card.SAK = sak
+
+		// SAK bit 3 (0x04) means the UID isn't complete yet; the first byte of this level is then the cascade tag
+		if sak&0x04 != 0 && part[0] == piccCascadeTag {
+			card.UID = append(card.UID, part[1:4]...)
+			continue
+		}
+		card.UID = append(card.UID, part[:4]...)
+		return card, nil
+	}
+	return card, fmt.Errorf("UID longer than three cascade levels")
+}
+
+// cardSession wakes and selects the card with uid again and runs fn, halting the card afterwards
+// The card has normally been halted since it was read, so it is woken with WUPA rather than REQA
+func (c *iso14443a) cardSession(uid []byte, fn func() error) error {
+	if len(uid) < 4 {
+		return fmt.Errorf("no card UID")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, err := c.activate(piccWupA)
+	if err != nil {
+		return fmt.Errorf("card %s not on the reader: %w", formatUID(uid), err)
+	}
+	defer c.halt()
+	if !bytes.Equal(id.UID, uid) {
+		return fmt.Errorf("card %s is on the reader, not %s", formatUID(id.UID), formatUID(uid))
+	}
+	return fn()
+}
+
+// requestA sends REQA or WUPA and returns the ATQA
+func (c *iso14443a) requestA(req byte) ([]byte, error) {
+	// Only valid bits after a collision should be kept in the FIFO
+	if err := c.clearBits(mfrcCollReg, 0x80); err != nil {
+		return nil, err
+	}
+	atqa, validBits, err := c.transceive([]byte{req}, 7, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(atqa) != 2 || validBits != 0 {
+		return nil, fmt.Errorf("bad ATQA % X", atqa)
+	}
+	return atqa, nil
+}
+
+// selectLevel resolves collisions and selects one cascade level
+// Returns the level's four UID bytes and the card's SAK
+func (c *iso14443a) selectLevel(sel byte) ([]byte, byte, error) {
+	// uid holds the four UID bytes and the BCC; knownBits counts how many bits of it are settled
+	uid := make([]byte, 5)
+	knownBits := 0
+
+	for knownBits < 32 {
+		whole, partial := knownBits/8, knownBits%8
+		frame := append([]byte{sel, byte((2+whole)<<4 | partial)}, uid[:whole]...)
+		if partial > 0 {
+			frame = append(frame, uid[whole])
+		}
+
+		// The answer starts where our last (possibly partial) byte left off
+		resp, _, err := c.transceive(frame, byte(partial), byte(partial))
+		if errors.Is(err, errCollision) {
+			coll, err := c.bus.readRegister(mfrcCollReg)
+			if err != nil {
+				return nil, 0, err
+			}
+			if coll&0x20 != 0 {
+				return nil, 0, fmt.Errorf("collision position unknown")
+			}
+			pos := int(coll & 0x1F) // 1-32, 0 means 32
+			if pos == 0 {
+				pos = 32
+			}
+			if pos <= knownBits {
+				return nil, 0, fmt.Errorf("collision did not advance")
+			}
+			// Keep what was received up to the collision, then choose the card with a 1 there
+			merge(uid, whole, partial, resp)
+			uid[(pos-1)/8] |= 1 << ((pos - 1) % 8)
+			knownBits = pos
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		merge(uid, whole, partial, resp)
+		knownBits = 32
+	}
+
+	if uid[0]^uid[1]^uid[2]^uid[3] != uid[4] {
+		return nil, 0, fmt.Errorf("BCC mismatch in % X", uid)
+	}
+
+	// SELECT with the full UID; the card answers with its SAK
+	frame := append([]byte{sel, piccSelectNVBFull}, uid...)
+	frame = append(frame, crcA(frame)...)
+	resp, validBits, err := c.transceive(frame, 0, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) != 3 || validBits != 0 {
+		return nil, 0, fmt.Errorf("bad SAK % X", resp)
+	}
+	if crc := crcA(resp[:1]); resp[1] != crc[0] || resp[2] != crc[1] {
+		return nil, 0, fmt.Errorf("SAK CRC mismatch")
+	}
+	return uid[:4], resp[0], nil
+}
+
+// merge copies an anticollision answer into uid, which already holds whole bytes and partial bits
+func merge(uid []byte, whole, partial int, resp []byte) {
+	for i, b := range resp {
+		at := whole + i
+		if at >= len(uid) {
+			return
+		}
+		if i == 0 && partial > 0 {
+			// The first byte only carries the bits after the ones we sent
+			mask := byte(1<<partial) - 1
+			b = uid[at]&mask | b&^mask
+		}
+		uid[at] = b
+	}
+}
+
+// halt puts the selected card to sleep so it stops answering REQA until it leaves the field
+func (c *iso14443a) halt() error {
+	frame := []byte{piccHaltA, 0x00}
+	frame = append(frame, crcA(frame)...)
+	// A halted card doesn't answer, so a timeout is success
+	if _, _, err := c.transceive(frame, 0, 0); err != nil && !errors.Is(err, errNoCard) {
+		return err
+	}
+	return nil
+}
+
+// transceive sends data to the card and returns its answer and how many bits of the last byte are valid (0 = all 8)
+// txLastBits is the number of bits of the last byte to send (0 = all 8); rxAlign is where the first received bit goes
+func (c *iso14443a) transceive(data []byte, txLastBits, rxAlign byte) ([]byte, int, error) {
+	return c.transceiveWithin(data, txLastBits, rxAlign, transceiveTimeout)
+}
+
+// transceiveWithin is transceive with a longer wait for the answer, for cards that take their time
+func (c *iso14443a) transceiveWithin(data []byte, txLastBits, rxAlign byte, timeout time.Duration) ([]byte, int, error) {
+	if err := c.loadFIFO(data); err != nil {
+		return nil, 0, err
+	}
+	framing := rxAlign<<4 | txLastBits
+	if err := c.bus.writeRegister(mfrcBitFramingReg, framing); err != nil {
+		return nil, 0, err
+	}
+	if err := c.bus.writeRegister(mfrcCommandReg, mfrcCmdTransceive); err != nil {
+		return nil, 0, err
+	}
+	if err := c.bus.writeRegister(mfrcBitFramingReg, framing|0x80); err != nil { // StartSend
+		return nil, 0, err
+	}
+
+	// Wait for the answer (RxIRq or IdleIRq), or the reader's timer (TimerIRq)
+	deadline := time.Now().Add(timeout)
+	for {
+		irq, err := c.bus.readRegister(mfrcComIrqReg)
+		if err != nil {
+			return nil, 0, err
+		}
+		if irq&0x30 != 0 {
+			break
+		}
+		if irq&0x01 != 0 || time.Now().After(deadline) {
+			c.bus.writeRegister(mfrcBitFramingReg, framing)
+			return nil, 0, errNoCard
+		}
+	}
+	if err := c.bus.writeRegister(mfrcBitFramingReg, framing); err != nil {
+		return nil, 0, err
+	}
+
+	// BufferOvfl, ParityErr or ProtocolErr spoil the answer; CollErr is reported separately
+	errReg, err := c.bus.readRegister(mfrcErrorReg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if errReg&0x13 != 0 {
+		return nil, 0, fmt.Errorf("reader error 0x%02X", errReg)
+	}
+
+	n, err := c.bus.readRegister(mfrcFIFOLevelReg)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp := make([]byte, n&0x7F)
+	for i := range resp {
+		if resp[i], err = c.bus.readRegister(mfrcFIFODataReg); err != nil {
+			return nil, 0, err
+		}
+	}
+	control, err := c.bus.readRegister(mfrcControlReg)
+	if err != nil {
+		return nil, 0, err
+	}
+	validBits := int(control & 0x07)
+
+	if errReg&0x08 != 0 {
+		return resp, validBits, errCollision
+	}
+	return resp, validBits, nil
+}
+
+// loadFIFO stops whatever the reader is doing, clears its interrupts and fills the FIFO with data
+func (c *iso14443a) loadFIFO(data []byte) error {
+	steps := []struct{ reg, val byte }{
+		{mfrcCommandReg, mfrcCmdIdle}, // Stop anything in progress
+		{mfrcComIrqReg, 0x7F},         // Clear all interrupt flags
+		{mfrcFIFOLevelReg, 0x80},      // Flush the FIFO
+	}
+	for _, s := range steps {
+		if err := c.bus.writeRegister(s.reg, s.val); err != nil {
+			return err
+		}
+	}
+	for _, b := range data {
+		if err := c.bus.writeRegister(mfrcFIFODataReg, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearBits clears mask in a register
+func (c *iso14443a) clearBits(reg, mask byte) error {
+	val, err := c.bus.readRegister(reg)
+	if err != nil {
+		return err
+	}
+	return c.bus.writeRegister(reg, val&^mask)
+}
+
+// setAntennaGain sets the receiver gain, 0 (18 dB) to 7 (48 dB)
+func (c *iso14443a) setAntennaGain(gain int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bus.writeRegister(mfrcRFCfgReg, byte(gain&0x07)<<4)
+}
+
+// crcA computes the ISO/IEC 14443-3 CRC_A of data, least significant byte first
+func crcA(data []byte) []byte {
+	crc := uint16(0x6363)
+	for _, b := range data {
+		b ^= byte(crc)
+		b ^= b << 4
+		crc = crc>>8 ^ uint16(b)<<8 ^ uint16(b)<<3 ^ uint16(b)>>4
+	}
+	return []byte{byte(crc), byte(crc >> 8)}
+}
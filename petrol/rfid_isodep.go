@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ISO/IEC 14443-4 (ISO-DEP) activation and block protocol
+const (
+	piccRATS = 0xE0 // Request for answer to select
+	piccPPS  = 0xD0 // Protocol and parameter selection (CID 0)
+
+	isoDepIBlock   = 0x02 // I-block PCB; bit 0 is the block number
+	isoDepChaining = 0x10 // I-block: more of the same message follows
+	isoDepRAck     = 0xA2 // R(ACK) PCB; bit 0 is the block number
+	isoDepRNak     = 0xB2 // R(NAK) PCB; bit 0 is the block number
+	isoDepDeselect = 0xC2 // S(DESELECT)
+	isoDepWTX      = 0xF2 // S(WTX) - the card needs more time
+
+	// The MFRC522's FIFO holds 64 bytes, so that is the largest frame we can take (FSDI 5)
+	isoDepFSDI = 5
+	isoDepFSD  = 64
+
+	// How many times a lost or garbled block is asked for again
+	isoDepRetries = 2
+)
+
+// isoDepFrameSizes maps FSCI/FSDI to a frame size in bytes (ISO/IEC 14443-4 table 1)
+var isoDepFrameSizes = []int{16, 24, 32, 40, 48, 64, 96, 128, 256}
+
+// isoDepMaxBitRate is the fastest bit rate, in kbit/s, to ask ISO-DEP cards for with PPS
+// 106 keeps to the rate every card starts at; the MFRC522 also manages 212, 424 and 848
+var isoDepMaxBitRate = 106
+
+var errProtocol = errors.New("ISO-DEP protocol error")
+
+// APDUTransceiver exchanges command and response APDUs with an ISO-DEP card
+type APDUTransceiver interface {
+	// Transceive sends a command APDU and returns the response APDU, status word included
+	Transceive(apdu []byte) ([]byte, error)
+}
+
+// APDUReader is an RFIDReader that can also talk to ISO-DEP cards - bank cards, phones and watches
+// The card must still be on the reader; it is woken up again if it was halted after being read
+type APDUReader interface {
+	RFIDReader
+	// APDUSession activates the card at ISO/IEC 14443-4 and runs fn, then deselects the card
+	APDUSession(card Card, fn func(t APDUTransceiver) error) error
+}
+
+// APDUSession implements APDUReader
+func (r *MFRC522RFIDReader) APDUSession(card Card, fn func(t APDUTransceiver) error) error {
+	return r.picc.apduSession(card.UID, fn)
+}
+
+// APDUSession implements APDUReader
+func (g *GobotRFIDReader) APDUSession(card Card, fn func(t APDUTransceiver) error) error {
+	if g.picc == nil {
+		return fmt.Errorf("driver not initialized")
+	}
+	return g.picc.apduSession(card.UID, fn)
+}
+
+// isoDep is an ISO-DEP session with a card that has answered RATS
+type isoDep struct {
+	picc  *iso14443a
+	fsc   int           // Largest frame the card takes, PCB and CRC included
+	fwt   time.Duration // Frame waiting time: how long the card may take to answer a block
+	block byte          // Current block number
+}
+
+// apduSession selects the card with uid, activates ISO-DEP and runs fn
+// The reader's timer and bit rate are put back as they were afterwards
+func (c *iso14443a) apduSession(uid []byte, fn func(t APDUTransceiver) error) error {
+	return c.cardSession(uid, func() error {
+		restore, err := c.saveTimer()
+		if err != nil {
+			return err
+		}
+		defer restore()
+		defer c.setBitRate(0)
+
+		d, err := c.openISODEP()
+		if err != nil {
+			return err
+		}
+		defer d.deselect()
+		return fn(d)
+	})
+}
+
+// openISODEP sends RATS to the selected card, then PPS if both sides can go faster
+func (c *iso14443a) openISODEP() (*isoDep, error) {
+	frame := []byte{piccRATS, isoDepFSDI << 4}
+	frame = append(frame, crcA(frame)...)
+	resp, _, err := c.transceive(frame, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("RATS: %w", err)
+	}
+	ats, err := checkCRC(resp)
+	if err != nil || len(ats) < 1 || int(ats[0]) != len(ats) {
+		return nil, fmt.Errorf("RATS: bad ATS % X", resp)
+	}
+
+	// Defaults for anything the ATS leaves out: FSCI 2, FWI 4, SFGI 0, 106 kbit/s only
+	d := &isoDep{picc: c, fsc: 32, fwt: isoDepTime(4)}
+	var ta byte
+	var sfgt time.Duration
+	if len(ats) > 1 {
+		t0 := ats[1]
+		if fsci := int(t0 & 0x0F); fsci < len(isoDepFrameSizes) {
+			d.fsc = isoDepFrameSizes[fsci]
+		} else {
+			d.fsc = 256
+		}
+		i := 2
+		if t0&0x10 != 0 && i < len(ats) { // TA: bit rates
+			ta = ats[i]
+			i++
+		}
+		if t0&0x20 != 0 && i < len(ats) { // TB: FWI and SFGI
+			if fwi := int(ats[i] >> 4); fwi < 15 {
+				d.fwt = isoDepTime(fwi)
+			}
+			if sfgi := int(ats[i] & 0x0F); sfgi > 0 && sfgi < 15 {
+				sfgt = isoDepTime(sfgi)
+			}
+		}
+	}
+	if d.fsc > isoDepFSD {
+		d.fsc = isoDepFSD
+	}
+	time.Sleep(sfgt) // The card may ask for a rest before the next frame
+
+	if err := c.setTimer(d.fwt); err != nil {
+		return nil, err
+	}
+	if err := d.negotiateBitRate(ta); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// isoDepTime converts a frame or guard time integer (FWI, SFGI) to a duration: (256 × 16 / fc) × 2^i
+func isoDepTime(i int) time.Duration {
+	return time.Duration(302064*(1<<i)) * time.Nanosecond
+}
+
+// negotiateBitRate sends PPS for the fastest rate up to isoDepMaxBitRate that the card takes both ways
+// TA bits 0-2 are the rates the card can receive at (212, 424, 848), bits 4-6 the rates it can send at
+func (d *isoDep) negotiateBitRate(ta byte) error {
+	for dsi := 3; dsi >= 1; dsi-- {
+		rate := 106 << dsi
+		bit := byte(1) << (dsi - 1)
+		if rate > isoDepMaxBitRate || ta&bit == 0 || ta&(bit<<4) == 0 {
+			continue
+		}
+		frame := []byte{piccPPS, 0x11, byte(dsi<<2 | dsi)}
+		frame = append(frame, crcA(frame)...)
+		resp, _, err := d.picc.transceiveWithin(frame, 0, 0, d.fwt+transceiveTimeout)
+		if err != nil {
+			return fmt.Errorf("PPS: %w", err)
+		}
+		if body, err := checkCRC(resp); err != nil || len(body) != 1 || body[0] != piccPPS {
+			return fmt.Errorf("PPS: bad answer % X", resp)
+		}
+		return d.picc.setBitRate(byte(dsi))
+	}
+	return nil
+}
+
+// Transceive implements APDUTransceiver, chaining the command and the response over as many blocks as they need
+func (d *isoDep) Transceive(apdu []byte) ([]byte, error) {
+	// Send the command, chaining if it doesn't fit one frame (PCB and CRC take 3 bytes)
+	maxInf := d.fsc - 3
+	var reply []byte
+	for sent := 0; ; {
+		n := len(apdu) - sent
+		if n > maxInf {
+			n = maxInf
+		}
+		last := sent+n == len(apdu)
+		pcb := isoDepIBlock | d.block
+		if !last {
+			pcb |= isoDepChaining
+		}
+		var err error
+		reply, err = d.exchange(append([]byte{pcb}, apdu[sent:sent+n]...))
+		if err != nil {
+			return nil, err
+		}
+		sent += n
+		if last {
+			break
+		}
+		// The card acknowledges each chained block before taking the next
+		if reply[0]&0xE6 != 0xA2 || reply[0]&0x10 != 0 || reply[0]&0x01 != d.block {
+			return nil, fmt.Errorf("%w: expected R(ACK), got % X", errProtocol, reply)
+		}
+		d.block ^= 1
+	}
+
+	// Collect the response, acknowledging each chained block
+	var resp []byte
+	for {
+		if reply[0]&0xE2 != 0x02 || reply[0]&0x01 != d.block {
+			return nil, fmt.Errorf("%w: expected I-block, got % X", errProtocol, reply)
+		}
+		d.block ^= 1
+		resp = append(resp, reply[1:]...)
+		if reply[0]&isoDepChaining == 0 {
+			return resp, nil
+		}
+		var err error
+		if reply, err = d.exchange([]byte{isoDepRAck | d.block}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// exchange sends one block and returns the card's answer
+// It answers waiting time extensions, and asks again when an answer is lost or garbled
+func (d *isoDep) exchange(block []byte) ([]byte, error) {
+	frame, timeout := block, d.fwt
+	for attempt := 0; ; {
+		reply, err := d.send(frame, timeout)
+		timeout = d.fwt
+		if err != nil {
+			if attempt++; attempt > isoDepRetries {
+				return nil, err
+			}
+			// R(NAK) makes the card send its last block again, or acknowledge if it missed ours;
+			// while the card is chaining its answer, the R(ACK) is sent again instead
+			if block[0]&0xE6 != 0xA2 {
+				frame = []byte{isoDepRNak | d.block}
+			} else {
+				frame = block
+			}
+			continue
+		}
+
+		switch pcb := reply[0]; {
+		case pcb&0xC7 == 0xC2 && pcb&0x30 == 0x30:
+			// S(WTX): the card wants up to 59 × FWT for this answer
+			if len(reply) < 2 {
+				return nil, fmt.Errorf("%w: short S(WTX)", errProtocol)
+			}
+			wtxm := reply[1] & 0x3F
+			if wtxm == 0 || wtxm > 59 {
+				return nil, fmt.Errorf("%w: bad WTXM %d", errProtocol, wtxm)
+			}
+			frame, timeout = []byte{isoDepWTX, wtxm}, d.fwt*time.Duration(wtxm)
+			continue
+		case pcb&0xE6 == 0xA2 && pcb&0x10 == 0 && pcb&0x01 != d.block:
+			// R(ACK) for the previous block: ours never arrived, so send it again
+			if attempt++; attempt > isoDepRetries {
+				return nil, fmt.Errorf("%w: card keeps missing block % X", errProtocol, block)
+			}
+			frame = block
+			continue
+		}
+		return reply, nil
+	}
+}
+
+// send transmits a block with its CRC and returns the card's block without the CRC
+func (d *isoDep) send(frame []byte, timeout time.Duration) ([]byte, error) {
+	if err := d.picc.setTimer(timeout); err != nil {
+		return nil, err
+	}
+	frame = append(append([]byte{}, frame...), crcA(frame)...)
+	resp, _, err := d.picc.transceiveWithin(frame, 0, 0, timeout+transceiveTimeout)
+	if err != nil {
+		return nil, err
+	}
+	block, err := checkCRC(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(block) == 0 {
+		return nil, fmt.Errorf("%w: empty block", errProtocol)
+	}
+	return block, nil
+}
+
+// deselect sends S(DESELECT), after which the card goes back to waiting for WUPA
+func (d *isoDep) deselect() {
+	if _, err := d.send([]byte{isoDepDeselect}, d.fwt); err != nil {
+		fmt.Printf("DEBUG: DESELECT failed: %v\n", err)
+	}
+}
+
+// checkCRC checks and strips the CRC_A at the end of a frame
+func checkCRC(frame []byte) ([]byte, error) {
+	if len(frame) < 2 {
+		return nil, fmt.Errorf("frame too short for a CRC: % X", frame)
+	}
+	body := frame[:len(frame)-2]
+	if crc := crcA(body); frame[len(frame)-2] != crc[0] || frame[len(frame)-1] != crc[1] {
+		return nil, fmt.Errorf("CRC mismatch in % X", frame)
+	}
+	return body, nil
+}
+
+// setBitRate sets the reader to 106 × 2^dsi kbit/s both ways, with the modulation width to match
+func (c *iso14443a) setBitRate(dsi byte) error {
+	modWidth := []byte{0x26, 0x15, 0x0A, 0x05}[dsi&0x03]
+	for _, w := range []struct{ reg, val byte }{
+		{mfrcTxModeReg, dsi << 4},
+		{mfrcRxModeReg, dsi << 4},
+		{mfrcModWidthReg, modWidth},
+	} {
+		if err := c.bus.writeRegister(w.reg, w.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTimer starts the reader's timer automatically at the end of each transmission, running out after d
+// The drivers set it to tens of milliseconds, which is too short for most ISO-DEP cards
+func (c *iso14443a) setTimer(d time.Duration) error {
+	// 13.56 MHz / (2 × prescaler + 1) per tick: about 25 µs at 0xA9, or 604 µs at 0xFFF for long waits
+	prescaler := 0xA9
+	if d > 1500*time.Millisecond {
+		prescaler = 0xFFF
+	}
+	tick := time.Duration(2*prescaler+1) * time.Second / 13560000
+	reload := int64(d/tick) + 1
+	if reload > 0xFFFF {
+		reload = 0xFFFF
+	}
+	for _, w := range []struct{ reg, val byte }{
+		{mfrcTModeReg, 0x80 | byte(prescaler>>8)}, // TAuto
+		{mfrcTPrescalerReg, byte(prescaler)},
+		{mfrcTReloadRegH, byte(reload >> 8)},
+		{mfrcTReloadRegL, byte(reload)},
+	} {
+		if err := c.bus.writeRegister(w.reg, w.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveTimer returns a function that puts the reader's timer back as the driver set it
+func (c *iso14443a) saveTimer() (func(), error) {
+	regs := []byte{mfrcTModeReg, mfrcTPrescalerReg, mfrcTReloadRegH, mfrcTReloadRegL}
+	saved := make([]byte, len(regs))
+	for i, reg := range regs {
+		val, err := c.bus.readRegister(reg)
+		if err != nil {
+			return nil, err
+		}
+		saved[i] = val
+	}
+	return func() {
+		for i, reg := range regs {
+			c.bus.writeRegister(reg, saved[i])
+		}
+	}, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"petrol-pump/ndef"
+)
+
+// NFC Forum Type 2 tags (MIFARE Ultralight, NTAG21x) are read and written in 4-byte pages
+const (
+	type2Write    = 0xA2 // WRITE one page; READ is the same command as for MIFARE Classic
+	type2PageSize = 4
+	type2CCPage   = 3    // Capability container
+	type2DataPage = 4    // First page of the data area
+	type2Magic    = 0xE1 // First byte of an NDEF-formatted capability container
+)
+
+// TLV blocks in a Type 2 tag's data area
+const (
+	tlvNull       = 0x00
+	tlvNDEF       = 0x03
+	tlvTerminator = 0xFE
+)
+
+var errNotNDEF = errors.New("tag is not NDEF formatted")
+
+// NDEFReader is an RFIDReader that can also read and write NDEF messages on Ultralight and NTAG tags
+// The tag must still be on the reader; it is woken up again if it was halted after being read
+type NDEFReader interface {
+	RFIDReader
+	// ReadNDEF returns the NDEF message on a tag (empty if it has none)
+	ReadNDEF(card Card) (ndef.Message, error)
+	// WriteNDEF replaces the NDEF message on a tag
+	WriteNDEF(card Card, msg ndef.Message) error
+}
+
+// ReadNDEF implements NDEFReader
+func (r *MFRC522RFIDReader) ReadNDEF(card Card) (ndef.Message, error) {
+	return r.picc.readNDEF(card.UID)
+}
+
+// WriteNDEF implements NDEFReader
+func (r *MFRC522RFIDReader) WriteNDEF(card Card, msg ndef.Message) error {
+	return r.picc.writeNDEF(card.UID, msg)
+}
+
+// ReadNDEF implements NDEFReader
+func (g *GobotRFIDReader) ReadNDEF(card Card) (ndef.Message, error) {
+	if g.picc == nil {
+		return nil, fmt.Errorf("driver not initialized")
+	}
+	return g.picc.readNDEF(card.UID)
+}
+
+// WriteNDEF implements NDEFReader
+func (g *GobotRFIDReader) WriteNDEF(card Card, msg ndef.Message) error {
+	if g.picc == nil {
+		return fmt.Errorf("driver not initialized")
+	}
+	return g.picc.writeNDEF(card.UID, msg)
+}
+
+// type2Area reads a Type 2 tag's data area as far as it is needed
+type type2Area struct {
+	picc *iso14443a
+	size int    // Bytes in the data area, from the capability container
+	data []byte // What has been read so far, from the start of the data area
+	ro   bool   // The capability container says the tag is read-only
+}
+
+// openType2 reads the capability container of the selected tag
+func (c *iso14443a) openType2() (*type2Area, error) {
+	block, err := c.readBlock(type2CCPage)
+	if err != nil {
+		return nil, err
+	}
+	// The READ answer starts with the capability container, followed by the first data pages
+	cc := block[:type2PageSize]
+	if cc[0] != type2Magic {
+		return nil, fmt.Errorf("%w (capability container % X)", errNotNDEF, cc)
+	}
+	area := &type2Area{picc: c, size: int(cc[2]) * 8, ro: cc[3]&0x0F != 0}
+	area.data = append(area.data, block[type2PageSize:]...)
+	return area, nil
+}
+
+// need reads on until the first n bytes of the data area are in a.data
+func (a *type2Area) need(n int) error {
+	if n > a.size {
+		return fmt.Errorf("TLV runs past the end of the %d-byte data area", a.size)
+	}
+	for len(a.data) < n {
+		page := type2DataPage + len(a.data)/type2PageSize
+		block, err := a.picc.readBlock(byte(page))
+		if err != nil {
+			return err
+		}
+		a.data = append(a.data, block...)
+	}
+	return nil
+}
+
+// findNDEF walks the TLVs to the NDEF message TLV
+// Returns its offset in the data area, the length of its header and the message length
+// A tag with no NDEF TLV before the terminator gives found == false
+func (a *type2Area) findNDEF() (offset, header, length int, found bool, err error) {
+	for offset < a.size {
+		if err := a.need(offset + 1); err != nil {
+			return 0, 0, 0, false, err
+		}
+		switch a.data[offset] {
+		case tlvNull:
+			offset++
+			continue
+		case tlvTerminator:
+			return offset, 0, 0, false, nil
+		}
+
+		if err := a.need(offset + 2); err != nil {
+			return 0, 0, 0, false, err
+		}
+		header, length = 2, int(a.data[offset+1])
+		if length == 0xFF {
+			// Three-byte length form
+			if err := a.need(offset + 4); err != nil {
+				return 0, 0, 0, false, err
+			}
+			header, length = 4, int(binary.BigEndian.Uint16(a.data[offset+2:]))
+		}
+		if a.data[offset] == tlvNDEF {
+			return offset, header, length, true, nil
+		}
+		offset += header + length
+	}
+	if offset > a.size {
+		return 0, 0, 0, false, fmt.Errorf("TLV runs past the end of the %d-byte data area", a.size)
+	}
+	// The TLVs fill the tag, and the last ones were skipped without being read
+	if err := a.need(offset); err != nil {
+		return 0, 0, 0, false, err
+	}
+	return offset, 0, 0, false, nil
+}
+
+// readNDEF reads the NDEF message from a Type 2 tag
+func (c *iso14443a) readNDEF(uid []byte) (ndef.Message, error) {
+	var msg ndef.Message
+	err := c.cardSession(uid, func() error {
+		area, err := c.openType2()
+		if err != nil {
+			return err
+		}
+		offset, header, length, found, err := area.findNDEF()
+		if err != nil || !found || length == 0 {
+			return err
+		}
+		start := offset + header
+		if err := area.need(start + length); err != nil {
+			return err
+		}
+		msg, err = ndef.Parse(area.data[start : start+length])
+		return err
+	})
+	return msg, err
+}
+
+// writeNDEF replaces the NDEF message on a Type 2 tag, keeping any TLVs in front of it
+// The message length is written as zero first and only set once the message is in place,
+// so a tag pulled away half way through reads as empty rather than corrupt
+func (c *iso14443a) writeNDEF(uid []byte, msg ndef.Message) error {
+	encoded, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	return c.cardSession(uid, func() error {
+		area, err := c.openType2()
+		if err != nil {
+			return err
+		}
+		if area.ro {
+			return fmt.Errorf("tag is read-only")
+		}
+		offset, _, _, _, err := area.findNDEF()
+		if err != nil {
+			return err
+		}
+
+		// The TLVs in front, then the NDEF TLV and a terminator
+		header := []byte{tlvNDEF, byte(len(encoded))}
+		if len(encoded) >= 0xFF {
+			header = binary.BigEndian.AppendUint16([]byte{tlvNDEF, 0xFF}, uint16(len(encoded)))
+		}
+		data := append(append([]byte{}, area.data[:offset]...), header...)
+		data = append(data, encoded...)
+		data = append(data, tlvTerminator)
+		if len(data) > area.size {
+			return fmt.Errorf("message needs %d bytes, tag holds %d", len(data), area.size)
+		}
+		for len(data)%type2PageSize != 0 {
+			data = append(data, 0)
+		}
+
+		// Pages holding the length are written twice: empty first, then for real
+		blank := append([]byte{}, data...)
+		for i := offset + 1; i < offset+len(header); i++ {
+			blank[i] = 0
+		}
+		if len(header) == 4 {
+			blank[offset+1] = 0xFF
+		}
+		first, last := offset/type2PageSize, (offset+len(header)-1)/type2PageSize
+		for page := first; page <= last; page++ {
+			if err := c.writePage(page, blank); err != nil {
+				return err
+			}
+		}
+		for page := 0; page < len(data)/type2PageSize; page++ {
+			if page < first || page > last {
+				if err := c.writePage(page, data); err != nil {
+					return err
+				}
+			}
+		}
+		for page := first; page <= last; page++ {
+			if err := c.writePage(page, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writePage writes page n of data (counted from the start of the data area) to the tag
+func (c *iso14443a) writePage(n int, data []byte) error {
+	page := type2DataPage + n
+	frame := append([]byte{type2Write, byte(page)}, data[n*type2PageSize:(n+1)*type2PageSize]...)
+	if err := c.classicCommand(frame); err != nil {
+		return fmt.Errorf("page %d: %w", page, err)
+	}
+	return nil
+}
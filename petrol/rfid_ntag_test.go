@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"petrol-pump/ndef"
+)
+
+// fakeNTAG is an MFRC522 with an NTAG in its field, answering at register level
+type fakeNTAG struct {
+	uid    []byte // 7 bytes
+	memory []byte // Every page, from page 0
+
+	fifo      []byte // Written by the driver, then the tag's answer
+	irq       byte
+	validBits byte
+	framing   byte
+}
+
+// newFakeNTAG makes a tag whose data area (from page 4) holds data and is size bytes long
+func newFakeNTAG(data []byte, size int) *fakeNTAG {
+	uid := []byte{0x04, 0xA2, 0x3B, 0x11, 0x22, 0x33, 0x44}
+	memory := make([]byte, 16+size)
+	copy(memory, uid[:3])
+	copy(memory[4:], uid[3:])
+	copy(memory[12:], []byte{type2Magic, 0x10, byte(size / 8), 0x00})
+	copy(memory[16:], data)
+	return &fakeNTAG{uid: uid, memory: memory}
+}
+
+func (f *fakeNTAG) readRegister(reg byte) (byte, error) {
+	switch reg {
+	case mfrcComIrqReg:
+		return f.irq, nil
+	case mfrcFIFOLevelReg:
+		return byte(len(f.fifo)), nil
+	case mfrcFIFODataReg:
+		b := f.fifo[0]
+		f.fifo = f.fifo[1:]
+		return b, nil
+	case mfrcControlReg:
+		return f.validBits, nil
+	}
+	return 0, nil
+}
+
+func (f *fakeNTAG) writeRegister(reg, val byte) error {
+	switch reg {
+	case mfrcComIrqReg:
+		f.irq = 0
+	case mfrcFIFOLevelReg:
+		f.fifo = nil
+	case mfrcFIFODataReg:
+		f.fifo = append(f.fifo, val)
+	case mfrcBitFramingReg:
+		if val&0x80 != 0 {
+			f.answer(f.fifo, val&0x07)
+		}
+	}
+	return nil
+}
+
+// answer replies to frame as the tag would: the answer goes in the FIFO, or the timer runs out
+func (f *fakeNTAG) answer(frame []byte, lastBits byte) {
+	f.fifo, f.validBits, f.irq = nil, 0, 0x30
+	reply := func(data []byte) { f.fifo = append(data, crcA(data)...) }
+	level := func(sel byte) []byte {
+		if sel == piccSelectCommands[0] {
+			return []byte{piccCascadeTag, f.uid[0], f.uid[1], f.uid[2]}
+		}
+		return f.uid[3:7]
+	}
+	switch {
+	case lastBits == 7 && (frame[0] == piccWupA || frame[0] == piccReqA):
+		f.fifo = []byte{0x44, 0x00}
+	case len(frame) == 2 && frame[1] == 0x20:
+		part := level(frame[0])
+		f.fifo = append(part, part[0]^part[1]^part[2]^part[3])
+	case len(frame) == 9 && frame[1] == piccSelectNVBFull:
+		if frame[0] == piccSelectCommands[0] {
+			reply([]byte{0x04})
+		} else {
+			reply([]byte{0x00})
+		}
+	case frame[0] == classicRead:
+		data := make([]byte, 16)
+		copy(data, f.memory[min(int(frame[1])*type2PageSize, len(f.memory)):])
+		reply(data)
+	case frame[0] == type2Write:
+		copy(f.memory[int(frame[1])*type2PageSize:], frame[2:2+type2PageSize])
+		f.fifo, f.validBits = []byte{classicAck}, 4
+	default:
+		// HALT, or something the tag doesn't understand: no answer
+		f.irq = 0x01
+	}
+}
+
+func TestType2FindNDEF(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           []byte
+		offset, header int
+		length         int
+		found, wantErr bool
+	}{
+		{"NDEF first", []byte{0x03, 0x05, 0xD1, 0x01, 0x01, 'T', 0x00, 0xFE}, 0, 2, 5, true, false},
+		{"after lock control", []byte{0x01, 0x03, 0xA0, 0x10, 0x44, 0x03, 0x02, 0xD0, 0x00, 0xFE}, 5, 2, 2, true, false},
+		{"after nulls", []byte{0x00, 0x00, 0x03, 0x00, 0xFE}, 2, 2, 0, true, false},
+		{"long length", append([]byte{0x03, 0xFF, 0x01, 0x2C}, make([]byte, 300)...), 0, 4, 300, true, false},
+		{"terminator only", []byte{0xFE, 0x00, 0x00, 0x00}, 0, 0, 0, false, false},
+		{"proprietary then terminator", []byte{0xFD, 0x01, 0xAA, 0xFE}, 3, 0, 0, false, false},
+		{"all nulls", []byte{0x00, 0x00, 0x00, 0x00}, 4, 0, 0, false, false},
+		{"lock control to the end", append([]byte{0x01, 0x0E}, make([]byte, 14)...), 16, 0, 0, false, false},
+		{"TLV past the end", []byte{0x01, 0x40, 0x00, 0x00}, 0, 0, 0, false, true},
+		{"long length cut short", []byte{0x03, 0xFF, 0x01}, 0, 0, 0, false, true},
+		{"length cut short", []byte{0x00, 0x03}, 0, 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Once with the whole data area already read, and once with only its first page,
+			// as after openType2, so the rest has to come from the tag
+			areas := map[string]*type2Area{
+				"all read":   {size: len(tt.data), data: tt.data},
+				"first page": {picc: &iso14443a{bus: newFakeNTAG(tt.data, len(tt.data))}, size: len(tt.data), data: tt.data[:min(len(tt.data), type2PageSize)]},
+			}
+			for name, area := range areas {
+				offset, header, length, found, err := area.findNDEF()
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%s: error %v, want error %v", name, err, tt.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if offset != tt.offset || header != tt.header || length != tt.length || found != tt.found {
+					t.Errorf("%s: findNDEF = %d, %d, %d, %v; want %d, %d, %d, %v",
+						name, offset, header, length, found, tt.offset, tt.header, tt.length, tt.found)
+				}
+				if len(area.data) < offset {
+					t.Errorf("%s: only %d bytes read, short of offset %d", name, len(area.data), offset)
+				}
+			}
+		})
+	}
+}
+
+func TestType2WriteThenRead(t *testing.T) {
+	msg := ndef.Message{
+		ndef.NewExternal(tagCustomerType, []byte("Sam")),
+		ndef.NewExternal(tagLastFillType, bytes.Repeat([]byte("x"), 300)), // Needs the long length form
+	}
+	tests := []struct {
+		name string
+		data []byte
+		size int
+	}{
+		{"blank", []byte{tlvTerminator}, 496},
+		{"after lock control", []byte{0x01, 0x03, 0xA0, 0x10, 0x44, 0x03, 0x00, tlvTerminator}, 496},
+		{"replacing a message", []byte{0x03, 0x05, 0xD1, 0x01, 0x01, 'T', 0x00, tlvTerminator}, 496},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := newFakeNTAG(tt.data, tt.size)
+			c := &iso14443a{bus: tag}
+			if err := c.writeNDEF(tag.uid, msg); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.readNDEF(tag.uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, msg) {
+				t.Errorf("read back %v, want %v", got, msg)
+			}
+			if tt.data[0] == 0x01 && !bytes.Equal(tag.memory[16:21], tt.data[:5]) {
+				t.Errorf("lock control TLV not kept: % X", tag.memory[16:21])
+			}
+		})
+	}
+}
+
+// A tag whose TLVs fill it to the end has no room for a message, and nothing to read
+func TestType2Full(t *testing.T) {
+	data := append([]byte{0x01, 0x0E}, make([]byte, 14)...)
+	tag := newFakeNTAG(data, len(data))
+	c := &iso14443a{bus: tag}
+	if msg, err := c.readNDEF(tag.uid); err != nil || msg != nil {
+		t.Errorf("readNDEF = %v, %v; want nothing", msg, err)
+	}
+	if err := c.writeNDEF(tag.uid, ndef.Message{ndef.NewText("en", "Hi")}); err == nil {
+		t.Error("wrote a message to a full tag")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"petrol-pump/board"
+	"petrol-pump/pn532"
+)
+
+// pn532PassiveRetries is how many times the PN532 looks for a card per InListPassiveTarget
+// A handful keeps each look under 50ms, so card events stay as quick as with the MFRC522
+const pn532PassiveRetries = 2
+
+func init() {
+	registerReader("pn532", readerKind{
+		summary: "NXP PN532 on a serial port (HSU)",
+		options: []string{"port", "baud"},
+		open: func(opts readerOptions) (RFIDReader, error) {
+			path := opts.str("port", "")
+			if path == "" {
+				return nil, fmt.Errorf("no serial port: set port= or -pn532")
+			}
+			baud, err := opts.int("baud", pn532.DefaultBaud)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("  Attempting PN532 on %s at %d baud...\n", path, baud)
+			reader, err := NewPN532RFIDReader(path, baud)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("✓ PN532 RFID reader initialized successfully")
+			fmt.Println("  Hardware ready - tap your card on the reader to pay")
+			return reader, nil
+		},
+		pins: func(opts readerOptions) (map[int]string, error) {
+			if onHeaderUART(opts.str("port", "")) {
+				return board.UART, nil
+			}
+			return nil, nil
+		},
+	})
+}
+
+// onHeaderUART reports whether a serial port is the one on the header's TXD and RXD pins
+func onHeaderUART(path string) bool {
+	switch path {
+	case "/dev/serial0", "/dev/ttyAMA0", "/dev/ttyS0":
+		return true
+	}
+	return false
+}
+
+// PN532RFIDReader implements RFIDReader with an NXP PN532 on its HSU (UART) interface
+type PN532RFIDReader struct {
+	mu       sync.Mutex // One command at a time
+	dev      *pn532.Device
+	lastCard Card
+	lastSeen time.Time
+}
+
+// NewPN532RFIDReader opens the PN532 on a serial port
+func NewPN532RFIDReader(path string, baud int) (*PN532RFIDReader, error) {
+	dev, err := pn532.OpenHSU(path, baud)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := dev.FirmwareVersion()
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("PN532 firmware version: %w", err)
+	}
+	fmt.Printf("  %s on %s\n", fw, path)
+	if err := dev.SetPassiveRetries(pn532PassiveRetries); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("PN532 retries: %w", err)
+	}
+	return &PN532RFIDReader{dev: dev}, nil
+}
+
+// IsCardPresent checks if an RFID card is present
+func (r *PN532RFIDReader) IsCardPresent() (bool, error) {
+	card, err := r.listCard()
+	if err != nil {
+		if isNoCard(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	r.mu.Lock()
+	r.lastCard = card
+	r.lastSeen = time.Now()
+	r.mu.Unlock()
+	return true, nil
+}
+
+// ReadCard describes the card on the reader
+func (r *PN532RFIDReader) ReadCard() (Card, error) {
+	r.mu.Lock()
+	if time.Since(r.lastSeen) < 2*time.Second && len(r.lastCard.UID) > 0 {
+		card := r.lastCard
+		r.mu.Unlock()
+		return card, nil
+	}
+	r.mu.Unlock()
+
+	card, err := r.listCard()
+	if err != nil {
+		return Card{}, fmt.Errorf("failed to read card: %w", err)
+	}
+	r.mu.Lock()
+	r.lastCard = card
+	r.lastSeen = time.Now()
+	r.mu.Unlock()
+	return card, nil
+}
+
+// CardEvents implements CardEventReader
+// The PN532 is asked for a card every cardPollInterval; it has no IRQ line on HSU
+func (r *PN532RFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, r)
+}
+
+func (r *PN532RFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		card, err := r.listCard()
+		if err == nil || !isNoCard(err) || time.Now().After(deadline) {
+			return card, err
+		}
+		time.Sleep(cardPollInterval)
+	}
+}
+
+// stillPresent lists the card again; the PN532 finds a card that stays in the field every time
+func (r *PN532RFIDReader) stillPresent(uid []byte) bool {
+	card, err := r.listCard()
+	return err == nil && bytes.Equal(card.UID, uid)
+}
+
+// listCard asks the PN532 for the card in the field
+func (r *PN532RFIDReader) listCard() (Card, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dev == nil {
+		return Card{}, fmt.Errorf("PN532 closed")
+	}
+	target, found, err := r.dev.ListPassiveTarget()
+	if err != nil {
+		return Card{}, err
+	}
+	if !found {
+		return Card{}, errNoCard
+	}
+	return newCard(cardIdentity{UID: target.UID, ATQA: target.ATQA, SAK: target.SAK}), nil
+}
+
+// Close releases any card and closes the serial port
+func (r *PN532RFIDReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dev == nil {
+		return nil
+	}
+	r.dev.Release()
+	err := r.dev.Close()
+	r.dev = nil
+	return err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readerOptions are one reader's settings, as key=value pairs from -reader or the reader config
+type readerOptions map[string]string
+
+// str returns the option key, or def if it isn't set
+func (o readerOptions) str(key, def string) string {
+	if v, ok := o[key]; ok {
+		return v
+	}
+	return def
+}
+
+// int returns the option key as a number, or def if it isn't set
+func (o readerOptions) int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s=%s: not a number", key, v)
+	}
+	return n, nil
+}
+
+// readerKind is a kind of card reader that configuration can choose
+type readerKind struct {
+	summary string   // One line for -reader list
+	options []string // Option keys it understands
+	open    func(opts readerOptions) (RFIDReader, error)
+	// pins names the header pins a reader with opts takes over, for checking the wiring (nil if it takes none)
+	pins func(opts readerOptions) (map[int]string, error)
+}
+
+// readerKinds holds every kind of reader by name; each reader's file registers its own
+var readerKinds = map[string]readerKind{}
+
+func registerReader(name string, kind readerKind) {
+	if _, ok := readerKinds[name]; ok {
+		panic("card reader " + name + " registered twice")
+	}
+	readerKinds[name] = kind
+}
+
+// readerNames lists the registered kinds of reader
+func readerNames() []string {
+	names := make([]string, 0, len(readerKinds))
+	for name := range readerKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printReaderKinds describes every kind of reader and its options, for -reader list
+func printReaderKinds() {
+	fmt.Println("Card readers:")
+	for _, name := range readerNames() {
+		kind := readerKinds[name]
+		fmt.Printf("  %-16s %s\n", name, kind.summary)
+		if len(kind.options) > 0 {
+			fmt.Printf("  %-16s options: %s\n", "", strings.Join(kind.options, " "))
+		}
+	}
+}
+
+// readerSpec is one reader chosen by configuration, e.g. "periph-mfrc522 bus=0 cs=1 gain=5"
+type readerSpec struct {
+	kind    string
+	options readerOptions
+	guessed bool // Put in the default chain on the off chance; not tried in debug mode, where there's probably no Pi
+}
+
+func (s readerSpec) String() string {
+	parts := []string{s.kind}
+	for _, key := range readerKinds[s.kind].options {
+		if v, ok := s.options[key]; ok {
+			parts = append(parts, key+"="+v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseReaderSpec parses a reader name followed by its key=value options
+func parseReaderSpec(text string) (readerSpec, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return readerSpec{}, fmt.Errorf("empty card reader")
+	}
+	kind, ok := readerKinds[fields[0]]
+	if !ok {
+		return readerSpec{}, fmt.Errorf("unknown card reader %q (have %s)", fields[0], strings.Join(readerNames(), ", "))
+	}
+	spec := readerSpec{kind: fields[0], options: readerOptions{}}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return readerSpec{}, fmt.Errorf("%s: option %q should be key=value", spec.kind, field)
+		}
+		known := false
+		for _, k := range kind.options {
+			known = known || k == key
+		}
+		if !known {
+			return readerSpec{}, fmt.Errorf("%s has no option %q (has %s)", spec.kind, key, strings.Join(kind.options, ", "))
+		}
+		spec.options[key] = value
+	}
+	return spec, nil
+}
+
+// parseReaderChain parses -reader: readers separated by commas, each tried if the one before fails
+func parseReaderChain(text string) ([]readerSpec, error) {
+	var chain []readerSpec
+	for _, part := range strings.Split(text, ",") {
+		spec, err := parseReaderSpec(part)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, spec)
+	}
+	return chain, nil
+}
+
+// loadReaderConfig reads a reader config file: one reader per line, most preferred first
+// Blank lines and lines starting with # are ignored
+func loadReaderConfig(path string) ([]readerSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reader config: %w", err)
+	}
+	defer file.Close()
+
+	var chain []readerSpec
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spec, err := parseReaderSpec(line)
+		if err != nil {
+			return nil, fmt.Errorf("reader config %s:%d: %w", path, lineNo, err)
+		}
+		chain = append(chain, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reader config: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("reader config %s lists no readers", path)
+	}
+	return chain, nil
+}
+
+// pins names the header pins the reader takes over
+func (s readerSpec) pins() (map[int]string, error) {
+	kind := readerKinds[s.kind]
+	if kind.pins == nil {
+		return nil, nil
+	}
+	return kind.pins(s.options)
+}
+
+// withDefaults fills in options from defaults for every reader in chain of the same kind that doesn't set them
+func withDefaults(chain []readerSpec, defaults []readerSpec) []readerSpec {
+	for i := range chain {
+		for _, def := range defaults {
+			if chain[i].kind != def.kind {
+				continue
+			}
+			for key, value := range def.options {
+				if _, ok := chain[i].options[key]; ok {
+					continue
+				}
+				if chain[i].options == nil {
+					chain[i].options = readerOptions{}
+				}
+				chain[i].options[key] = value
+			}
+		}
+	}
+	return chain
+}
+
+// factory turns a spec into something the supervisor can open
+func (s readerSpec) factory() readerFactory {
+	kind := readerKinds[s.kind]
+	return readerFactory{name: s.kind, open: func() (RFIDReader, error) {
+		return kind.open(s.options)
+	}}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"petrol-pump/board"
+)
+
+func TestChooseReaders(t *testing.T) {
+	pn532 := readerSpec{kind: "pn532", options: readerOptions{"port": "/dev/ttyUSB0", "baud": "115200"}}
+	wedge := readerSpec{kind: "evdev", options: readerOptions{"device": "/dev/input/event0", "format": "auto"}}
+	tests := []struct {
+		name       string
+		readers    string
+		shorthands []readerSpec
+		want       []readerSpec
+	}{
+		{"default", "", nil, []readerSpec{
+			{kind: "gobot-mfrc522", guessed: true}, {kind: "periph-mfrc522"}, {kind: "mock"},
+		}},
+		{"default with shorthands", "", []readerSpec{pn532, wedge}, []readerSpec{
+			pn532, wedge, {kind: "gobot-mfrc522", guessed: true}, {kind: "periph-mfrc522"}, {kind: "mock"},
+		}},
+		{"shorthand fills in options", "pn532, mock", []readerSpec{pn532}, []readerSpec{
+			pn532, {kind: "mock"},
+		}},
+		{"chain options win", "pn532 baud=9600", []readerSpec{pn532}, []readerSpec{
+			{kind: "pn532", options: readerOptions{"port": "/dev/ttyUSB0", "baud": "9600"}},
+		}},
+		{"shorthand not in the chain", "periph-mfrc522", []readerSpec{wedge}, []readerSpec{
+			{kind: "periph-mfrc522"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chooseReaders(tt.readers, "", tt.shorthands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				if len(got[i].options) == 0 {
+					got[i].options = nil // parseReaderChain may leave an empty map
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chooseReaders(%q) = %v, want %v", tt.readers, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := chooseReaders("mock", "readers.conf", nil); err == nil {
+		t.Error("chooseReaders with both -reader and -reader-config, want an error")
+	}
+}
+
+func TestCheckPins(t *testing.T) {
+	boardPins = board.Pins{Buttons: []int{17, 27}, RST: 22, IRQ: 24}
+	defer func() { boardPins = board.Pins{} }()
+
+	tests := []struct {
+		name    string
+		buttons []int
+		chain   string
+		wantErr string // "" for no conflict
+	}{
+		{"default wiring", []int{17, 27}, "gobot-mfrc522, periph-mfrc522, mock", ""},
+		{"button on the SPI bus", []int{17, 10}, "gobot-mfrc522", "GPIO10"},
+		{"button on RST", []int{22}, "periph-mfrc522", "GPIO22"},
+		{"gobot takes no RST", []int{22}, "gobot-mfrc522", ""},
+		{"RST on the SPI bus", []int{17}, "periph-mfrc522 rst=11", "GPIO11"},
+		{"RST and IRQ together", []int{17}, "periph-mfrc522 rst=23 irq=23", "GPIO23"},
+		{"button on the header UART", []int{14}, "pn532 port=/dev/serial0", "GPIO14"},
+		{"USB serial takes no pins", []int{14}, "pn532 port=/dev/ttyUSB0", ""},
+		{"readers share a bus", []int{17}, "gobot-mfrc522 cs=1, periph-mfrc522 cs=1", ""},
+		{"bad chip select", []int{17}, "periph-mfrc522 cs=5", "periph-mfrc522"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := parseReaderChain(tt.chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkPins(tt.buttons, chain)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkPins: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("checkPins = nil, want an error about %s", tt.wantErr)
+			case err != nil && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("checkPins = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerReader("replay", readerKind{
+		summary: "taps cards from a script file, for demos and soak tests",
+		options: []string{"file", "loop"},
+		open: func(opts readerOptions) (RFIDReader, error) {
+			path := opts.str("file", "")
+			if path == "" {
+				return nil, fmt.Errorf("no script: set file=")
+			}
+			loop := opts.str("loop", "false")
+			if loop != "true" && loop != "false" {
+				return nil, fmt.Errorf("loop must be true or false")
+			}
+			reader, err := NewReplayRFIDReader(path, loop == "true")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("✓ Replaying %d card taps from %s\n", len(reader.taps), path)
+			return reader, nil
+		},
+	})
+}
+
+// replayTap is one line of a replay script
+type replayTap struct {
+	after time.Duration // Wait after the previous card left
+	card  Card
+	held  time.Duration // How long the card stays on the reader
+}
+
+// ReplayRFIDReader implements RFIDReader by tapping cards from a script, with no hardware
+// Each line of the script is "<wait> <UID> [<held>]", e.g. "5s 04:A2:3B:11:22:33:44 1s":
+// wait 5 seconds, then hold the card on the reader for 1 second (the default)
+type ReplayRFIDReader struct {
+	taps []replayTap
+	loop bool // Start again after the last tap
+
+	mu      sync.Mutex
+	next    int       // Tap to come
+	due     time.Time // When it arrives
+	current Card      // Card on the reader, until gone
+	gone    time.Time
+}
+
+// NewReplayRFIDReader loads a replay script; the first tap comes its wait after now
+func NewReplayRFIDReader(path string, loop bool) (*ReplayRFIDReader, error) {
+	taps, err := loadReplayScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayRFIDReader{taps: taps, loop: loop, due: time.Now().Add(taps[0].after)}, nil
+}
+
+func loadReplayScript(path string) ([]replayTap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay script: %w", err)
+	}
+	defer file.Close()
+
+	var taps []replayTap
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("replay script %s:%d: expected \"wait UID [held]\"", path, lineNo)
+		}
+		tap := replayTap{held: time.Second}
+		if tap.after, err = time.ParseDuration(fields[0]); err != nil {
+			return nil, fmt.Errorf("replay script %s:%d: %w", path, lineNo, err)
+		}
+		uid, err := parseUID(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("replay script %s:%d: %w", path, lineNo, err)
+		}
+		tap.card = Card{UID: uid, Family: CardUnknown}
+		if len(fields) == 3 {
+			if tap.held, err = time.ParseDuration(fields[2]); err != nil {
+				return nil, fmt.Errorf("replay script %s:%d: %w", path, lineNo, err)
+			}
+		}
+		taps = append(taps, tap)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay script: %w", err)
+	}
+	if len(taps) == 0 {
+		return nil, fmt.Errorf("replay script %s has no taps", path)
+	}
+	return taps, nil
+}
+
+// onReader returns the card on the reader now, if any
+func (r *ReplayRFIDReader) onReader() (Card, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current, len(r.current.UID) > 0 && time.Now().Before(r.gone)
+}
+
+// IsCardPresent checks if the script has a card on the reader
+func (r *ReplayRFIDReader) IsCardPresent() (bool, error) {
+	_, present := r.onReader()
+	return present, nil
+}
+
+// ReadCard describes the card the script has on the reader
+func (r *ReplayRFIDReader) ReadCard() (Card, error) {
+	card, present := r.onReader()
+	if !present {
+		return Card{}, errNoCard
+	}
+	return card, nil
+}
+
+// CardEvents implements CardEventReader
+func (r *ReplayRFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, r)
+}
+
+func (r *ReplayRFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	r.mu.Lock()
+	if r.next == len(r.taps) && r.loop {
+		r.next = 0
+		r.due = r.gone.Add(r.taps[0].after)
+	}
+	if r.next == len(r.taps) || time.Until(r.due) > timeout {
+		r.mu.Unlock()
+		time.Sleep(timeout)
+		return Card{}, errNoCard
+	}
+	tap, due := r.taps[r.next], r.due
+	r.mu.Unlock()
+	time.Sleep(time.Until(due))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = tap.card
+	r.gone = due.Add(tap.held)
+	if r.next++; r.next < len(r.taps) {
+		r.due = r.gone.Add(r.taps[r.next].after)
+	}
+	return tap.card, nil
+}
+
+func (r *ReplayRFIDReader) stillPresent(uid []byte) bool {
+	card, present := r.onReader()
+	return present && bytes.Equal(card.UID, uid)
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"petrol-pump/ndef"
+)
+
+// ReaderHealth is how well the RFID reader is working, for the screen and the log
+type ReaderHealth int
+
+const (
+	HealthOK       ReaderHealth = iota // The reader chosen at startup is working
+	HealthDegraded                     // Errors are piling up, or a fallback reader has taken over
+	HealthOffline                      // No reader is working; reconnecting
+)
+
+func (h ReaderHealth) String() string {
+	switch h {
+	case HealthOK:
+		return "OK"
+	case HealthDegraded:
+		return "degraded"
+	}
+	return "offline"
+}
+
+// How the supervisor decides a reader has failed, and how it brings one back
+const (
+	readerFailureLimit    = 5 // Errors or panics in a row before the reader is reopened
+	readerReopenAttempts  = 3 // Tries at reopening a reader before failing over to the next
+	readerBackoff         = 500 * time.Millisecond
+	readerBackoffMax      = 30 * time.Second
+	readerDegradedFailure = 2 // Errors in a row before health shows degraded
+
+	// readerHangLimit is how long past its own timeout a reader call may take before the reader
+	// counts as hung, like an MFRC522 that stops answering SPI mid-transfer
+	readerHangLimit = 5 * time.Second
+)
+
+var (
+	errReaderOffline = errors.New("card reader offline")
+	errReaderHung    = errors.New("card reader stopped answering")
+	errUnsupported   = errors.New("not supported by this card reader")
+)
+
+// readerFactory opens one kind of reader
+type readerFactory struct {
+	name string
+	open func() (RFIDReader, error)
+}
+
+// readerSupervisor runs the card reader on behalf of everything else, and replaces it when it fails
+// Detection errors and panics are counted; after readerFailureLimit in a row the reader is closed
+// and opened again with backoff, failing over to the next factory if it won't come back.
+// A call that hangs is abandoned and the reader replaced straight away
+// It implements every reader interface the pump uses, passing calls on to whichever reader is current
+type readerSupervisor struct {
+	factories []readerFactory     // In order of preference
+	mock      *MockRFIDReader     // The mock fallback's reader, for the debug keys (nil without one)
+	hangLimit time.Duration       // readerHangLimit; tests shorten it
+	sleep     func(time.Duration) // time.Sleep; tests record the backoff instead
+
+	mu        sync.Mutex
+	current   RFIDReader
+	detector  cardDetector
+	index     int // Factory the current reader came from
+	primary   int // Factory the reader came from at startup
+	failures  int // Detection errors in a row
+	health    ReaderHealth
+	listeners []func(ReaderHealth, string)
+}
+
+func newReaderSupervisor(factories []readerFactory, mock *MockRFIDReader) *readerSupervisor {
+	return &readerSupervisor{factories: factories, mock: mock, hangLimit: readerHangLimit, sleep: time.Sleep, health: HealthOffline}
+}
+
+// start opens the first reader in the chain that works
+func (s *readerSupervisor) start() error {
+	for i, f := range s.factories {
+		reader, err := openReader(f)
+		if err != nil {
+			fmt.Printf("  ⚠ %s initialization failed: %v\n", f.name, err)
+			continue
+		}
+		s.mu.Lock()
+		s.install(i, reader)
+		s.primary = i
+		s.mu.Unlock()
+		s.setHealth(HealthOK, f.name+" ready")
+		return nil
+	}
+	return fmt.Errorf("no card reader could be opened")
+}
+
+// openReader calls a factory, turning a panic into an error
+func openReader(f readerFactory) (reader RFIDReader, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic opening %s: %v", f.name, r)
+		}
+	}()
+	return f.open()
+}
+
+// install makes reader current; s.mu must be held
+func (s *readerSupervisor) install(index int, reader RFIDReader) {
+	s.current, s.index, s.failures = reader, index, 0
+	if d, ok := reader.(cardDetector); ok {
+		s.detector = d
+	} else {
+		s.detector = &pollingDetector{reader: reader}
+	}
+}
+
+// Name is the name of the current reader
+func (s *readerSupervisor) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return "none"
+	}
+	return s.factories[s.index].name
+}
+
+// Health reports how the reader is doing
+func (s *readerSupervisor) Health() ReaderHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.health
+}
+
+// OnHealthChange calls fn whenever the reader's health changes, with the reason
+func (s *readerSupervisor) OnHealthChange(fn func(ReaderHealth, string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *readerSupervisor) setHealth(h ReaderHealth, reason string) {
+	s.mu.Lock()
+	if s.health == h {
+		s.mu.Unlock()
+		return
+	}
+	s.health = h
+	listeners := append([]func(ReaderHealth, string){}, s.listeners...)
+	s.mu.Unlock()
+
+	switch h {
+	case HealthOK:
+		fmt.Printf("✓ RFID reader OK: %s\n", reason)
+	case HealthDegraded:
+		fmt.Printf("⚠ RFID reader degraded: %s\n", reason)
+	default:
+		fmt.Printf("✗ RFID reader offline: %s\n", reason)
+	}
+	for _, fn := range listeners {
+		fn(h, reason)
+	}
+}
+
+// settledHealth is the health of a working reader: OK unless a fallback has taken over
+func (s *readerSupervisor) settledHealth() (ReaderHealth, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := s.factories[s.index].name
+	if s.index > s.primary {
+		return HealthDegraded, fmt.Sprintf("running on fallback %s", name)
+	}
+	return HealthOK, name + " working"
+}
+
+// report counts a detection result, reopening the reader once it has failed too often
+func (s *readerSupervisor) report(err error) {
+	if err == nil || isNoCard(err) {
+		s.mu.Lock()
+		recovered := s.failures >= readerDegradedFailure
+		s.failures = 0
+		s.mu.Unlock()
+		if recovered {
+			s.setHealth(s.settledHealth())
+		}
+		return
+	}
+
+	s.mu.Lock()
+	s.failures++
+	failures := s.failures
+	name := s.factories[s.index].name
+	s.mu.Unlock()
+	switch {
+	case failures >= readerFailureLimit:
+		s.reconnect(fmt.Errorf("%s failed %d times in a row: %w", name, failures, err))
+	case failures >= readerDegradedFailure:
+		s.setHealth(HealthDegraded, fmt.Sprintf("%s: %v", name, err))
+	}
+}
+
+// reconnect closes the current reader and opens one again, backing off between tries
+// The same kind of reader is tried readerReopenAttempts times before moving down the chain;
+// once the chain runs out it starts again from the reader that failed
+func (s *readerSupervisor) reconnect(cause error) {
+	s.mu.Lock()
+	failed, reader := s.index, s.current
+	s.current, s.detector = nil, nil
+	s.mu.Unlock()
+	closeReader(reader, s.hangLimit)
+	s.setHealth(HealthOffline, cause.Error())
+
+	backoff := readerBackoff
+	next, tries := failed, 0
+	for {
+		s.sleep(backoff)
+		f := s.factories[next]
+		fmt.Printf("🔧 Reopening RFID reader: %s\n", f.name)
+		reader, err := openReader(f)
+		if err == nil {
+			s.mu.Lock()
+			s.install(next, reader)
+			s.mu.Unlock()
+			s.setHealth(s.settledHealth())
+			return
+		}
+		fmt.Printf("  ⚠ %s: %v\n", f.name, err)
+
+		if tries++; tries >= readerReopenAttempts {
+			tries = 0
+			if next++; next == len(s.factories) {
+				next = failed
+			}
+		}
+		backoff = min(backoff*2, readerBackoffMax)
+	}
+}
+
+// closeReader closes a reader that has a Close method, whatever state it is in
+// A reader that has hung may never close; it is left behind rather than hang the supervisor too
+func closeReader(reader RFIDReader, limit time.Duration) {
+	closer, ok := reader.(io.Closer)
+	if !ok {
+		return
+	}
+	_, err := callReader(limit, "close", func() (struct{}, error) {
+		return struct{}{}, closer.Close()
+	})
+	if err != nil {
+		fmt.Printf("⚠ Closing RFID reader: %v\n", err)
+	}
+}
+
+// callReader runs a call into a reader driver, turning a panic into an error
+// If the call hasn't returned within limit it is abandoned, and errReaderHung returned
+func callReader[T any](limit time.Duration, what string, call func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1) // The call can still finish after it has been given up on
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic %s: %v", what, r)}
+			}
+		}()
+		value, err := call()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-time.After(limit):
+		var zero T
+		return zero, fmt.Errorf("%s: %w after %s", what, errReaderHung, limit)
+	}
+}
+
+// hung replaces the reader behind detector d, which has stopped answering
+func (s *readerSupervisor) hung(d cardDetector, err error) {
+	s.mu.Lock()
+	if s.detector != d {
+		// Already replaced
+		s.mu.Unlock()
+		return
+	}
+	name := s.factories[s.index].name
+	s.mu.Unlock()
+	s.reconnect(fmt.Errorf("%s: %w", name, err))
+}
+
+// Close closes the current reader
+func (s *readerSupervisor) Close() error {
+	s.mu.Lock()
+	reader := s.current
+	s.current, s.detector = nil, nil
+	s.mu.Unlock()
+	closeReader(reader, s.hangLimit)
+	return nil
+}
+
+func (s *readerSupervisor) reader() RFIDReader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// detectCard implements cardDetector, counting failures and panics and replacing a reader that hangs
+func (s *readerSupervisor) detectCard(timeout time.Duration) (Card, error) {
+	s.mu.Lock()
+	d := s.detector
+	s.mu.Unlock()
+	if d == nil {
+		time.Sleep(timeout)
+		return Card{}, errReaderOffline
+	}
+	card, err := callReader(timeout+s.hangLimit, "detecting card", func() (Card, error) {
+		return d.detectCard(timeout)
+	})
+	if errors.Is(err, errReaderHung) {
+		s.hung(d, err)
+		return Card{}, err
+	}
+	s.report(err)
+	return card, err
+}
+
+// stillPresent implements cardDetector
+func (s *readerSupervisor) stillPresent(uid []byte) bool {
+	s.mu.Lock()
+	d := s.detector
+	s.mu.Unlock()
+	if d == nil {
+		return false
+	}
+	present, err := callReader(s.hangLimit, "checking card", func() (bool, error) {
+		return d.stillPresent(uid), nil
+	})
+	switch {
+	case errors.Is(err, errReaderHung):
+		s.hung(d, err)
+	case err != nil:
+		s.report(err)
+	}
+	return present
+}
+
+// CardEvents implements CardEventReader, carrying on across reconnections
+func (s *readerSupervisor) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, s)
+}
+
+// IsCardPresent implements RFIDReader
+func (s *readerSupervisor) IsCardPresent() (bool, error) {
+	r := s.reader()
+	if r == nil {
+		return false, errReaderOffline
+	}
+	return r.IsCardPresent()
+}
+
+// ReadCard implements RFIDReader
+func (s *readerSupervisor) ReadCard() (Card, error) {
+	r := s.reader()
+	if r == nil {
+		return Card{}, errReaderOffline
+	}
+	return r.ReadCard()
+}
+
+// supports reports whether the reader running now has the capability T, such as NDEFReader
+// The answer can change when the supervisor fails over to a different kind of reader
+func supports[T any](s *readerSupervisor) bool {
+	_, ok := s.reader().(T)
+	return ok
+}
+
+// readerCan reports whether reader has the capability T; a supervisor answers for its current reader
+func readerCan[T any](reader any) bool {
+	if s, ok := reader.(*readerSupervisor); ok {
+		return s != nil && supports[T](s)
+	}
+	_, ok := reader.(T)
+	return ok
+}
+
+// capable returns the current reader if it has the capability T
+func capable[T any](s *readerSupervisor, what string) (T, error) {
+	var zero T
+	r := s.reader()
+	if r == nil {
+		return zero, errReaderOffline
+	}
+	c, ok := r.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s: %w", what, errUnsupported)
+	}
+	return c, nil
+}
+
+// ReadValue implements ValueCardReader
+func (s *readerSupervisor) ReadValue(card Card, key ClassicKey, block byte) (int32, error) {
+	r, err := capable[ValueCardReader](s, "value blocks")
+	if err != nil {
+		return 0, err
+	}
+	return r.ReadValue(card, key, block)
+}
+
+// AddValue implements ValueCardReader
+func (s *readerSupervisor) AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error) {
+	r, err := capable[ValueCardReader](s, "value blocks")
+	if err != nil {
+		return 0, err
+	}
+	return r.AddValue(card, key, block, delta)
+}
+
+// WriteValue implements ValueCardReader
+func (s *readerSupervisor) WriteValue(card Card, key ClassicKey, block byte, value int32) error {
+	r, err := capable[ValueCardReader](s, "value blocks")
+	if err != nil {
+		return err
+	}
+	return r.WriteValue(card, key, block, value)
+}
+
+// ReadNDEF implements NDEFReader
+func (s *readerSupervisor) ReadNDEF(card Card) (ndef.Message, error) {
+	r, err := capable[NDEFReader](s, "NDEF")
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadNDEF(card)
+}
+
+// WriteNDEF implements NDEFReader
+func (s *readerSupervisor) WriteNDEF(card Card, msg ndef.Message) error {
+	r, err := capable[NDEFReader](s, "NDEF")
+	if err != nil {
+		return err
+	}
+	return r.WriteNDEF(card, msg)
+}
+
+// APDUSession implements APDUReader
+func (s *readerSupervisor) APDUSession(card Card, fn func(t APDUTransceiver) error) error {
+	r, err := capable[APDUReader](s, "ISO-DEP")
+	if err != nil {
+		return err
+	}
+	return r.APDUSession(card, fn)
+}
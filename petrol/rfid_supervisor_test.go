@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errBusFault = errors.New("spi: i/o timeout")
+
+// fakeReader is a reader whose detection answers are scripted by the test
+type fakeReader struct {
+	name   string
+	detect func() (Card, error)
+	mu     sync.Mutex
+	closed bool
+}
+
+func (f *fakeReader) IsCardPresent() (bool, error)           { return false, nil }
+func (f *fakeReader) ReadCard() (Card, error)                { return Card{}, errNoCard }
+func (f *fakeReader) detectCard(time.Duration) (Card, error) { return f.detect() }
+func (f *fakeReader) stillPresent([]byte) bool               { return false }
+
+func (f *fakeReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeReader) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func noCard() (Card, error)    { return Card{}, errNoCard }
+func failing() (Card, error)   { return Card{}, errBusFault }
+func panicking() (Card, error) { panic("nil pointer in driver") }
+
+// fakeChain is a chain of reader factories that open or fail as the test says, logging every open
+type fakeChain struct {
+	mu      sync.Mutex
+	opens   []string                        // Factories opened, in order
+	failing map[string]int                  // Opens still to fail, per factory; -1 fails for ever
+	detect  map[string]func() (Card, error) // What newly opened readers detect
+	readers []*fakeReader
+}
+
+func (c *fakeChain) factory(name string) readerFactory {
+	return readerFactory{name: name, open: func() (RFIDReader, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.opens = append(c.opens, name)
+		if n := c.failing[name]; n != 0 {
+			if n > 0 {
+				c.failing[name]--
+			}
+			return nil, fmt.Errorf("%s: no such device", name)
+		}
+		detect := c.detect[name]
+		if detect == nil {
+			detect = noCard
+		}
+		r := &fakeReader{name: name, detect: detect}
+		c.readers = append(c.readers, r)
+		return r, nil
+	}}
+}
+
+func (c *fakeChain) opened() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.opens...)
+}
+
+// newTestSupervisor starts a supervisor over the chain's factories, recording backoff and health changes
+func newTestSupervisor(t *testing.T, chain *fakeChain, names ...string) (s *readerSupervisor, backoff *[]time.Duration, health *[]ReaderHealth) {
+	t.Helper()
+	var factories []readerFactory
+	for _, name := range names {
+		factories = append(factories, chain.factory(name))
+	}
+	s = newReaderSupervisor(factories, nil)
+	s.hangLimit = 50 * time.Millisecond
+	backoff, health = new([]time.Duration), new([]ReaderHealth)
+	s.sleep = func(d time.Duration) { *backoff = append(*backoff, d) }
+	s.OnHealthChange(func(h ReaderHealth, reason string) { *health = append(*health, h) })
+	if err := s.start(); err != nil {
+		t.Fatal(err)
+	}
+	return s, backoff, health
+}
+
+func TestSupervisorFailsOver(t *testing.T) {
+	chain := &fakeChain{
+		failing: map[string]int{},
+		detect:  map[string]func() (Card, error){"mfrc522": failing},
+	}
+	s, backoff, health := newTestSupervisor(t, chain, "mfrc522", "pn532", "mock")
+	if s.Name() != "mfrc522" || s.Health() != HealthOK {
+		t.Fatalf("started on %s, %s", s.Name(), s.Health())
+	}
+	first := chain.readers[0]
+
+	// The MFRC522 stops working, and won't open again
+	chain.failing["mfrc522"] = -1
+	for i := 1; i <= readerFailureLimit; i++ {
+		_, err := s.detectCard(time.Millisecond)
+		if !errors.Is(err, errBusFault) {
+			t.Fatalf("detection %d: %v, want the bus fault", i, err)
+		}
+		want := HealthOK
+		if i >= readerDegradedFailure {
+			want = HealthDegraded
+		}
+		if i < readerFailureLimit && s.Health() != want {
+			t.Errorf("after %d errors health is %s, want %s", i, s.Health(), want)
+		}
+	}
+
+	if !first.isClosed() {
+		t.Error("the failed reader wasn't closed")
+	}
+	wantOpens := []string{"mfrc522", "mfrc522", "mfrc522", "mfrc522", "pn532"}
+	if got := chain.opened(); !reflect.DeepEqual(got, wantOpens) {
+		t.Errorf("opened %v, want %v", got, wantOpens)
+	}
+	wantBackoff := []time.Duration{readerBackoff, 2 * readerBackoff, 4 * readerBackoff, 8 * readerBackoff}
+	if !reflect.DeepEqual(*backoff, wantBackoff) {
+		t.Errorf("backed off %v, want %v", *backoff, wantBackoff)
+	}
+	wantHealth := []ReaderHealth{HealthOK, HealthDegraded, HealthOffline, HealthDegraded}
+	if !reflect.DeepEqual(*health, wantHealth) {
+		t.Errorf("health went %v, want %v", *health, wantHealth)
+	}
+	if s.Name() != "pn532" || s.Health() != HealthDegraded {
+		t.Errorf("running on %s, %s; want pn532, degraded as a fallback", s.Name(), s.Health())
+	}
+
+	// The fallback working doesn't make it the primary
+	if _, err := s.detectCard(time.Millisecond); !errors.Is(err, errNoCard) {
+		t.Fatal(err)
+	}
+	if s.Health() != HealthDegraded {
+		t.Errorf("health %s on the fallback, want degraded", s.Health())
+	}
+}
+
+func TestSupervisorWrapsAroundTheChain(t *testing.T) {
+	// The MFRC522 was missing at startup, so the PN532 is the primary reader
+	chain := &fakeChain{
+		failing: map[string]int{"mfrc522": 1},
+		detect:  map[string]func() (Card, error){"pn532": panicking},
+	}
+	s, backoff, health := newTestSupervisor(t, chain, "mfrc522", "pn532", "wedge")
+	if s.Name() != "pn532" {
+		t.Fatalf("started on %s, want pn532", s.Name())
+	}
+
+	// Panics count as failures; the PN532 then won't reopen, nor will the wedge, until the chain comes round again
+	chain.failing["pn532"], chain.failing["wedge"] = readerReopenAttempts, readerReopenAttempts
+	chain.opens = nil
+	for i := 0; i < readerFailureLimit; i++ {
+		if _, err := s.detectCard(time.Millisecond); err == nil || isNoCard(err) {
+			t.Fatalf("detection %d: %v, want the panic", i+1, err)
+		}
+	}
+
+	wantOpens := []string{"pn532", "pn532", "pn532", "wedge", "wedge", "wedge", "pn532"}
+	if got := chain.opened(); !reflect.DeepEqual(got, wantOpens) {
+		t.Errorf("opened %v, want %v", got, wantOpens)
+	}
+	if len(*backoff) != len(wantOpens) || (*backoff)[len(*backoff)-1] != readerBackoffMax {
+		t.Errorf("backed off %v, want %d waits capped at %s", *backoff, len(wantOpens), readerBackoffMax)
+	}
+	// The PN532 was primary for this run, so it coming back is OK
+	wantHealth := []ReaderHealth{HealthOK, HealthDegraded, HealthOffline, HealthOK}
+	if !reflect.DeepEqual(*health, wantHealth) {
+		t.Errorf("health went %v, want %v", *health, wantHealth)
+	}
+}
+
+func TestSupervisorReplacesHungReader(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	chain := &fakeChain{
+		failing: map[string]int{},
+		detect: map[string]func() (Card, error){"mfrc522": func() (Card, error) {
+			<-hang
+			return Card{}, errNoCard
+		}},
+	}
+	s, backoff, health := newTestSupervisor(t, chain, "mfrc522", "mock")
+
+	// The first reader hangs; the one opened in its place works
+	chain.detect["mfrc522"] = noCard
+	start := time.Now()
+	if _, err := s.detectCard(time.Millisecond); !errors.Is(err, errReaderHung) {
+		t.Fatalf("detectCard = %v, want errReaderHung", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s to give up on a hung reader", elapsed)
+	}
+
+	if !reflect.DeepEqual(chain.opened(), []string{"mfrc522", "mfrc522"}) {
+		t.Errorf("opened %v, want the MFRC522 reopened", chain.opened())
+	}
+	if !reflect.DeepEqual(*backoff, []time.Duration{readerBackoff}) {
+		t.Errorf("backed off %v", *backoff)
+	}
+	wantHealth := []ReaderHealth{HealthOK, HealthOffline, HealthOK}
+	if !reflect.DeepEqual(*health, wantHealth) {
+		t.Errorf("health went %v, want %v", *health, wantHealth)
+	}
+	if _, err := s.detectCard(time.Millisecond); !errors.Is(err, errNoCard) {
+		t.Errorf("replacement reader: %v", err)
+	}
+
+	// A late answer from the abandoned reader doesn't replace the new one
+	s.hung(chain.readers[0], errReaderHung)
+	if len(chain.opened()) != 2 {
+		t.Errorf("a stale hang reopened the reader: %v", chain.opened())
+	}
+}
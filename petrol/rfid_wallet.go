@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"petrol-pump/payment"
+	"petrol-pump/pump"
+)
+
+// DefaultWalletBlock is where wallet cards keep their balance: the first block of sector 1
+const DefaultWalletBlock = 4
+
+// walletCards keeps wallet balances on MIFARE Classic cards, in pence in a value block
+// It implements payment.ValueStore for the cards on the forecourt's reader
+type walletCards struct {
+	reader   ValueCardReader
+	block    byte
+	key      ClassicKey // Balance checks and payments
+	topUpKey ClassicKey // Top-ups and refunds - usually key B, as cards normally only allow increments with it
+}
+
+// Balance implements payment.ValueStore
+// A card that can't be opened with the wallet key, or has no value block, is not a wallet card
+func (w *walletCards) Balance(cardUID string) (pump.Money, error) {
+	return w.balance(cardUID, w.key)
+}
+
+// Debit implements payment.ValueStore
+func (w *walletCards) Debit(cardUID string, amount pump.Money) (pump.Money, error) {
+	balance, err := w.Balance(cardUID)
+	if err != nil {
+		return 0, err
+	}
+	if balance < amount {
+		return balance, &payment.BalanceError{Balance: balance, Due: amount}
+	}
+	return w.add(cardUID, w.key, balance, -amount)
+}
+
+// Credit implements payment.ValueStore
+func (w *walletCards) Credit(cardUID string, amount pump.Money) (pump.Money, error) {
+	balance, err := w.balance(cardUID, w.topUpKey)
+	if err != nil {
+		return 0, err
+	}
+	return w.add(cardUID, w.topUpKey, balance, amount)
+}
+
+// TopUp adds amount to a card, first turning it into a wallet card holding nothing if it isn't one
+func (w *walletCards) TopUp(card Card, amount pump.Money) (pump.Money, error) {
+	if card.Family != CardMifareClassic1K && card.Family != CardMifareClassic4K {
+		return 0, fmt.Errorf("%s cards can't hold a balance", card.Family)
+	}
+	if _, err := w.balance(card.ID(), w.topUpKey); errors.Is(err, errNotValueBlock) {
+		fmt.Printf("ℹ Card %s: new wallet card\n", card.ID())
+		if err := w.reader.WriteValue(card, w.topUpKey, w.block, 0); err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, err
+	}
+	return w.Credit(card.ID(), amount)
+}
+
+// balance reads a card's value block with key
+func (w *walletCards) balance(cardUID string, key ClassicKey) (pump.Money, error) {
+	uid, err := parseUID(cardUID)
+	if err != nil {
+		return 0, err
+	}
+	value, err := w.reader.ReadValue(Card{UID: uid}, key, w.block)
+	if errors.Is(err, errAuthFailed) || errors.Is(err, errNotValueBlock) || errors.Is(err, errUnsupported) {
+		return 0, fmt.Errorf("%w: %w", payment.ErrNoWallet, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("%w: negative balance %d", payment.ErrNoWallet, value)
+	}
+	return pump.Money(value), nil
+}
+
+// add changes a card's balance from before by amount and returns the new balance
+func (w *walletCards) add(cardUID string, key ClassicKey, before, amount pump.Money) (pump.Money, error) {
+	if amount < math.MinInt32 || amount > math.MaxInt32 {
+		return 0, fmt.Errorf("%s is too much for a wallet card", amount)
+	}
+	uid, err := parseUID(cardUID)
+	if err != nil {
+		return 0, err
+	}
+	value, err := w.reader.AddValue(Card{UID: uid}, key, w.block, int32(amount))
+	if errors.Is(err, errValueUnconfirmed) {
+		return w.confirm(cardUID, key, before, before+amount, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return pump.Money(value), nil
+}
+
+// confirm reads the balance again to find out whether an unconfirmed change from before was committed
+// Reporting a change that went through as failed would charge the customer twice when they tap again,
+// so it only fails if the card still holds the old balance; a card that can't be read is taken to hold expected
+func (w *walletCards) confirm(cardUID string, key ClassicKey, before, expected pump.Money, cause error) (pump.Money, error) {
+	balance, err := w.balance(cardUID, key)
+	switch {
+	case err == nil && balance == before:
+		return 0, cause
+	case err == nil:
+		return balance, nil
+	}
+	fmt.Printf("⚠ Card %s: %v, and the balance can't be read again (%v) - taking it as %s\n", cardUID, cause, err, expected)
+	return expected, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"petrol-pump/payment"
+	"petrol-pump/pump"
+)
+
+// flakyValueCard is a wallet card whose changes can be lost on the way back from the card
+type flakyValueCard struct {
+	RFIDReader
+	value  int32
+	commit bool // Whether the card commits a change whose answer is lost
+	lose   bool // Lose the card's answer to the next change
+	leave  bool // Take the card away once an answer has been lost
+	gone   bool
+}
+
+func (f *flakyValueCard) ReadValue(card Card, key ClassicKey, block byte) (int32, error) {
+	if f.gone {
+		return 0, fmt.Errorf("card %s not on the reader: %w", card.ID(), errNoCard)
+	}
+	return f.value, nil
+}
+
+func (f *flakyValueCard) AddValue(card Card, key ClassicKey, block byte, delta int32) (int32, error) {
+	if !f.lose {
+		f.value += delta
+		return f.value, nil
+	}
+	f.lose, f.gone = false, f.leave
+	if f.commit {
+		f.value += delta
+	}
+	return 0, fmt.Errorf("%w: transfer to block %d: %w", errValueUnconfirmed, block, errNoCard)
+}
+
+func (f *flakyValueCard) WriteValue(card Card, key ClassicKey, block byte, value int32) error {
+	f.value = value
+	return nil
+}
+
+func TestWalletDebit(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    flakyValueCard
+		want    pump.Money // Balance reported
+		left    int32      // Balance on the card
+		wantErr error
+	}{
+		{"paid", flakyValueCard{value: 5000}, 3000, 3000, nil},
+		{"balance too low", flakyValueCard{value: 1500}, 1500, 1500, payment.ErrInsufficientFunds},
+		{"answer lost, committed", flakyValueCard{value: 5000, lose: true, commit: true}, 3000, 3000, nil},
+		{"answer lost, not committed", flakyValueCard{value: 5000, lose: true}, 0, 5000, errValueUnconfirmed},
+		{"answer lost, not committed, card gone", flakyValueCard{value: 5000, lose: true, leave: true}, 3000, 5000, nil},
+		{"answer lost, committed, card gone", flakyValueCard{value: 5000, lose: true, commit: true, leave: true}, 3000, 3000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := tt.card
+			w := &walletCards{reader: &card, block: DefaultWalletBlock, key: DefaultClassicKey, topUpKey: DefaultClassicKey}
+			got, err := w.Debit("DEADBEEF", 2000)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Debit error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want || card.value != tt.left {
+				t.Errorf("Debit = %s with %d on the card, want %s with %d", got, card.value, tt.want, tt.left)
+			}
+		})
+	}
+}
+
+func TestWalletCreditConfirmed(t *testing.T) {
+	card := flakyValueCard{value: 500, lose: true, commit: true}
+	w := &walletCards{reader: &card, block: DefaultWalletBlock, key: DefaultClassicKey, topUpKey: DefaultClassicKey}
+	got, err := w.Credit("DEADBEEF", 250)
+	if err != nil || got != 750 || card.value != 750 {
+		t.Errorf("Credit = %s, %v with %d on the card; want £7.50 once", got, err, card.value)
+	}
+}
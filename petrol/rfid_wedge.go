@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"petrol-pump/evdev"
+)
+
+// defaultWedgeFormat is how a keyboard-wedge reader is taken to type UIDs unless told otherwise
+const defaultWedgeFormat = "auto"
+
+// wedgeRepeatWindow is how long a card counts as still on a wedge reader after it was last typed
+// Some readers type the UID again every second or so while the card stays; that isn't a new tap
+const wedgeRepeatWindow = time.Second
+
+func init() {
+	registerReader("evdev", readerKind{
+		summary: "USB reader that types UIDs like a keyboard, read through evdev",
+		options: []string{"device", "format"},
+		open: func(opts readerOptions) (RFIDReader, error) {
+			path := opts.str("device", "")
+			if path == "" {
+				return nil, fmt.Errorf("no input device: set device= or -wedge-reader")
+			}
+			format := opts.str("format", defaultWedgeFormat)
+			if !validWedgeFormat(format) {
+				return nil, fmt.Errorf("format must be decimal, hex or auto")
+			}
+			fmt.Printf("  Attempting keyboard-wedge reader on %s (%s UIDs)...\n", path, format)
+			reader, err := NewWedgeRFIDReader(path, format)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("✓ Keyboard-wedge RFID reader initialized successfully")
+			fmt.Println("  Hardware ready - tap your card on the reader to pay")
+			return reader, nil
+		},
+	})
+}
+
+// validWedgeFormat reports whether format is one parseWedgeUID understands
+func validWedgeFormat(format string) bool {
+	return format == "decimal" || format == "hex" || format == "auto"
+}
+
+// WedgeRFIDReader implements RFIDReader with a USB reader that types each card's UID like a keyboard
+// The input device is grabbed, so the UIDs don't also land in the console or the GUI
+type WedgeRFIDReader struct {
+	dev    *evdev.Device
+	format string
+	scans  chan Card
+
+	mu       sync.Mutex
+	lastCard Card
+	lastSeen time.Time
+	err      error // Why the device stopped being readable
+}
+
+// NewWedgeRFIDReader opens a keyboard-wedge reader's input device, such as /dev/input/event0
+// format is how it types UIDs: decimal, hex or auto
+func NewWedgeRFIDReader(path, format string) (*WedgeRFIDReader, error) {
+	dev, err := evdev.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &WedgeRFIDReader{dev: dev, format: format, scans: make(chan Card, 1)}
+	go r.readLines()
+	return r, nil
+}
+
+// readLines assembles what the reader types into lines, one per card, until the device closes
+func (r *WedgeRFIDReader) readLines() {
+	var line []byte
+	for {
+		code, err := r.dev.ReadKey(time.Time{})
+		if err != nil {
+			r.mu.Lock()
+			r.err = fmt.Errorf("keyboard-wedge reader: %w", err)
+			r.mu.Unlock()
+			return
+		}
+		c, ok := evdev.Char(code)
+		switch {
+		case !ok:
+			continue
+		case c != '\n':
+			if len(line) < 32 {
+				line = append(line, c)
+			}
+			continue
+		case len(line) == 0:
+			continue
+		}
+
+		uid, err := parseWedgeUID(string(line), r.format)
+		line = line[:0]
+		if err != nil {
+			fmt.Printf("⚠ Keyboard-wedge reader: %v\n", err)
+			continue
+		}
+		r.scanned(Card{UID: uid, Family: CardUnknown})
+	}
+}
+
+// scanned records a card the reader typed, passing it on unless it is a repeat of the card already there
+func (r *WedgeRFIDReader) scanned(card Card) {
+	r.mu.Lock()
+	repeat := bytes.Equal(card.UID, r.lastCard.UID) && time.Since(r.lastSeen) < wedgeRepeatWindow
+	r.lastCard = card
+	r.lastSeen = time.Now()
+	r.mu.Unlock()
+	if repeat {
+		return
+	}
+	select {
+	case <-r.scans: // Nobody took the last one; the newest tap wins
+	default:
+	}
+	r.scans <- card
+}
+
+// parseWedgeUID turns a line typed by the reader into a UID
+// Decimal is the UID as one big-endian number, which is how most readers that type 10 digits mean it;
+// auto takes only lines with A-F in them as hex, since all-digit lines of any length come from decimal
+// readers too - a hex reader needs format hex for the UIDs that happen to have no letters
+func parseWedgeUID(line, format string) ([]byte, error) {
+	if format == "auto" {
+		format = "decimal"
+		if strings.ContainsAny(line, "ABCDEF") {
+			format = "hex"
+		}
+	}
+	switch format {
+	case "hex":
+		uid, err := hex.DecodeString(line)
+		if err != nil || len(uid) == 0 || len(uid) > 10 {
+			return nil, fmt.Errorf("bad hex card UID %q", line)
+		}
+		return uid, nil
+	case "decimal":
+		n, err := strconv.ParseUint(line, 10, 64)
+		if err != nil || n >= 1<<56 {
+			return nil, fmt.Errorf("bad decimal card UID %q", line)
+		}
+		uid := binary.BigEndian.AppendUint64(nil, n)
+		if n <= 0xFFFFFFFF {
+			return uid[4:], nil
+		}
+		return uid[1:], nil // 7 bytes
+	}
+	return nil, fmt.Errorf("card UID format must be decimal, hex or auto, not %q", format)
+}
+
+// IsCardPresent checks if a card was typed in the last couple of seconds
+func (r *WedgeRFIDReader) IsCardPresent() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	return time.Since(r.lastSeen) < 2*time.Second && len(r.lastCard.UID) > 0, nil
+}
+
+// ReadCard describes the card typed last
+func (r *WedgeRFIDReader) ReadCard() (Card, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastSeen) < 2*time.Second && len(r.lastCard.UID) > 0 {
+		return r.lastCard, nil
+	}
+	return Card{}, errNoCard
+}
+
+// CardEvents implements CardEventReader
+// A card arrives when its UID is typed, and leaves once the reader stops typing it
+func (r *WedgeRFIDReader) CardEvents(ctx context.Context) <-chan CardEvent {
+	return watchCards(ctx, r)
+}
+
+func (r *WedgeRFIDReader) detectCard(timeout time.Duration) (Card, error) {
+	select {
+	case card := <-r.scans:
+		return card, nil
+	case <-time.After(timeout):
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return Card{}, r.err
+	}
+	return Card{}, errNoCard
+}
+
+func (r *WedgeRFIDReader) stillPresent(uid []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.Equal(uid, r.lastCard.UID) && time.Since(r.lastSeen) < wedgeRepeatWindow
+}
+
+// Close lets go of the input device
+func (r *WedgeRFIDReader) Close() error {
+	return r.dev.Close()
+}
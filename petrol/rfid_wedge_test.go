@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseWedgeUID(t *testing.T) {
+	tests := []struct {
+		line, format string
+		want         []byte // nil for an error
+	}{
+		{"0305419896", "decimal", []byte{0x12, 0x34, 0x56, 0x78}},
+		{"12345678", "decimal", []byte{0x00, 0xBC, 0x61, 0x4E}},
+		{"1311768467294899695", "decimal", nil}, // Over 7 bytes
+		{"1250999896491", "decimal", []byte{0x00, 0x01, 0x23, 0x45, 0x67, 0x89, 0xAB}},
+		{"12345678", "hex", []byte{0x12, 0x34, 0x56, 0x78}},
+		{"04A23B11223344", "hex", []byte{0x04, 0xA2, 0x3B, 0x11, 0x22, 0x33, 0x44}},
+		{"123", "hex", nil},
+		{"", "hex", nil},
+		{"DEADBEEF", "auto", []byte{0xDE, 0xAD, 0xBE, 0xEF}},
+		{"0305419896", "auto", []byte{0x12, 0x34, 0x56, 0x78}},
+		{"12345678", "auto", []byte{0x00, 0xBC, 0x61, 0x4E}}, // 8 digits are decimal unless told otherwise
+		{"12345678", "octal", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseWedgeUID(tt.line, tt.format)
+		switch {
+		case tt.want == nil && err == nil:
+			t.Errorf("parseWedgeUID(%q, %s) = % X, want an error", tt.line, tt.format, got)
+		case tt.want != nil && err != nil:
+			t.Errorf("parseWedgeUID(%q, %s): %v", tt.line, tt.format, err)
+		case !bytes.Equal(got, tt.want):
+			t.Errorf("parseWedgeUID(%q, %s) = % X, want % X", tt.line, tt.format, got, tt.want)
+		}
+	}
+}
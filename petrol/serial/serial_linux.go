@@ -0,0 +1,61 @@
+// Package serial sets up serial ports for the pump's peripherals - receipt printers and card readers.
+package serial
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// baudRates maps speeds to their termios constants
+var baudRates = map[int]uint32{
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+	460800: unix.B460800,
+	921600: unix.B921600,
+}
+
+// Configure puts a serial port into raw 8N1 mode at baud
+func Configure(file *os.File, baud int) error {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	// Through the raw connection rather than Fd, which would put the file into blocking mode
+	// and stop read deadlines working
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var termiosErr error
+	err = conn.Control(func(fd uintptr) {
+		termiosErr = setRaw(int(fd), speed)
+	})
+	if err != nil {
+		return err
+	}
+	return termiosErr
+}
+
+func setRaw(fd int, speed uint32) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+
+	// Raw mode: no line editing, translation or echo
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CSTOPB | unix.CBAUD
+	t.Cflag |= unix.CS8 | unix.CLOCAL | unix.CREAD | speed
+	t.Ispeed = speed
+	t.Ospeed = speed
+
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package serial
+
+import (
+	"fmt"
+	"os"
+)
+
+// Configure is only implemented on Linux, where the pump runs
+func Configure(*os.File, int) error {
+	return fmt.Errorf("serial ports are only supported on Linux")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"petrol-pump/ndef"
+	"petrol-pump/pump"
+)
+
+// Records the pump understands on NFC stickers, as NFC Forum external types
+const (
+	tagCustomerType = "petrol-pump:customer" // Text: the customer's name
+	tagVehicleType  = "petrol-pump:vehicle"  // Text: the vehicle, e.g. "AB12 CDE, red hatchback"
+	tagVoucherType  = "petrol-pump:voucher"  // Text: a voucher code
+	tagLastFillType = "petrol-pump:lastfill" // JSON: the last sale paid with the tag, written by the pump
+)
+
+// tagProfile is what an NFC sticker tells the pump about its owner
+type tagProfile struct {
+	Customer string
+	Vehicle  string
+	Voucher  string
+	LastFill *lastFill // nil if the tag hasn't paid for a sale yet
+}
+
+// lastFill is the record of the last sale paid with a tag
+type lastFill struct {
+	Time   time.Time   `json:"time"`
+	Pump   int         `json:"pump"`
+	Grade  string      `json:"grade"`
+	Volume pump.Volume `json:"volume_ml"`
+	Amount pump.Money  `json:"amount_pence"`
+}
+
+func (f lastFill) String() string {
+	return fmt.Sprintf("%s %s %s at pump %d, %s", f.Time.Local().Format("2006-01-02 15:04"), f.Volume, f.Grade, f.Pump, f.Amount)
+}
+
+// profileFromMessage picks the pump's records out of a tag's NDEF message
+// A plain text record, as written by most phone apps, is taken as the customer's name
+func profileFromMessage(msg ndef.Message) tagProfile {
+	var profile tagProfile
+	if r, ok := msg.Find(ndef.TNFExternal, tagCustomerType); ok {
+		profile.Customer = string(r.Payload)
+	} else if r, ok := msg.Find(ndef.TNFWellKnown, "T"); ok {
+		profile.Customer, _ = r.Text()
+	}
+	if r, ok := msg.Find(ndef.TNFExternal, tagVehicleType); ok {
+		profile.Vehicle = string(r.Payload)
+	}
+	if r, ok := msg.Find(ndef.TNFExternal, tagVoucherType); ok {
+		profile.Voucher = string(r.Payload)
+	}
+	if r, ok := msg.Find(ndef.TNFExternal, tagLastFillType); ok {
+		var fill lastFill
+		if err := json.Unmarshal(r.Payload, &fill); err == nil {
+			profile.LastFill = &fill
+		} else {
+			fmt.Printf("⚠ Ignoring unreadable last fill record: %v\n", err)
+		}
+	}
+	return profile
+}
+
+// Greeting is the line the success screen shows for the tag's owner, or "" if there's nobody to greet
+func (t tagProfile) Greeting() string {
+	switch {
+	case t.Customer != "" && t.Vehicle != "":
+		return fmt.Sprintf("Thanks, %s (%s)", t.Customer, t.Vehicle)
+	case t.Customer != "":
+		return fmt.Sprintf("Thanks, %s", t.Customer)
+	}
+	return ""
+}
+
+func (t tagProfile) String() string {
+	var parts []string
+	if t.Customer != "" {
+		parts = append(parts, "customer "+t.Customer)
+	}
+	if t.Vehicle != "" {
+		parts = append(parts, "vehicle "+t.Vehicle)
+	}
+	if t.Voucher != "" {
+		parts = append(parts, "voucher "+t.Voucher)
+	}
+	if t.LastFill != nil {
+		parts = append(parts, "last fill "+t.LastFill.String())
+	}
+	if len(parts) == 0 {
+		return "no profile"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lastFillRecord is the record the pump writes back to a tag after a sale
+func lastFillRecord(pumpNumber int, sale pump.Sale) (ndef.Record, error) {
+	payload, err := json.Marshal(lastFill{
+		Time:   sale.End.UTC(),
+		Pump:   pumpNumber,
+		Grade:  sale.Grade,
+		Volume: sale.Volume,
+		Amount: sale.Amount,
+	})
+	if err != nil {
+		return ndef.Record{}, err
+	}
+	return ndef.NewExternal(tagLastFillType, payload), nil
+}